@@ -1,63 +1,69 @@
 package handler
 
 import (
+	"context"
+	"crypto/rand"
 	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/service"
 	"ecommerce-backend/models"
-	"ecommerce-backend/pkg/database"
+	"ecommerce-backend/pkg/apperror"
+	"ecommerce-backend/pkg/money"
+	"ecommerce-backend/pkg/pagination"
 	"ecommerce-backend/pkg/utils"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os" // Added os for directory check
+	"path"
+	"path/filepath"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Handler translates HTTP requests into service calls. Its dependencies are
+// injected through New so it can run against any storage implementation.
+type Handler struct {
+	svc *service.Services
+}
+
+func New(svc *service.Services) *Handler {
+	return &Handler{svc: svc}
+}
+
 // --- Auth Handlers ---
 
-func Register(c *gin.Context) {
+func (h *Handler) Register(c *gin.Context) {
 	var input models.RegisterRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed to POST data", nil, []string{err.Error()})
+		c.Error(apperror.FromBinding(err))
 		return
 	}
 
-	hashedPwd, _ := utils.HashPassword(input.Password)
-	user := models.User{
-		Name: input.Name, Phone: input.Phone, Email: input.Email, 
-		Password: hashedPwd, DOB: input.DOB, Job: input.Job, 
-		Gender: input.Gender, About: input.About, 
-		ProvinceID: input.ProvinceID, CityID: input.CityID,
-	}
-
-	if err := repository.CreateUser(&user); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed to POST data", nil, []string{err.Error()})
+	if _, err := h.svc.Auth.Register(c.Request.Context(), input); err != nil {
+		c.Error(err)
 		return
 	}
 
-	// Auto create store
-	store := models.Store{UserID: user.ID, Name: user.Name + "'s Store"}
-	repository.UpdateStore(&store)
-
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", "Register Succeed", nil)
 }
 
-func Login(c *gin.Context) {
+func (h *Handler) Login(c *gin.Context) {
 	var input models.LoginRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed to POST data", nil, []string{err.Error()})
+		c.Error(apperror.FromBinding(err))
 		return
 	}
 
-	user, err := repository.FindUserByPhone(input.Phone)
-	if err != nil || !utils.CheckPassword(user.Password, input.Password) {
-		utils.APIResponse(c, http.StatusUnauthorized, false, "Failed to POST data", nil, []string{"No Telp atau kata sandi salah"})
+	user, token, err := h.svc.Auth.Login(c.Request.Context(), input)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	token, _ := utils.GenerateToken(user.ID, user.IsAdmin)
-	
 	response := map[string]interface{}{
 		"nama": user.Name, "no_telp": user.Phone, "email": user.Email, "token": token,
 	}
@@ -66,429 +72,1290 @@ func Login(c *gin.Context) {
 
 // --- User/Profile Handlers ---
 
-func GetProfile(c *gin.Context) {
+func (h *Handler) GetProfile(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	user, err := repository.FindUserByID(userID)
+	user, err := h.svc.Users.Get(c.Request.Context(), userID)
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Failed to GET data", nil, []string{"User not found"})
+		c.Error(err)
 		return
 	}
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", user, nil)
 }
 
-func UpdateProfile(c *gin.Context) {
+func (h *Handler) UpdateProfile(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
 	var input models.User
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed to UPDATE data", nil, []string{err.Error()})
+		c.Error(apperror.FromBinding(err))
 		return
 	}
 
-	user, err := repository.FindUserByID(userID)
+	_, err := h.svc.Users.Update(c.Request.Context(), userID, service.UpdateProfileInput{
+		Name:     input.Name,
+		Job:      input.Job,
+		About:    input.About,
+		Gender:   input.Gender,
+		Password: input.Password,
+	})
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "User not found", nil, nil)
+		c.Error(err)
 		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", "", nil)
+}
 
-	// Update fields
-	user.Name = input.Name
-	user.Job = input.Job
-	user.About = input.About
-	user.Gender = input.Gender
-	if input.Password != "" {
-		hash, _ := utils.HashPassword(input.Password)
-		user.Password = hash
+// SetUserReseller lets admins grant or revoke the reseller role.
+func (h *Handler) SetUserReseller(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.SetResellerRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
 	}
-	
-	repository.UpdateUser(&user)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", "", nil)
+
+	user, err := h.svc.Users.SetReseller(c.Request.Context(), uint(id), *input.IsReseller)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", user, nil)
 }
 
 // --- Address Handlers ---
 
-func GetMyAddress(c *gin.Context) {
+func (h *Handler) GetMyAddress(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	addresses, _ := repository.GetAddressesByUserID(userID)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", addresses, nil)
-}
-
-func GetAddressByID(c *gin.Context) {
-	id, _ := strconv.Atoi(c.Param("id"))
-	address, err := repository.GetAddressByID(uint(id))
+	addresses, err := h.svc.Addresses.List(c.Request.Context(), userID)
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Failed to GET data", nil, []string{"Address not found"})
+		c.Error(err)
 		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", addresses, nil)
+}
 
+func (h *Handler) GetAddressByID(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
 	userID := c.MustGet("user_id").(uint)
-	if address.UserID != userID {
-		utils.APIResponse(c, http.StatusForbidden, false, "Failed", nil, []string{"Forbidden"})
+
+	address, err := h.svc.Addresses.Get(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.Error(err)
 		return
 	}
-
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", address, nil)
 }
 
-func CreateAddress(c *gin.Context) {
+func (h *Handler) CreateAddress(c *gin.Context) {
 	var input models.Address
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed", nil, []string{err.Error()})
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	address, err := h.svc.Addresses.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	input.UserID = c.MustGet("user_id").(uint)
-	repository.CreateAddress(&input)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", 1, nil)
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", address.ID, nil)
 }
 
-func UpdateAddress(c *gin.Context) {
+func (h *Handler) UpdateAddress(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	var input models.Address
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed", nil, []string{err.Error()})
-		return
-	}
-
-	address, err := repository.GetAddressByID(uint(id))
-	if err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed to GET data", nil, []string{"record not found"})
+		c.Error(apperror.FromBinding(err))
 		return
 	}
+	userID := c.MustGet("user_id").(uint)
 
-	if address.UserID != c.MustGet("user_id").(uint) {
-		utils.APIResponse(c, http.StatusForbidden, false, "Forbidden", nil, nil)
+	if _, err := h.svc.Addresses.Update(c.Request.Context(), userID, uint(id), input); err != nil {
+		c.Error(err)
 		return
 	}
-
-	address.ReceiverName = input.ReceiverName
-	address.Phone = input.Phone
-	address.Detail = input.Detail
-	repository.UpdateAddress(&address)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", "", nil)
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", "", nil)
 }
 
-func DeleteAddress(c *gin.Context) {
+func (h *Handler) DeleteAddress(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	address, err := repository.GetAddressByID(uint(id))
-	if err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed to GET data", nil, []string{"record not found"})
-		return
-	}
+	userID := c.MustGet("user_id").(uint)
 
-	if address.UserID != c.MustGet("user_id").(uint) {
-		utils.APIResponse(c, http.StatusForbidden, false, "Forbidden", nil, nil)
+	if err := h.svc.Addresses.Delete(c.Request.Context(), userID, uint(id)); err != nil {
+		c.Error(err)
 		return
 	}
-
-	repository.DeleteAddress(address.ID)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", "", nil)
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to DELETE data", "", nil)
 }
 
 // --- Store Handlers ---
 
-func GetMyStore(c *gin.Context) {
+func (h *Handler) GetMyStore(c *gin.Context) {
 	userID := c.MustGet("user_id").(uint)
-	store, err := repository.GetStoreByUserID(userID)
+	store, err := h.svc.Stores.GetByUserID(c.Request.Context(), userID)
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Store not found", nil, nil)
+		c.Error(err)
 		return
 	}
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", store, nil)
 }
 
-func UpdateStore(c *gin.Context) {
+func (h *Handler) UpdateStore(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id_toko"))
-	store, err := repository.GetStoreByID(uint(id))
-	
-	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Store not found", nil, nil)
-		return
-	}
-	
-	if store.UserID != c.MustGet("user_id").(uint) {
-		utils.APIResponse(c, http.StatusForbidden, false, "Forbidden", nil, nil)
+	userID := c.MustGet("user_id").(uint)
+
+	// Check ownership before storing the upload
+	if _, err := h.svc.Stores.GetOwned(c.Request.Context(), userID, uint(id)); err != nil {
+		c.Error(err)
 		return
 	}
 
-	store.Name = c.PostForm("nama_toko")
+	input := service.UpdateStoreInput{Name: c.PostForm("nama_toko")}
 	filename, err := utils.SaveUploadedFile(c, "photo")
 	if err == nil {
-		store.PhotoURL = filename
+		input.PhotoURL = filename
 	}
 
-	repository.UpdateStore(&store)
+	if _, err := h.svc.Stores.Update(c.Request.Context(), userID, uint(id), input); err != nil {
+		c.Error(err)
+		return
+	}
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", "Update toko succeed", nil)
 }
 
-func GetStoreByID(c *gin.Context) {
+func (h *Handler) GetStoreByID(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id_toko"))
-	store, err := repository.GetStoreByID(uint(id))
+	store, err := h.svc.Stores.Get(c.Request.Context(), uint(id))
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Failed to GET data", nil, []string{"Toko tidak ditemukan"})
+		c.Error(err)
 		return
 	}
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", store, nil)
 }
 
-func GetAllStores(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	name := c.Query("nama")
+func (h *Handler) GetAllStores(c *gin.Context) {
+	page, err := pageQuery(c, repository.StoreSort)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
-	stores, _, _ := repository.GetAllStores(page, limit, name)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", models.Pagination{Page: page, Limit: limit, Data: stores}, nil)
+	stores, total, err := h.svc.Stores.List(c.Request.Context(), repository.StoreFilter{Name: c.Query("nama"), Page: page})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", paginated(page, stores, total, repository.StoreKeys), nil)
 }
 
 // --- Category Handlers (Admin) ---
 
-func GetAllCategory(c *gin.Context) {
-	cats, _ := repository.GetAllCategories()
+func (h *Handler) GetAllCategory(c *gin.Context) {
+	cats, err := h.svc.Categories.List(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", cats, nil)
 }
 
-func CreateCategory(c *gin.Context) {
-	var input models.Category
+func (h *Handler) CreateCategory(c *gin.Context) {
+	var input models.CategoryRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed", nil, nil)
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	cat, err := h.svc.Categories.Create(c.Request.Context(), input.Name)
+	if err != nil {
+		c.Error(err)
 		return
 	}
-	repository.CreateCategory(&input)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", input.ID, nil)
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", cat.ID, nil)
 }
 
-func GetCategoryByID(c *gin.Context) {
+func (h *Handler) GetCategoryByID(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	cat, err := repository.GetCategoryByID(uint(id))
+	cat, err := h.svc.Categories.Get(c.Request.Context(), uint(id))
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Failed to GET data", nil, []string{"No Data Category"})
+		c.Error(err)
 		return
 	}
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", cat, nil)
 }
 
-func UpdateCategory(c *gin.Context) {
+func (h *Handler) UpdateCategory(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	var input models.Category
-	c.ShouldBindJSON(&input)
-	
-	cat, err := repository.GetCategoryByID(uint(id))
-	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Not Found", nil, nil)
+	var input models.CategoryRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+
+	if _, err := h.svc.Categories.Update(c.Request.Context(), uint(id), input.Name); err != nil {
+		c.Error(err)
 		return
 	}
-	cat.Name = input.Name
-	repository.UpdateCategory(&cat)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", "", nil)
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", "", nil)
 }
 
-func DeleteCategory(c *gin.Context) {
+func (h *Handler) DeleteCategory(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	err := repository.DeleteCategory(uint(id))
-	if err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed to GET data", nil, []string{"record not found"})
+	if err := h.svc.Categories.Delete(c.Request.Context(), uint(id)); err != nil {
+		c.Error(err)
 		return
 	}
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", "", nil)
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to DELETE data", "", nil)
 }
 
 // --- Product Handlers ---
 
-func GetAllProducts(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	name := c.Query("nama_produk")
-	catID := c.Query("category_id")
-	storeID := c.Query("toko_id")
-	maxP := c.Query("max_harga")
-	minP := c.Query("min_harga")
+func (h *Handler) GetAllProducts(c *gin.Context) {
+	page, err := pageQuery(c, repository.ProductSort)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	maxPrice, err := queryAmount(c, "max_harga")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	minPrice, err := queryAmount(c, "min_harga")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// Searching by name goes through the search index, best match first
+	if name := c.Query("nama_produk"); name != "" {
+		if c.Query("sort") != "" || page.After != nil {
+			c.Error(&apperror.Error{
+				Code:    apperror.CodeValidation,
+				Message: "results of nama_produk are ranked by relevance",
+				Fields: []apperror.FieldError{{
+					Field: "sort", Rule: "sort",
+					Message: "sort and cursor cannot be combined with nama_produk",
+				}},
+			})
+			return
+		}
+		categoryID, err := queryID(c, "category_id")
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		storeID, err := queryID(c, "toko_id")
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		result, err := h.svc.Products.Search(c.Request.Context(), models.ProductSearchQuery{
+			Q:          name,
+			CategoryID: categoryID,
+			StoreID:    storeID,
+			MinPrice:   minPrice,
+			MaxPrice:   maxPrice,
+			Page:       page.Page,
+			Limit:      page.Limit,
+		})
+		if err == nil {
+			err = h.markWishlisted(c, result.Data)
+		}
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", models.Pagination{
+			Page:       result.Page,
+			Limit:      result.Limit,
+			Total:      int64(result.Total),
+			TotalPages: result.TotalPages,
+			Data:       result.Data,
+		}, nil)
+		return
+	}
+
+	products, total, err := h.svc.Products.List(c.Request.Context(), repository.ProductFilter{
+		Page:       page,
+		CategoryID: c.Query("category_id"),
+		StoreID:    c.Query("toko_id"),
+		MaxPrice:   maxPrice,
+		MinPrice:   minPrice,
+	})
+	if err == nil {
+		err = h.markWishlisted(c, products)
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", paginated(page, products, total, repository.ProductKeys), nil)
+}
+
+// SearchProducts is the full-text product search, ranked by relevance and
+// with facet counts by category, store and price.
+func (h *Handler) SearchProducts(c *gin.Context) {
+	var query models.ProductSearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	clampPage(&query.Page, &query.Limit)
+
+	result, err := h.svc.Products.Search(c.Request.Context(), query)
+	if err == nil {
+		err = h.markWishlisted(c, result.Data)
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", result, nil)
+}
 
-	products, _, _ := repository.GetProducts(page, limit, name, catID, storeID, maxP, minP)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", models.Pagination{Page: page, Limit: limit, Data: products}, nil)
+// queryID reads an optional id from the query string, 0 when absent.
+func queryID(c *gin.Context, name string) (uint, error) {
+	s := c.Query(name)
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		return 0, &apperror.Error{
+			Code:    apperror.CodeValidation,
+			Message: err.Error(),
+			Fields: []apperror.FieldError{{
+				Field: name, Rule: "number",
+				Message: name + " must be a positive number",
+			}},
+		}
+	}
+	return uint(id), nil
+}
+
+// queryAmount reads an optional rupiah amount from the query string.
+func queryAmount(c *gin.Context, name string) (*money.Amount, error) {
+	s := c.Query(name)
+	if s == "" {
+		return nil, nil
+	}
+	amount, err := money.Parse(s)
+	if err != nil {
+		return nil, &apperror.Error{
+			Code:    apperror.CodeValidation,
+			Message: err.Error(),
+			Fields: []apperror.FieldError{{
+				Field: name, Rule: "money",
+				Message: name + " must be a rupiah amount with at most 2 decimals",
+			}},
+		}
+	}
+	return &amount, nil
 }
 
-func GetProductByID(c *gin.Context) {
+func (h *Handler) GetProductByID(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	prod, err := repository.GetProductByID(uint(id))
+	prod, err := h.svc.Products.Get(c.Request.Context(), uint(id))
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Failed to GET data", nil, []string{"No Data Product"})
+		c.Error(err)
 		return
 	}
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", prod, nil)
+	h.respondProduct(c, prod)
 }
 
-func CreateProduct(c *gin.Context) {
-	userID := c.MustGet("user_id").(uint)
-	store, err := repository.GetStoreByUserID(userID)
+// GetProductBySlug returns a product by the slug of its storefront URL. A
+// slug the product had before being renamed redirects permanently to the
+// current one.
+func (h *Handler) GetProductBySlug(c *gin.Context) {
+	prod, moved, err := h.svc.Products.GetBySlug(c.Request.Context(), c.Param("slug"))
 	if err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "User has no store", nil, []string{"User must have a store"})
+		c.Error(err)
+		return
+	}
+	if moved {
+		c.Redirect(http.StatusMovedPermanently, path.Join(path.Dir(c.Request.URL.Path), prod.Slug))
+		return
+	}
+	h.respondProduct(c, prod)
+}
+
+// respondProduct answers with a product, marked for a signed-in caller
+// who saved it.
+func (h *Handler) respondProduct(c *gin.Context, prod models.Product) {
+	products := []models.Product{prod}
+	if err := h.markWishlisted(c, products); err != nil {
+		c.Error(err)
 		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", products[0], nil)
+}
+
+// markWishlisted sets IsWishlisted on the products the caller saved. It
+// does nothing for anonymous callers of routes with optional auth.
+func (h *Handler) markWishlisted(c *gin.Context, products []models.Product) error {
+	userID, ok := c.Get("user_id")
+	if !ok {
+		return nil
+	}
+	return h.svc.Wishlist.Mark(c.Request.Context(), userID.(uint), products)
+}
 
-	priceRes, _ := strconv.ParseFloat(c.PostForm("harga_reseller"), 64)
-	priceCons, _ := strconv.ParseFloat(c.PostForm("harga_konsumen"), 64)
-	stock, _ := strconv.Atoi(c.PostForm("stok"))
-	catID, _ := strconv.Atoi(c.PostForm("category_id"))
+func (h *Handler) CreateProduct(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
 
-	// Validation: Ensure valid Category ID is provided
-	if catID == 0 {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Validation Failed", nil, []string{"category_id is required"})
+	var req models.CreateProductRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.Error(apperror.FromBinding(err))
 		return
 	}
 
-	product := models.Product{
-		Name:          c.PostForm("nama_produk"),
-		CategoryID:    uint(catID),
-		StoreID:       store.ID,
-		ResellerPrice: priceRes,
-		ConsumerPrice: priceCons,
-		Stock:         stock,
-		Description:   c.PostForm("deskripsi"),
-		Slug:          utils.Slugify(c.PostForm("nama_produk")),
+	input := service.CreateProductInput{
+		Name:          req.Name,
+		CategoryID:    req.CategoryID,
+		ResellerPrice: req.ResellerPrice,
+		ConsumerPrice: req.ConsumerPrice,
+		Stock:         req.Stock,
+		Description:   req.Description,
 	}
 
-	if err := repository.CreateProduct(&product); err != nil {
-		// Log the actual error for debugging
-		fmt.Println("Create Product Error:", err) 
-		utils.APIResponse(c, http.StatusInternalServerError, false, "Failed to create product in DB", nil, []string{err.Error()})
+	input.PhotoURLs = saveUploads(c)
+
+	product, err := h.svc.Products.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		if !errors.Is(err, service.ErrCommitted) {
+			removeUploads(input.PhotoURLs)
+		}
+		c.Error(err)
 		return
 	}
 
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", product.ID, nil)
+}
+
+// saveUploads stores the files of the photos field of a multipart form in
+// the upload directory and returns their names. Requests without a
+// multipart form have none.
+func saveUploads(c *gin.Context) []string {
 	// Safely Handle Multiple Photos
 	form, err := c.MultipartForm()
-	if err == nil && form != nil { // Check if form exists
-		files := form.File["photos"]
-		
-		// 1. Ensure upload directory exists
-		uploadPath := "public/uploads"
-		if _, err := os.Stat(uploadPath); os.IsNotExist(err) {
-			os.MkdirAll(uploadPath, 0755)
+	if err != nil || form == nil { // Check if form exists
+		return nil
+	}
+	files := form.File["photos"]
+
+	// 1. Ensure upload directory exists
+	if _, err := os.Stat(utils.UploadPath); os.IsNotExist(err) {
+		os.MkdirAll(utils.UploadPath, 0755)
+	}
+
+	// 2. Loop through files and save
+	var names []string
+	for _, file := range files {
+		filename := uploadName(file.Filename)
+		dst := fmt.Sprintf("%s/%s", utils.UploadPath, filename)
+
+		// Only attach to the product if file save was successful
+		if err := c.SaveUploadedFile(file, dst); err == nil {
+			names = append(names, filename)
+		} else {
+			log.Printf("Failed to save file: %v", err)
 		}
+	}
+	return names
+}
+
+// uploadName is a random name for an uploaded file, keeping the extension
+// of original. Uploads never share a name, so removing one cannot take
+// the file of another.
+func uploadName(original string) string {
+	return strings.ToLower(rand.Text()) + strings.ToLower(filepath.Ext(original))
+}
+
+// removeUpload deletes a stored upload once nothing refers to it anymore.
+// Failing only leaves a stray file behind, so it is just logged.
+func removeUpload(name string) {
+	err := os.Remove(fmt.Sprintf("%s/%s", utils.UploadPath, filepath.Base(name)))
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove file: %v", err)
+	}
+}
+
+// removeUploads deletes the uploads of a request the service refused, so
+// they do not stay behind unused. Uploads of a write that was committed
+// before the request failed are in use and must be kept.
+func removeUploads(names []string) {
+	for _, name := range names {
+		removeUpload(name)
+	}
+}
+
+// UpdateProduct changes the fields sent, as JSON or as a multipart form,
+// and answers with the updated product. Photos sent in the form are added
+// after the current ones. PUT and PATCH behave the same.
+func (h *Handler) UpdateProduct(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
 
-		// 2. Loop through files and save
-		for _, file := range files {
-			filename := fmt.Sprintf("%d-%s", time.Now().Unix(), file.Filename)
-			dst := fmt.Sprintf("%s/%s", uploadPath, filename)
-			
-			// Save the file
-			if err := c.SaveUploadedFile(file, dst); err == nil {
-				// Only save to DB if file save was successful
-				database.DB.Create(&models.ProductPhoto{ProductID: product.ID, URL: filename})
-			} else {
-				fmt.Println("Failed to save file:", err)
-			}
+	var req models.UpdateProductRequest
+	if err := c.ShouldBind(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	input := service.UpdateProductInput{
+		Name:          req.Name,
+		CategoryID:    req.CategoryID,
+		ResellerPrice: req.ResellerPrice,
+		ConsumerPrice: req.ConsumerPrice,
+		Stock:         req.Stock,
+		Description:   req.Description,
+		PhotoURLs:     saveUploads(c),
+	}
+	product, err := h.svc.Products.Update(c.Request.Context(), userID, uint(id), input)
+	if err != nil {
+		if !errors.Is(err, service.ErrCommitted) {
+			removeUploads(input.PhotoURLs)
 		}
+		c.Error(err)
+		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", product, nil)
+}
 
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", product.ID, nil)
+// AddProductPhotos adds the photos of a multipart form after the current
+// ones and answers with every photo of the product.
+func (h *Handler) AddProductPhotos(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
+
+	names := saveUploads(c)
+	photos, err := h.svc.Products.AddPhotos(c.Request.Context(), userID, uint(id), names)
+	if err != nil {
+		if !errors.Is(err, service.ErrCommitted) {
+			removeUploads(names)
+		}
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", photos, nil)
 }
 
-func UpdateProduct(c *gin.Context) {
+func (h *Handler) DeleteProductPhoto(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	product, err := repository.GetProductByID(uint(id))
-	
+	photoID, _ := strconv.Atoi(c.Param("photo_id"))
+	userID := c.MustGet("user_id").(uint)
+
+	photo, err := h.svc.Products.DeletePhoto(c.Request.Context(), userID, uint(id), uint(photoID))
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Not Found", nil, nil)
+		c.Error(err)
 		return
 	}
+	removeUpload(photo.URL)
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to DELETE data", "", nil)
+}
 
+func (h *Handler) DeleteProductPhotos(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
 	userID := c.MustGet("user_id").(uint)
-	store, _ := repository.GetStoreByUserID(userID)
-	if product.StoreID != store.ID {
-		utils.APIResponse(c, http.StatusForbidden, false, "Forbidden", nil, nil)
+
+	photos, err := h.svc.Products.DeletePhotos(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	for _, photo := range photos {
+		removeUpload(photo.URL)
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to DELETE data", "", nil)
+}
+
+func (h *Handler) ReorderProductPhotos(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
 
-	product.Name = c.PostForm("nama_produk")
-	if val := c.PostForm("nama_produk"); val != "" { product.Name = val }
-	
-	repository.UpdateProduct(&product)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", "", nil)
+	var req models.ReorderPhotosRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	photos, err := h.svc.Products.ReorderPhotos(c.Request.Context(), userID, uint(id), req.PhotoIDs)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", photos, nil)
 }
 
-func DeleteProduct(c *gin.Context) {
+func (h *Handler) SetPrimaryProductPhoto(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	product, err := repository.GetProductByID(uint(id))
-	
+	photoID, _ := strconv.Atoi(c.Param("photo_id"))
+	userID := c.MustGet("user_id").(uint)
+
+	photos, err := h.svc.Products.SetPrimaryPhoto(c.Request.Context(), userID, uint(id), uint(photoID))
 	if err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed to GET data", nil, []string{"record not found"})
+		c.Error(err)
 		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", photos, nil)
+}
 
+// SetProductVariants replaces the option and variant matrix of a product
+// and answers with the product as GET /product/:id shows it.
+func (h *Handler) SetProductVariants(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
 	userID := c.MustGet("user_id").(uint)
-	store, _ := repository.GetStoreByUserID(userID)
-	if product.StoreID != store.ID {
-		utils.APIResponse(c, http.StatusForbidden, false, "Forbidden", nil, nil)
+
+	var req models.ProductVariantsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(apperror.FromBinding(err))
 		return
 	}
+	product, err := h.svc.Products.SetVariants(c.Request.Context(), userID, uint(id), req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", product, nil)
+}
 
-	repository.DeleteProduct(product.ID)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", "", nil)
+func (h *Handler) DeleteProduct(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
+
+	if err := h.svc.Products.Delete(c.Request.Context(), userID, uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to DELETE data", "", nil)
 }
 
 // --- Transaction Handlers ---
 
-func CreateTrx(c *gin.Context) {
+func (h *Handler) CreateTrx(c *gin.Context) {
 	var input models.TrxRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Failed", nil, []string{err.Error()})
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	orders, err := h.svc.Transactions.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", placedOrders(orders), nil)
+}
+
+// placedOrders lists the orders a request placed, one per store.
+func placedOrders(orders []models.Transaction) []models.PlacedOrder {
+	placed := make([]models.PlacedOrder, len(orders))
+	for i, trx := range orders {
+		placed[i] = models.PlacedOrder{ID: trx.ID, InvoiceCode: trx.InvoiceCode}
+	}
+	return placed
+}
 
+func (h *Handler) GetTrxByID(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
 	userID := c.MustGet("user_id").(uint)
 
-	addr, err := repository.GetAddressByID(input.AlamatKirim)
-	if err != nil || addr.UserID != userID {
-		utils.APIResponse(c, http.StatusBadRequest, false, "Invalid Address", nil, nil)
+	trx, err := h.svc.Transactions.Get(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", trx, nil)
+}
 
-	// Calculate Total strictly for header
-	var total float64
-	for _, item := range input.DetailTrx {
-		prod, err := repository.GetProductByID(item.ProductID)
-		if err != nil {
-			utils.APIResponse(c, http.StatusBadRequest, false, "Product Unavailable", nil, nil)
-			return
-		}
-		total += float64(item.Kuantitas) * prod.ConsumerPrice
+// GetTrxByInvoice looks an order up by its invoice number. Invoice numbers
+// contain slashes, so the route takes the rest of the path; clients may
+// send them as is or escaped.
+func (h *Handler) GetTrxByInvoice(c *gin.Context) {
+	code := strings.TrimPrefix(c.Param("code"), "/")
+	userID := c.MustGet("user_id").(uint)
+
+	trx, err := h.svc.Transactions.GetByInvoice(c.Request.Context(), userID, code)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", trx, nil)
+}
+
+func (h *Handler) GetAllTrx(c *gin.Context) {
+	page, err := pageQuery(c, repository.TransactionSort)
+	if err != nil {
+		c.Error(err)
+		return
 	}
+	userID := c.MustGet("user_id").(uint)
 
-	trx := models.Transaction{
-		UserID:        userID,
-		AddressID:     input.AlamatKirim,
-		TotalPrice:    total,
-		InvoiceCode:   fmt.Sprintf("INV-%d", time.Now().Unix()),
-		PaymentMethod: input.MethodBayar,
+	trxs, total, err := h.svc.Transactions.List(c.Request.Context(), userID, page)
+	if err != nil {
+		c.Error(err)
+		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", paginated(page, trxs, total, repository.TransactionKeys), nil)
+}
+
+// --- Order Status Handlers ---
 
-	// Now passing slice directly because Repo accepts []models.TrxItemRequest
-	if err := repository.CreateTransaction(&trx, input.DetailTrx); err != nil {
-		utils.APIResponse(c, http.StatusInternalServerError, false, "Failed to create transaction", nil, []string{err.Error()})
+func (h *Handler) CancelTrx(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.CancelTrxRequest
+	// The reason is optional, so an empty body is fine
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.Error(apperror.FromBinding(err))
 		return
 	}
+	userID := c.MustGet("user_id").(uint)
 
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", len(input.DetailTrx), nil)
+	trx, err := h.svc.Transactions.Cancel(c.Request.Context(), userID, uint(id), input.Reason)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", trx, nil)
 }
 
-func GetTrxByID(c *gin.Context) {
+func (h *Handler) CompleteTrx(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
-	trx, err := repository.GetTransactionByID(uint(id))
-	
+	userID := c.MustGet("user_id").(uint)
+
+	trx, err := h.svc.Transactions.Complete(c.Request.Context(), userID, uint(id))
 	if err != nil {
-		utils.APIResponse(c, http.StatusNotFound, false, "Failed to GET data", nil, []string{"No Data Trx"})
+		c.Error(err)
 		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", trx, nil)
+}
 
+func (h *Handler) GetStoreOrders(c *gin.Context) {
+	var query models.StoreOrderQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	clampPage(&query.Page, &query.Limit)
 	userID := c.MustGet("user_id").(uint)
-	if trx.UserID != userID {
-		utils.APIResponse(c, http.StatusForbidden, false, "Forbidden", nil, nil)
+
+	lines, total, err := h.svc.Transactions.ListStoreOrders(c.Request.Context(), userID, query)
+	if err != nil {
+		c.Error(err)
 		return
 	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", pageOf(query.Page, query.Limit, total, lines), nil)
+}
+
+func (h *Handler) GetStoreOrderByID(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
 
+	trx, err := h.svc.Transactions.GetStoreOrder(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
 	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", trx, nil)
 }
 
-func GetAllTrx(c *gin.Context) {
+func (h *Handler) ProcessOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
 	userID := c.MustGet("user_id").(uint)
-	trxs, _ := repository.GetTransactionsByUserID(userID)
-	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", models.Pagination{Data: trxs}, nil)
-}
\ No newline at end of file
+
+	trx, err := h.svc.Transactions.Process(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", trx, nil)
+}
+
+func (h *Handler) ShipOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.ShipTrxRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	trx, err := h.svc.Transactions.Ship(c.Request.Context(), userID, uint(id), input.TrackingNumber)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", trx, nil)
+}
+
+func (h *Handler) DeliverOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
+
+	trx, err := h.svc.Transactions.Deliver(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", trx, nil)
+}
+
+func (h *Handler) CancelOrder(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.CancelTrxRequest
+	// The reason is optional, so an empty body is fine
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	trx, err := h.svc.Transactions.SellerCancel(c.Request.Context(), userID, uint(id), input.Reason)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", trx, nil)
+}
+
+func (h *Handler) UpdateTrxStatus(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.UpdateTrxStatusRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	actor := service.Actor{ID: c.MustGet("user_id").(uint), Role: models.ActorAdmin}
+
+	trx, err := h.svc.Transactions.SetStatus(c.Request.Context(), actor, uint(id), input)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", trx, nil)
+}
+
+// --- Payment Handlers ---
+
+func (h *Handler) PayTrx(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
+
+	p, err := h.svc.Payments.Pay(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", p, nil)
+}
+
+func (h *Handler) GetTrxPayment(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
+
+	p, err := h.svc.Payments.Get(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", p, nil)
+}
+
+// PaymentWebhook receives notifications from payment providers. The raw
+// body is passed on untouched because signatures are computed over it.
+func (h *Handler) PaymentWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, 1<<20))
+	if err != nil {
+		c.Error(apperror.Wrap(apperror.CodeBadRequest, "cannot read body", err))
+		return
+	}
+
+	err = h.svc.Payments.HandleWebhook(c.Request.Context(), c.Param("provider"), c.Request.Header, body)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", nil, nil)
+}
+
+func (h *Handler) SimulatePayment(c *gin.Context) {
+	var input models.SimulatePaymentRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	p, err := h.svc.Payments.Simulate(c.Request.Context(), userID, c.Param("charge_id"), input.Status)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", p, nil)
+}
+
+// --- Cart Handlers ---
+
+func (h *Handler) GetCart(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	cart, err := h.svc.Carts.Get(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", cart, nil)
+}
+
+func (h *Handler) AddCartItem(c *gin.Context) {
+	var input models.CartItemRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	if err := h.svc.Carts.AddItem(c.Request.Context(), userID, input); err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondCart(c, "Succeed to POST data")
+}
+
+func (h *Handler) UpdateCartItem(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	if err := h.svc.Carts.UpdateItem(c.Request.Context(), userID, uint(id), input.Kuantitas); err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondCart(c, "Succeed to UPDATE data")
+}
+
+func (h *Handler) DeleteCartItem(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	userID := c.MustGet("user_id").(uint)
+
+	if err := h.svc.Carts.RemoveItem(c.Request.Context(), userID, uint(id)); err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondCart(c, "Succeed to DELETE data")
+}
+
+func (h *Handler) ClearCart(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	if err := h.svc.Carts.Clear(c.Request.Context(), userID); err != nil {
+		c.Error(err)
+		return
+	}
+	h.respondCart(c, "Succeed to DELETE data")
+}
+
+// respondCart answers a cart change with the updated cart, so clients do not
+// need a second request to refresh prices and totals.
+func (h *Handler) respondCart(c *gin.Context, message string) {
+	userID := c.MustGet("user_id").(uint)
+	cart, err := h.svc.Carts.Get(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, message, cart, nil)
+}
+
+func (h *Handler) CheckoutCart(c *gin.Context) {
+	var input models.CheckoutRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	orders, err := h.svc.Carts.Checkout(c.Request.Context(), userID, input)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", placedOrders(orders), nil)
+}
+
+// --- Commission Handlers ---
+
+func (h *Handler) GetCommission(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	balance, err := h.svc.Ledger.Balance(c.Request.Context(), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", balance, nil)
+}
+
+func (h *Handler) GetCommissionEntries(c *gin.Context) {
+	var query models.LedgerQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	clampPage(&query.Page, &query.Limit)
+	userID := c.MustGet("user_id").(uint)
+
+	entries, total, err := h.svc.Ledger.Entries(c.Request.Context(), userID, query.Page, query.Limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", pageOf(query.Page, query.Limit, total, entries), nil)
+}
+
+func (h *Handler) RequestPayout(c *gin.Context) {
+	var input models.PayoutRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	payout, err := h.svc.Ledger.RequestPayout(c.Request.Context(), userID, input)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", payout, nil)
+}
+
+func (h *Handler) GetMyPayouts(c *gin.Context) {
+	h.listPayouts(c, c.MustGet("user_id").(uint))
+}
+
+// GetAllPayouts lists the payouts of every user for admins, typically
+// filtered with status=pending.
+func (h *Handler) GetAllPayouts(c *gin.Context) {
+	h.listPayouts(c, 0)
+}
+
+func (h *Handler) listPayouts(c *gin.Context, userID uint) {
+	var query models.PayoutQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	clampPage(&query.Page, &query.Limit)
+
+	payouts, total, err := h.svc.Ledger.Payouts(c.Request.Context(), userID, query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", pageOf(query.Page, query.Limit, total, payouts), nil)
+}
+
+func (h *Handler) ApprovePayout(c *gin.Context) {
+	h.resolvePayout(c, h.svc.Ledger.ApprovePayout)
+}
+
+func (h *Handler) RejectPayout(c *gin.Context) {
+	h.resolvePayout(c, h.svc.Ledger.RejectPayout)
+}
+
+func (h *Handler) resolvePayout(c *gin.Context, resolve func(ctx context.Context, adminID, id uint, note string) (models.Payout, error)) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.ResolvePayoutRequest
+	// The note is optional, so an empty body is fine
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	adminID := c.MustGet("user_id").(uint)
+
+	payout, err := resolve(c.Request.Context(), adminID, uint(id), input.Note)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", payout, nil)
+}
+
+// --- Review Handlers ---
+
+// CreateReview reviews an order line, as JSON or as a multipart form with
+// photos. Saved photos are removed again when the review is refused.
+func (h *Handler) CreateReview(c *gin.Context) {
+	var req models.CreateReviewRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	input := service.CreateReviewInput{
+		DetailID: req.DetailID,
+		Rating:   req.Rating,
+		Text:     req.Text,
+	}
+	input.PhotoURLs = saveUploads(c)
+
+	review, err := h.svc.Reviews.Create(c.Request.Context(), userID, input)
+	if err != nil {
+		if !errors.Is(err, service.ErrCommitted) {
+			removeUploads(input.PhotoURLs)
+		}
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", review, nil)
+}
+
+func (h *Handler) GetProductReviews(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	h.listReviews(c, func(ctx context.Context, query models.ReviewQuery, page pagination.Params) ([]models.Review, int64, error) {
+		return h.svc.Reviews.ProductReviews(ctx, uint(id), query.Rating, page)
+	})
+}
+
+func (h *Handler) GetStoreReviews(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id_toko"))
+	h.listReviews(c, func(ctx context.Context, query models.ReviewQuery, page pagination.Params) ([]models.Review, int64, error) {
+		return h.svc.Reviews.StoreReviews(ctx, uint(id), query.Rating, page)
+	})
+}
+
+func (h *Handler) GetMyReviews(c *gin.Context) {
+	userID := c.MustGet("user_id").(uint)
+	h.listReviews(c, func(ctx context.Context, query models.ReviewQuery, page pagination.Params) ([]models.Review, int64, error) {
+		return h.svc.Reviews.List(ctx, repository.ReviewFilter{UserID: userID, Rating: query.Rating, Hidden: query.Hidden, Page: page})
+	})
+}
+
+// GetAllReviews lists the reviews of every product for admins, typically
+// filtered with disembunyikan=true.
+func (h *Handler) GetAllReviews(c *gin.Context) {
+	h.listReviews(c, func(ctx context.Context, query models.ReviewQuery, page pagination.Params) ([]models.Review, int64, error) {
+		return h.svc.Reviews.List(ctx, repository.ReviewFilter{Rating: query.Rating, Hidden: query.Hidden, Page: page})
+	})
+}
+
+func (h *Handler) listReviews(c *gin.Context, list func(ctx context.Context, query models.ReviewQuery, page pagination.Params) ([]models.Review, int64, error)) {
+	var query models.ReviewQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	page, err := pageQuery(c, repository.ReviewSort)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	reviews, total, err := list(c.Request.Context(), query, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", paginated(page, reviews, total, repository.ReviewKeys), nil)
+}
+
+func (h *Handler) ReplyReview(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.ReplyReviewRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	review, err := h.svc.Reviews.Reply(c.Request.Context(), userID, uint(id), input.Reply)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", review, nil)
+}
+
+func (h *Handler) HideReview(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	var input models.HideReviewRequest
+	// The reason is optional, so an empty body is fine
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	adminID := c.MustGet("user_id").(uint)
+
+	review, err := h.svc.Reviews.Hide(c.Request.Context(), adminID, uint(id), input.Reason)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", review, nil)
+}
+
+func (h *Handler) ShowReview(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	adminID := c.MustGet("user_id").(uint)
+
+	review, err := h.svc.Reviews.Show(c.Request.Context(), adminID, uint(id))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to UPDATE data", review, nil)
+}
+
+// --- Wishlist Handlers ---
+
+// wishlistLineKeys reads the values repository.WishlistSort sorts by from
+// the lines made of the wishlist items.
+var wishlistLineKeys = pagination.Keys[models.WishlistLine]{
+	"id":         func(l models.WishlistLine) interface{} { return l.ID },
+	"created_at": func(l models.WishlistLine) interface{} { return l.CreatedAt },
+}
+
+func (h *Handler) GetWishlist(c *gin.Context) {
+	page, err := pageQuery(c, repository.WishlistSort)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	lines, total, err := h.svc.Wishlist.List(c.Request.Context(), userID, page)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to GET data", paginated(page, lines, total, wishlistLineKeys), nil)
+}
+
+func (h *Handler) AddWishlistItem(c *gin.Context) {
+	var input models.WishlistItemRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.Error(apperror.FromBinding(err))
+		return
+	}
+	userID := c.MustGet("user_id").(uint)
+
+	line, err := h.svc.Wishlist.Add(c.Request.Context(), userID, input.ProductID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to POST data", line, nil)
+}
+
+func (h *Handler) DeleteWishlistItem(c *gin.Context) {
+	productID, _ := strconv.Atoi(c.Param("product_id"))
+	userID := c.MustGet("user_id").(uint)
+
+	if err := h.svc.Wishlist.Remove(c.Request.Context(), userID, uint(productID)); err != nil {
+		c.Error(err)
+		return
+	}
+	utils.APIResponse(c, http.StatusOK, true, "Succeed to DELETE data", "", nil)
+}
@@ -0,0 +1,242 @@
+package handler_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/handler"
+	"ecommerce-backend/internal/payment"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/service"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/apperror"
+	"ecommerce-backend/pkg/middleware"
+	"ecommerce-backend/pkg/money"
+	"ecommerce-backend/pkg/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// shop is the API on the in-memory fakes with a buyer, a seller whose
+// store sells one product, and the address of the buyer.
+type shop struct {
+	router  *gin.Engine
+	buyer   string
+	seller  string
+	product models.Product
+	address models.Address
+}
+
+func newShop(t *testing.T) *shop {
+	t.Helper()
+	ctx := context.Background()
+	repos := repository.NewMemoryRepositories()
+	svc := service.New(repos, service.Options{
+		Payments:          payment.NewRegistry(payment.NewMock("secret")),
+		PaymentDeadline:   time.Hour,
+		IdempotencyWindow: time.Hour,
+		IdempotencyLease:  time.Minute,
+	})
+	h := handler.New(svc)
+
+	buyer := models.User{Name: "Buyer", Phone: "0811", Email: "buyer@example.com"}
+	seller := models.User{Name: "Seller", Phone: "0812", Email: "seller@example.com"}
+	for _, u := range []*models.User{&buyer, &seller} {
+		must(t, repos.Users.Create(ctx, u))
+	}
+	store := models.Store{UserID: seller.ID, Name: "Toko"}
+	must(t, repos.Stores.Create(ctx, &store))
+	category := models.Category{Name: "Baju"}
+	must(t, repos.Categories.Create(ctx, &category))
+	product := models.Product{StoreID: store.ID, CategoryID: category.ID, Name: "Kaos", Slug: "kaos", ConsumerPrice: money.Rupiah(15000), Stock: 5}
+	must(t, repos.Products.Create(ctx, &product))
+	address := models.Address{UserID: buyer.ID, Title: "Rumah"}
+	must(t, repos.Addresses.Create(ctx, &address))
+
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(apperror.JSONFieldName)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.NoRoute(middleware.NotFound)
+	api := r.Group("/api/v1")
+	api.GET("/product/:id", middleware.OptionalAuth(), h.GetProductByID)
+	authorized := api.Group("/")
+	authorized.Use(middleware.AuthMiddleware())
+	authorized.PUT("/product/:id", h.UpdateProduct)
+	authorized.GET("/trx", h.GetAllTrx)
+	authorized.GET("/trx/:id", h.GetTrxByID)
+	authorized.POST("/trx", h.Idempotent(), h.CreateTrx)
+	authorized.POST("/trx/:id/cancel", h.CancelTrx)
+	authorized.GET("/toko/my/orders", h.GetStoreOrders)
+
+	return &shop{
+		router:  r,
+		buyer:   token(t, buyer.ID),
+		seller:  token(t, seller.ID),
+		product: product,
+		address: address,
+	}
+}
+
+func token(t *testing.T, userID uint) string {
+	t.Helper()
+	token, err := utils.GenerateToken(userID, false)
+	must(t, err)
+	return token
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// envelope is the response body every route answers with.
+type envelope struct {
+	Status  bool            `json:"status"`
+	Message string          `json:"message"`
+	Errors  []apperror.Item `json:"errors"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// call sends a request as the user of token, none when it is empty, and
+// decodes the response.
+func (s *shop) call(t *testing.T, method, path, token, body string) (int, envelope) {
+	t.Helper()
+	req := httptest.NewRequest(method, "/api/v1"+path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+
+	var res envelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("%s %s: %v in %s", method, path, err, rec.Body)
+	}
+	return rec.Code, res
+}
+
+// order is what the tests read of an order in a response.
+type order struct {
+	ID          uint              `json:"id"`
+	Status      string            `json:"status"`
+	InvoiceCode string            `json:"kode_invoice"`
+	TotalPrice  money.Amount      `json:"harga_total"`
+	Details     []json.RawMessage `json:"detail_trx"`
+}
+
+func (s *shop) orderBody(quantity int) string {
+	return fmt.Sprintf(`{"method_bayar":"mock","alamat_kirim":%d,"detail_trx":[{"product_id":%d,"kuantitas":%d}]}`, s.address.ID, s.product.ID, quantity)
+}
+
+func TestErrorMapping(t *testing.T) {
+	s := newShop(t)
+	_, placed := s.call(t, http.MethodPost, "/trx", s.buyer, s.orderBody(1))
+	if !placed.Status {
+		t.Fatalf("placing an order failed: %+v", placed)
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		token        string
+		body         string
+		status       int
+		code         apperror.Code
+		field        string
+	}{
+		{"no token", http.MethodGet, "/trx", "", "", http.StatusUnauthorized, apperror.CodeUnauthorized, ""},
+		{"bad token", http.MethodGet, "/trx", "not-a-token", "", http.StatusUnauthorized, apperror.CodeUnauthorized, ""},
+		{"unknown route", http.MethodGet, "/nope", "", "", http.StatusNotFound, apperror.CodeNotFound, ""},
+		{"unknown order", http.MethodGet, "/trx/999", s.buyer, "", http.StatusNotFound, apperror.CodeNotFound, ""},
+		{"order of another user", http.MethodGet, "/trx/1", s.seller, "", http.StatusForbidden, apperror.CodeForbidden, ""},
+		{"product of another store", http.MethodPut, fmt.Sprintf("/product/%d", s.product.ID), s.buyer, `{"nama_produk":"Kaos"}`, http.StatusForbidden, apperror.CodeForbidden, ""},
+		{"malformed body", http.MethodPost, "/trx", s.buyer, `{"detail_trx":`, http.StatusBadRequest, apperror.CodeBadRequest, ""},
+		{"empty body", http.MethodPost, "/trx", s.buyer, "", http.StatusBadRequest, apperror.CodeBadRequest, ""},
+		{"missing field", http.MethodPost, "/trx", s.buyer, `{"method_bayar":"mock","alamat_kirim":1}`, http.StatusBadRequest, apperror.CodeValidation, "detail_trx"},
+		{"wrong type", http.MethodPost, "/trx", s.buyer, `{"method_bayar":"mock","alamat_kirim":"rumah","detail_trx":[]}`, http.StatusBadRequest, apperror.CodeValidation, "alamat_kirim"},
+		{"unknown payment method", http.MethodPost, "/trx", s.buyer, strings.Replace(s.orderBody(1), "mock", "cash", 1), http.StatusBadRequest, apperror.CodeValidation, "method_bayar"},
+		{"out of stock", http.MethodPost, "/trx", s.buyer, s.orderBody(100), http.StatusConflict, apperror.CodeOutOfStock, ""},
+	}
+	for _, tt := range tests {
+		status, res := s.call(t, tt.method, tt.path, tt.token, tt.body)
+		if status != tt.status || res.Status || len(res.Errors) == 0 || res.Errors[0].Code != tt.code {
+			t.Errorf("%s: got %d %+v, want %d %s", tt.name, status, res, tt.status, tt.code)
+			continue
+		}
+		if tt.field != "" && res.Errors[0].Field != tt.field {
+			t.Errorf("%s: error on field %q, want %q", tt.name, res.Errors[0].Field, tt.field)
+		}
+	}
+}
+
+func TestOrderFlow(t *testing.T) {
+	s := newShop(t)
+	stock := func() int {
+		t.Helper()
+		_, res := s.call(t, http.MethodGet, fmt.Sprintf("/product/%d", s.product.ID), "", "")
+		var product models.Product
+		must(t, json.Unmarshal(res.Data, &product))
+		return product.Stock
+	}
+
+	status, res := s.call(t, http.MethodPost, "/trx", s.buyer, s.orderBody(2))
+	var placed []models.PlacedOrder
+	must(t, json.Unmarshal(res.Data, &placed))
+	if status != http.StatusOK || len(placed) != 1 || placed[0].InvoiceCode == "" {
+		t.Fatalf("place order: got %d %+v, want the order with its invoice", status, res)
+	}
+	if got := stock(); got != 3 {
+		t.Fatalf("stock after the order = %d, want 3", got)
+	}
+
+	_, res = s.call(t, http.MethodGet, "/trx", s.buyer, "")
+	var list struct {
+		Total int64   `json:"total"`
+		Data  []order `json:"data"`
+	}
+	must(t, json.Unmarshal(res.Data, &list))
+	if list.Total != 1 || len(list.Data) != 1 || list.Data[0].ID != placed[0].ID {
+		t.Fatalf("orders of the buyer = %+v, want order %d", list, placed[0].ID)
+	}
+	id := list.Data[0].ID
+
+	_, res = s.call(t, http.MethodGet, fmt.Sprintf("/trx/%d", id), s.buyer, "")
+	var trx order
+	must(t, json.Unmarshal(res.Data, &trx))
+	if trx.InvoiceCode != placed[0].InvoiceCode || trx.Status != models.TrxStatusPendingPayment || trx.TotalPrice != money.Rupiah(30000) || len(trx.Details) != 1 {
+		t.Fatalf("order = %+v, want 2 × Rp15.000 pending payment", trx)
+	}
+
+	_, res = s.call(t, http.MethodGet, "/toko/my/orders", s.seller, "")
+	must(t, json.Unmarshal(res.Data, &list))
+	if len(list.Data) != 1 || list.Data[0].ID != id {
+		t.Fatalf("orders of the store = %+v, want order %d", list.Data, id)
+	}
+
+	status, res = s.call(t, http.MethodPost, fmt.Sprintf("/trx/%d/cancel", id), s.buyer, `{"alasan":"salah ukuran"}`)
+	must(t, json.Unmarshal(res.Data, &trx))
+	if status != http.StatusOK || trx.Status != models.TrxStatusCancelled {
+		t.Fatalf("cancel: got %d %+v", status, trx)
+	}
+	if got := stock(); got != 5 {
+		t.Fatalf("stock after cancelling = %d, want 5", got)
+	}
+
+	status, res = s.call(t, http.MethodPost, fmt.Sprintf("/trx/%d/cancel", id), s.buyer, "")
+	if status != http.StatusConflict || len(res.Errors) == 0 || res.Errors[0].Code != apperror.CodeInvalidTransition {
+		t.Fatalf("cancelling twice: got %d %+v, want 409 %s", status, res, apperror.CodeInvalidTransition)
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"ecommerce-backend/pkg/apperror"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyHeader is the request header carrying the client's key.
+	IdempotencyHeader = "Idempotency-Key"
+	// ReplayedHeader marks responses replayed from an earlier request.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKey = 255
+	// maxIdempotentBody matches the memory gin uses for multipart forms.
+	maxIdempotentBody = 32 << 20
+)
+
+// Idempotent lets clients retry the route safely by sending an
+// Idempotency-Key header. The first request with a key runs as usual and a
+// successful response is stored; retries with the same key and the same
+// content get that response back without running again. Failed requests
+// free the key so they can be retried. Requests without the header are not
+// affected. It must run after AuthMiddleware, since keys are per user.
+func (h *Handler) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKey {
+			c.Error(apperror.New(apperror.CodeBadRequest, fmt.Sprintf("%s must be at most %d characters", IdempotencyHeader, maxIdempotencyKey)))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentBody+1))
+		if err != nil {
+			c.Error(apperror.Wrap(apperror.CodeBadRequest, "cannot read body", err))
+			c.Abort()
+			return
+		}
+		if len(body) > maxIdempotentBody {
+			c.Error(apperror.New(apperror.CodeBadRequest, "request body is too large"))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID := c.MustGet("user_id").(uint)
+		record, replay, err := h.svc.Idempotency.Begin(c.Request.Context(), userID, key, fingerprint(c.Request, body))
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if replay {
+			c.Header(ReplayedHeader, "true")
+			c.Data(record.StatusCode, record.ContentType, record.Response)
+			c.Abort()
+			return
+		}
+
+		// A client that gave up waiting is exactly the one that retries, so
+		// the outcome is recorded even if the request was cancelled
+		ctx := context.WithoutCancel(c.Request.Context())
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		stored := false
+		defer func() {
+			if stored {
+				return
+			}
+			if err := h.svc.Idempotency.Release(ctx, record); err != nil {
+				log.Printf("idempotency: release key of user %d: %v", userID, err)
+			}
+		}()
+
+		c.Next()
+
+		if len(c.Errors) > 0 || w.Status() >= http.StatusMultipleChoices {
+			return
+		}
+		if err := h.svc.Idempotency.Finish(ctx, record, w.Status(), w.Header().Get("Content-Type"), w.body.Bytes()); err != nil {
+			log.Printf("idempotency: store response for user %d: %v", userID, err)
+			return
+		}
+		stored = true
+	}
+}
+
+// recordingWriter keeps a copy of the response body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// fingerprint identifies what a request asks for, so a retry can be told
+// apart from a different request reusing a key. JSON bodies are compared by
+// value and multipart forms by their fields and file contents, because
+// clients may re-encode them, with a new boundary, when retrying.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "multipart/form-data" && writeMultipart(h, body, params["boundary"]):
+	case writeJSON(h, body):
+	default:
+		h.Write(body)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeJSON(h hash.Hash, body []byte) bool {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return false
+	}
+	// Marshal sorts object keys, so field order does not matter
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	h.Write(canonical)
+	return true
+}
+
+func writeMultipart(h hash.Hash, body []byte, boundary string) bool {
+	if boundary == "" {
+		return false
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	var parts []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false
+		}
+		sum := sha256.New()
+		if _, err := io.Copy(sum, p); err != nil {
+			return false
+		}
+		parts = append(parts, fmt.Sprintf("%s\x00%s\x00%x", p.FormName(), p.FileName(), sum.Sum(nil)))
+	}
+	sort.Strings(parts)
+	for _, part := range parts {
+		fmt.Fprintln(h, part)
+	}
+	return true
+}
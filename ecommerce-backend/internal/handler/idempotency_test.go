@@ -0,0 +1,109 @@
+package handler_test
+
+import (
+	"ecommerce-backend/internal/handler"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/service"
+	"ecommerce-backend/pkg/apperror"
+	"ecommerce-backend/pkg/middleware"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotentRouter serves POST /orders behind Idempotent for user 1. The
+// route fails while fail is set and counts the requests it runs.
+func idempotentRouter(fail *bool, runs *int) *gin.Engine {
+	svc := service.New(repository.NewMemoryRepositories(), service.Options{IdempotencyWindow: time.Hour, IdempotencyLease: time.Minute})
+	h := handler.New(svc)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.POST("/orders", func(c *gin.Context) { c.Set("user_id", uint(1)) }, h.Idempotent(), func(c *gin.Context) {
+		*runs++
+		if *fail {
+			c.Error(apperror.New(apperror.CodeConflict, "out of stock"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"order": *runs})
+	})
+	return r
+}
+
+func post(r *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set(handler.IdempotencyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotentReplays(t *testing.T) {
+	var fail bool
+	var runs int
+	r := idempotentRouter(&fail, &runs)
+
+	first := post(r, "k1", `{"a":1,"b":2}`)
+	// the same JSON, fields in another order
+	retry := post(r, "k1", `{"b":2, "a":1}`)
+	if runs != 1 {
+		t.Fatalf("route ran %d times, want once", runs)
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry got %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(handler.ReplayedHeader) != "true" {
+		t.Fatalf("retry is not marked as replayed")
+	}
+
+	if rec := post(r, "k2", `{"a":1,"b":2}`); rec.Code != http.StatusOK || runs != 2 {
+		t.Fatalf("another key got %d after %d runs, want it to run", rec.Code, runs)
+	}
+	post(r, "", `{}`)
+	post(r, "", `{}`)
+	if runs != 4 {
+		t.Fatalf("requests without a key ran %d times in all, want 4", runs)
+	}
+}
+
+func TestIdempotentRejectsReusedKey(t *testing.T) {
+	var fail bool
+	var runs int
+	r := idempotentRouter(&fail, &runs)
+
+	post(r, "k1", `{"a":1}`)
+	rec := post(r, "k1", `{"a":2}`)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), string(apperror.CodeIdempotencyReused)) {
+		t.Fatalf("got %d %s, want 422 %s", rec.Code, rec.Body, apperror.CodeIdempotencyReused)
+	}
+	if runs != 1 {
+		t.Fatalf("route ran %d times, want once", runs)
+	}
+}
+
+func TestIdempotentReleasesKeyOnError(t *testing.T) {
+	fail := true
+	var runs int
+	r := idempotentRouter(&fail, &runs)
+
+	if rec := post(r, "k1", `{"a":1}`); rec.Code != http.StatusConflict {
+		t.Fatalf("failing request got %d, want 409", rec.Code)
+	}
+	fail = false
+	rec := post(r, "k1", `{"a":1}`)
+	if rec.Code != http.StatusOK || rec.Header().Get(handler.ReplayedHeader) != "" {
+		t.Fatalf("retry after an error got %d %v, want it to run", rec.Code, rec.Header())
+	}
+	if want := fmt.Sprintf(`{"order":%d}`, runs); runs != 2 || rec.Body.String() != want {
+		t.Fatalf("retry got %s after %d runs, want %s", rec.Body, runs, want)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/apperror"
+	"ecommerce-backend/pkg/pagination"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clampPage applies the default page and limit of list endpoints.
+func clampPage(page, limit *int) {
+	*page, *limit = pagination.Clamp(*page, *limit)
+}
+
+// pageOf is the response of a list endpoint paged by number only.
+func pageOf(page, limit int, total int64, data interface{}) models.Pagination {
+	return models.Pagination{
+		Page:       page,
+		Limit:      limit,
+		Total:      total,
+		TotalPages: pagination.TotalPages(total, limit),
+		Data:       data,
+	}
+}
+
+// pageQuery reads the page, limit, sort and cursor of a list sortable by
+// sortable.
+func pageQuery(c *gin.Context, sortable pagination.Sortable) (pagination.Params, error) {
+	var query pagination.Query
+	if err := c.ShouldBindQuery(&query); err != nil {
+		return pagination.Params{}, apperror.FromBinding(err)
+	}
+	page, err := pagination.Parse(query, sortable)
+	var paramErr *pagination.Error
+	if errors.As(err, &paramErr) {
+		return pagination.Params{}, &apperror.Error{
+			Code:    apperror.CodeValidation,
+			Message: paramErr.Error(),
+			Fields: []apperror.FieldError{{
+				Field: paramErr.Param, Rule: paramErr.Param,
+				Message: paramErr.Message,
+			}},
+		}
+	}
+	return page, err
+}
+
+// paginated is the response of a list endpoint paged by page, with the
+// cursor of the next page carrying the sort keys of the last of rows.
+func paginated[T any](page pagination.Params, rows []T, total int64, keys pagination.Keys[T]) models.Pagination {
+	p := pageOf(page.Page, page.Limit, total, rows)
+	if page.After != nil {
+		p.Page = 0
+	}
+	p.NextCursor = pagination.Next(page, rows, keys)
+	return p
+}
@@ -0,0 +1,29 @@
+// Package jobs runs periodic background work inside the server process.
+// Jobs run on every replica, so each one must be safe to run concurrently
+// with itself.
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every calls fn every interval until ctx is done. Errors are logged and
+// the next run goes ahead as scheduled. A run that outlasts the interval
+// delays the next one instead of overlapping it.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := fn(ctx); err != nil {
+				log.Printf("job %s: %v", name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The baseline mirrors the models as they were when the service still ran
+// AutoMigrate at boot. Running it against an existing database creates any
+// missing tables, columns and indexes, which brings drifted schemas back to
+// a known starting point without touching existing data.
+
+type baselineUser struct {
+	ID         uint           `gorm:"primaryKey;column:id"`
+	Name       string         `gorm:"column:nama"`
+	Password   string         `gorm:"column:kata_sandi"`
+	Phone      string         `gorm:"unique;column:notelp"`
+	Email      string         `gorm:"unique;column:email"`
+	DOB        string         `gorm:"column:tanggal_lahir"`
+	Gender     string         `gorm:"column:jenis_kelamin"`
+	About      string         `gorm:"column:tentang"`
+	Job        string         `gorm:"column:pekerjaan"`
+	ProvinceID string         `gorm:"column:id_provinsi"`
+	CityID     string         `gorm:"column:id_kota"`
+	IsAdmin    bool           `gorm:"default:false;column:isAdmin"`
+	Store      baselineStore  `gorm:"foreignKey:UserID;references:ID"`
+	CreatedAt  time.Time      `gorm:"column:created_at"`
+	UpdatedAt  time.Time      `gorm:"column:updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index"`
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineAddress struct {
+	ID           uint      `gorm:"primaryKey;column:id"`
+	UserID       uint      `gorm:"column:id_user"`
+	Title        string    `gorm:"column:judul_alamat"`
+	ReceiverName string    `gorm:"column:nama_penerima"`
+	Phone        string    `gorm:"column:no_telp"`
+	Detail       string    `gorm:"column:detail_alamat"`
+	CreatedAt    time.Time `gorm:"column:created_at"`
+	UpdatedAt    time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineAddress) TableName() string { return "addresses" }
+
+type baselineStore struct {
+	ID        uint      `gorm:"primaryKey;column:id"`
+	UserID    uint      `gorm:"column:id_user"`
+	Name      string    `gorm:"column:nama_toko"`
+	PhotoURL  string    `gorm:"column:url_foto"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineStore) TableName() string { return "stores" }
+
+type baselineCategory struct {
+	ID        uint      `gorm:"primaryKey;column:id"`
+	Name      string    `gorm:"column:nama_category"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineCategory) TableName() string { return "categories" }
+
+type baselineProduct struct {
+	ID            uint                   `gorm:"primaryKey;column:id"`
+	StoreID       uint                   `gorm:"column:id_toko"`
+	CategoryID    uint                   `gorm:"column:id_category"`
+	Name          string                 `gorm:"column:nama_produk"`
+	Slug          string                 `gorm:"column:slug"`
+	ResellerPrice float64                `gorm:"column:harga_reseller"`
+	ConsumerPrice float64                `gorm:"column:harga_konsumen"`
+	Stock         int                    `gorm:"column:stok"`
+	Description   string                 `gorm:"column:deskripsi"`
+	Store         baselineStore          `gorm:"foreignKey:StoreID"`
+	Category      baselineCategory       `gorm:"foreignKey:CategoryID"`
+	Photos        []baselineProductPhoto `gorm:"foreignKey:ProductID"`
+	CreatedAt     time.Time              `gorm:"column:created_at"`
+	UpdatedAt     time.Time              `gorm:"column:updated_at"`
+	DeletedAt     gorm.DeletedAt         `gorm:"index"`
+}
+
+func (baselineProduct) TableName() string { return "products" }
+
+type baselineProductPhoto struct {
+	ID        uint      `gorm:"primaryKey;column:id"`
+	ProductID uint      `gorm:"column:id_produk"`
+	URL       string    `gorm:"column:url"`
+	CreatedAt time.Time `gorm:"column:created_at"`
+	UpdatedAt time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineProductPhoto) TableName() string { return "product_photos" }
+
+type baselineTransaction struct {
+	ID            uint                        `gorm:"primaryKey;column:id"`
+	UserID        uint                        `gorm:"column:id_user"`
+	AddressID     uint                        `gorm:"column:alamat_pengiriman"`
+	TotalPrice    float64                     `gorm:"column:harga_total"`
+	InvoiceCode   string                      `gorm:"column:kode_invoice"`
+	PaymentMethod string                      `gorm:"column:method_bayar"`
+	Address       baselineAddress             `gorm:"foreignKey:AddressID"`
+	Details       []baselineTransactionDetail `gorm:"foreignKey:TransactionID"`
+	CreatedAt     time.Time                   `gorm:"column:created_at"`
+	UpdatedAt     time.Time                   `gorm:"column:updated_at"`
+}
+
+func (baselineTransaction) TableName() string { return "transactions" }
+
+type baselineTransactionDetail struct {
+	ID            uint               `gorm:"primaryKey;column:id"`
+	TransactionID uint               `gorm:"column:id_trx"`
+	ProductLogID  uint               `gorm:"column:id_log_produk"`
+	StoreID       uint               `gorm:"column:id_toko"`
+	Quantity      int                `gorm:"column:kuantitas"`
+	TotalPrice    float64            `gorm:"column:harga_total"`
+	ProductLog    baselineProductLog `gorm:"foreignKey:ProductLogID"`
+	CreatedAt     time.Time          `gorm:"column:created_at"`
+	UpdatedAt     time.Time          `gorm:"column:updated_at"`
+}
+
+func (baselineTransactionDetail) TableName() string { return "transaction_details" }
+
+type baselineProductLog struct {
+	ID            uint      `gorm:"primaryKey;column:id"`
+	ProductID     uint      `gorm:"column:id_produk"`
+	StoreID       uint      `gorm:"column:id_toko"`
+	CategoryID    uint      `gorm:"column:id_category"`
+	Name          string    `gorm:"column:nama_produk"`
+	Slug          string    `gorm:"column:slug"`
+	ResellerPrice float64   `gorm:"column:harga_reseller"`
+	ConsumerPrice float64   `gorm:"column:harga_konsumen"`
+	Description   string    `gorm:"column:deskripsi"`
+	CreatedAt     time.Time `gorm:"column:created_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at"`
+}
+
+func (baselineProductLog) TableName() string { return "product_logs" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 1,
+		Name:    "baseline",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&baselineUser{},
+				&baselineAddress{},
+				&baselineStore{},
+				&baselineCategory{},
+				&baselineProduct{},
+				&baselineProductPhoto{},
+				&baselineTransaction{},
+				&baselineTransactionDetail{},
+				&baselineProductLog{},
+			)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(
+				&baselineTransactionDetail{},
+				&baselineProductLog{},
+				&baselineTransaction{},
+				&baselineProductPhoto{},
+				&baselineProduct{},
+				&baselineCategory{},
+				&baselineStore{},
+				&baselineAddress{},
+				&baselineUser{},
+			)
+		},
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Carts move from the mobile app to the server so they follow the user
+// across devices. Every user has at most one cart, holding each product at
+// most once.
+
+type cartsCart struct {
+	ID        uint            `gorm:"primaryKey;column:id"`
+	UserID    uint            `gorm:"uniqueIndex;column:id_user"`
+	User      baselineUser    `gorm:"foreignKey:UserID"`
+	Items     []cartsCartItem `gorm:"foreignKey:CartID;constraint:OnDelete:CASCADE"`
+	CreatedAt time.Time       `gorm:"column:created_at"`
+	UpdatedAt time.Time       `gorm:"column:updated_at"`
+}
+
+func (cartsCart) TableName() string { return "carts" }
+
+type cartsCartItem struct {
+	ID        uint            `gorm:"primaryKey;column:id"`
+	CartID    uint            `gorm:"uniqueIndex:idx_cart_items_product;column:id_cart"`
+	ProductID uint            `gorm:"uniqueIndex:idx_cart_items_product;column:id_produk"`
+	Quantity  int             `gorm:"column:kuantitas"`
+	Product   baselineProduct `gorm:"foreignKey:ProductID"`
+	CreatedAt time.Time       `gorm:"column:created_at"`
+	UpdatedAt time.Time       `gorm:"column:updated_at"`
+}
+
+func (cartsCartItem) TableName() string { return "cart_items" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 2,
+		Name:    "carts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&cartsCart{}, &cartsCartItem{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&cartsCartItem{}, &cartsCart{})
+		},
+	})
+}
@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Orders get a status and a history of every status change. Orders placed
+// before this migration were final as soon as they were created, so they
+// start out as paid rather than waiting for a payment that never comes.
+
+type orderStatusTransaction struct {
+	ID             uint   `gorm:"primaryKey;column:id"`
+	Status         string `gorm:"column:status;type:varchar(32);not null;default:pending_payment;index"`
+	TrackingNumber string `gorm:"column:no_resi;type:varchar(64)"`
+}
+
+func (orderStatusTransaction) TableName() string { return "transactions" }
+
+type orderStatusHistory struct {
+	ID            uint                `gorm:"primaryKey;column:id"`
+	TransactionID uint                `gorm:"column:id_trx;index"`
+	Transaction   baselineTransaction `gorm:"foreignKey:TransactionID;constraint:OnDelete:CASCADE"`
+	FromStatus    string              `gorm:"column:status_awal;type:varchar(32)"`
+	ToStatus      string              `gorm:"column:status;type:varchar(32)"`
+	ActorID       uint                `gorm:"column:id_actor"`
+	ActorRole     string              `gorm:"column:role_actor;type:varchar(16)"`
+	Note          string              `gorm:"column:catatan"`
+	CreatedAt     time.Time           `gorm:"column:created_at"`
+}
+
+func (orderStatusHistory) TableName() string { return "transaction_status_histories" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 3,
+		Name:    "order_status",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&orderStatusTransaction{}, &orderStatusHistory{}); err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE transactions SET status = ?", "paid").Error; err != nil {
+				return err
+			}
+			return tx.Exec(`INSERT INTO transaction_status_histories
+				(id_trx, status_awal, status, id_actor, role_actor, catatan, created_at)
+				SELECT id, '', 'paid', 0, 'system', 'placed before order statuses existed', created_at
+				FROM transactions`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropTable(&orderStatusHistory{}); err != nil {
+				return err
+			}
+			if m.HasIndex(&orderStatusTransaction{}, "Status") {
+				if err := m.DropIndex(&orderStatusTransaction{}, "Status"); err != nil {
+					return err
+				}
+			}
+			// Plain ALTER TABLE works on every driver; the SQLite migrator
+			// would rebuild the table, which foreign keys to it forbid
+			if err := tx.Exec("ALTER TABLE transactions DROP COLUMN no_resi").Error; err != nil {
+				return err
+			}
+			return tx.Exec("ALTER TABLE transactions DROP COLUMN status").Error
+		},
+	})
+}
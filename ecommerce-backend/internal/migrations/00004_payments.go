@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Payments record every charge created at a payment provider for an order.
+// A charge is identified by the provider and its id at that provider.
+
+type paymentsPayment struct {
+	ID            uint                `gorm:"primaryKey;column:id"`
+	TransactionID uint                `gorm:"column:id_trx;index"`
+	Transaction   baselineTransaction `gorm:"foreignKey:TransactionID"`
+	Provider      string              `gorm:"column:provider;type:varchar(32);uniqueIndex:idx_payments_charge"`
+	ChargeID      string              `gorm:"column:charge_id;type:varchar(128);uniqueIndex:idx_payments_charge"`
+	Amount        float64             `gorm:"column:jumlah"`
+	Status        string              `gorm:"column:status;type:varchar(16)"`
+	PaymentURL    string              `gorm:"column:url_pembayaran"`
+	ExpiresAt     time.Time           `gorm:"column:expired_at"`
+	PaidAt        *time.Time          `gorm:"column:paid_at"`
+	CreatedAt     time.Time           `gorm:"column:created_at"`
+	UpdatedAt     time.Time           `gorm:"column:updated_at"`
+}
+
+func (paymentsPayment) TableName() string { return "payments" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 4,
+		Name:    "payments",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&paymentsPayment{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&paymentsPayment{})
+		},
+	})
+}
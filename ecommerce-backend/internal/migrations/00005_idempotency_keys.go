@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Idempotency keys store the response of mutating requests per user so
+// retried requests can be answered without running them twice.
+
+type idempotencyKey struct {
+	ID          uint         `gorm:"primaryKey;column:id"`
+	UserID      uint         `gorm:"column:id_user;uniqueIndex:idx_idempotency_keys_user_key"`
+	User        baselineUser `gorm:"foreignKey:UserID"`
+	Key         string       `gorm:"column:idempotency_key;type:varchar(255);uniqueIndex:idx_idempotency_keys_user_key"`
+	Fingerprint string       `gorm:"column:fingerprint;type:varchar(64)"`
+	StatusCode  int          `gorm:"column:status_code"`
+	ContentType string       `gorm:"column:content_type;type:varchar(128)"`
+	Response    []byte       `gorm:"column:response"`
+	ExpiresAt   time.Time    `gorm:"column:expired_at;index"`
+	CreatedAt   time.Time    `gorm:"column:created_at"`
+}
+
+func (idempotencyKey) TableName() string { return "idempotency_keys" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 5,
+		Name:    "idempotency_keys",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&idempotencyKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&idempotencyKey{})
+		},
+	})
+}
@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Invoice numbers come from a sequence per store and day and must be
+// unique. Orders placed before this migration used the Unix time as their
+// number, so orders placed in the same second are renamed after their id
+// before the unique index is added.
+
+type invoiceTransaction struct {
+	ID          uint   `gorm:"primaryKey;column:id"`
+	InvoiceCode string `gorm:"column:kode_invoice;type:varchar(64);uniqueIndex:idx_transactions_invoice"`
+}
+
+func (invoiceTransaction) TableName() string { return "transactions" }
+
+type invoiceSequence struct {
+	Scope     string `gorm:"primaryKey;column:scope;type:varchar(64)"`
+	LastValue int64  `gorm:"column:last_value;not null"`
+}
+
+func (invoiceSequence) TableName() string { return "invoice_sequences" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 6,
+		Name:    "invoice_numbers",
+		Up: func(tx *gorm.DB) error {
+			var duplicates []invoiceTransaction
+			err := tx.Where("kode_invoice IN (?)",
+				tx.Model(&invoiceTransaction{}).Select("kode_invoice").Group("kode_invoice").Having("COUNT(*) > 1"),
+			).Order("id").Find(&duplicates).Error
+			if err != nil {
+				return err
+			}
+			seen := make(map[string]bool)
+			for _, trx := range duplicates {
+				if !seen[trx.InvoiceCode] {
+					seen[trx.InvoiceCode] = true
+					continue
+				}
+				code := fmt.Sprintf("%s-%d", trx.InvoiceCode, trx.ID)
+				if err := tx.Model(&trx).Update("kode_invoice", code).Error; err != nil {
+					return err
+				}
+			}
+
+			m := tx.Migrator()
+			// MySQL cannot index the longtext column of the baseline. SQLite
+			// ignores the length and would rebuild the table to change it
+			if tx.Dialector.Name() != "sqlite" {
+				if err := m.AlterColumn(&invoiceTransaction{}, "InvoiceCode"); err != nil {
+					return err
+				}
+			}
+			if err := m.CreateIndex(&invoiceTransaction{}, "idx_transactions_invoice"); err != nil {
+				return err
+			}
+			return m.CreateTable(&invoiceSequence{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropTable(&invoiceSequence{}); err != nil {
+				return err
+			}
+			if err := m.DropIndex(&invoiceTransaction{}, "idx_transactions_invoice"); err != nil {
+				return err
+			}
+			if tx.Dialector.Name() != "sqlite" {
+				return m.AlterColumn(&baselineTransaction{}, "InvoiceCode")
+			}
+			return nil
+		},
+	})
+}
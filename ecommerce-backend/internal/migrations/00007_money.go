@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Prices and totals move from floating point rupiah to integer sen (see
+// pkg/money). Each column is replaced by a BIGINT one holding the amount
+// rounded to the sen. Renaming, adding and dropping columns works the same
+// on every driver, where changing the type in place would make the SQLite
+// migrator rebuild tables that foreign keys point to.
+
+type moneyProduct struct {
+	ResellerPrice int64 `gorm:"column:harga_reseller;type:bigint;not null;default:0"`
+	ConsumerPrice int64 `gorm:"column:harga_konsumen;type:bigint;not null;default:0"`
+}
+
+func (moneyProduct) TableName() string { return "products" }
+
+type moneyProductLog struct {
+	ResellerPrice int64 `gorm:"column:harga_reseller;type:bigint;not null;default:0"`
+	ConsumerPrice int64 `gorm:"column:harga_konsumen;type:bigint;not null;default:0"`
+}
+
+func (moneyProductLog) TableName() string { return "product_logs" }
+
+type moneyTransaction struct {
+	TotalPrice int64 `gorm:"column:harga_total;type:bigint;not null;default:0"`
+}
+
+func (moneyTransaction) TableName() string { return "transactions" }
+
+type moneyTransactionDetail struct {
+	TotalPrice int64 `gorm:"column:harga_total;type:bigint;not null;default:0"`
+}
+
+func (moneyTransactionDetail) TableName() string { return "transaction_details" }
+
+type moneyPayment struct {
+	Amount int64 `gorm:"column:jumlah;type:bigint;not null;default:0"`
+}
+
+func (moneyPayment) TableName() string { return "payments" }
+
+// moneyColumn is one converted column, with its model before and after.
+type moneyColumn struct {
+	before, after interface{ TableName() string }
+	field, column string
+}
+
+var moneyColumns = []moneyColumn{
+	{&baselineProduct{}, &moneyProduct{}, "ResellerPrice", "harga_reseller"},
+	{&baselineProduct{}, &moneyProduct{}, "ConsumerPrice", "harga_konsumen"},
+	{&baselineProductLog{}, &moneyProductLog{}, "ResellerPrice", "harga_reseller"},
+	{&baselineProductLog{}, &moneyProductLog{}, "ConsumerPrice", "harga_konsumen"},
+	{&baselineTransaction{}, &moneyTransaction{}, "TotalPrice", "harga_total"},
+	{&baselineTransactionDetail{}, &moneyTransactionDetail{}, "TotalPrice", "harga_total"},
+	{&paymentsPayment{}, &moneyPayment{}, "Amount", "jumlah"},
+}
+
+// replaceColumn renames column out of the way, adds it again as declared by
+// model and fills it from the old values with convert, a SQL expression
+// over the old column.
+func replaceColumn(tx *gorm.DB, model interface{ TableName() string }, field, column, convert string) error {
+	table, old := model.TableName(), column+"_old"
+	if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", table, column, old)).Error; err != nil {
+		return err
+	}
+	if err := tx.Migrator().AddColumn(model, field); err != nil {
+		return err
+	}
+	update := fmt.Sprintf("UPDATE %s SET %s = "+convert, table, column, old)
+	if err := tx.Exec(update).Error; err != nil {
+		return err
+	}
+	return tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, old)).Error
+}
+
+func init() {
+	register(migrate.Migration{
+		Version: 7,
+		Name:    "money",
+		Up: func(tx *gorm.DB) error {
+			for _, c := range moneyColumns {
+				if err := replaceColumn(tx, c.after, c.field, c.column, "ROUND(%s * 100)"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, c := range moneyColumns {
+				if err := replaceColumn(tx, c.before, c.field, c.column, "%s / 100.0"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
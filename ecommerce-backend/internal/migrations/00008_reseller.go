@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+
+	"gorm.io/gorm"
+)
+
+// Users get a reseller role, orders a type telling regular, reseller and
+// dropship orders apart along with the dropshipper shown on the parcel, and
+// order lines the margin of the reseller. Existing orders are regular.
+
+type resellerUser struct {
+	IsReseller bool `gorm:"column:is_reseller;not null;default:false"`
+}
+
+func (resellerUser) TableName() string { return "users" }
+
+type resellerTransaction struct {
+	OrderType        string `gorm:"column:jenis_pesanan;type:varchar(16);not null;default:reguler"`
+	DropshipperName  string `gorm:"column:nama_dropshipper;type:varchar(100)"`
+	DropshipperPhone string `gorm:"column:telp_dropshipper;type:varchar(32)"`
+}
+
+func (resellerTransaction) TableName() string { return "transactions" }
+
+type resellerTransactionDetail struct {
+	Margin int64 `gorm:"column:margin;type:bigint;not null;default:0"`
+}
+
+func (resellerTransactionDetail) TableName() string { return "transaction_details" }
+
+// resellerColumns lists the added columns, in the order they are added.
+var resellerColumns = []struct {
+	model interface{ TableName() string }
+	field string
+	name  string
+}{
+	{&resellerUser{}, "IsReseller", "is_reseller"},
+	{&resellerTransaction{}, "OrderType", "jenis_pesanan"},
+	{&resellerTransaction{}, "DropshipperName", "nama_dropshipper"},
+	{&resellerTransaction{}, "DropshipperPhone", "telp_dropshipper"},
+	{&resellerTransactionDetail{}, "Margin", "margin"},
+}
+
+func init() {
+	register(migrate.Migration{
+		Version: 8,
+		Name:    "reseller",
+		Up: func(tx *gorm.DB) error {
+			for _, c := range resellerColumns {
+				if err := tx.Migrator().AddColumn(c.model, c.field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			// Plain ALTER TABLE, see 00003_order_status
+			for i := len(resellerColumns) - 1; i >= 0; i-- {
+				c := resellerColumns[i]
+				if err := tx.Exec("ALTER TABLE " + c.model.TableName() + " DROP COLUMN " + c.name).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
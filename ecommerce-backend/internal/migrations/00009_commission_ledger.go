@@ -0,0 +1,71 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The commission ledger of resellers: credits for completed reseller orders
+// and debits for payouts, plus the payout requests themselves. Entry
+// references are unique so nothing is ever booked twice.
+
+type ledgerPayout struct {
+	ID            uint         `gorm:"primaryKey;column:id"`
+	UserID        uint         `gorm:"column:id_user;index"`
+	User          baselineUser `gorm:"foreignKey:UserID"`
+	Amount        int64        `gorm:"column:jumlah;type:bigint;not null"`
+	BankName      string       `gorm:"column:nama_bank;type:varchar(64)"`
+	AccountNumber string       `gorm:"column:no_rekening;type:varchar(32)"`
+	AccountName   string       `gorm:"column:nama_rekening;type:varchar(100)"`
+	Status        string       `gorm:"column:status;type:varchar(16);not null;default:pending;index"`
+	Note          string       `gorm:"column:catatan"`
+	ProcessedBy   *uint        `gorm:"column:id_admin"`
+	Admin         baselineUser `gorm:"foreignKey:ProcessedBy"`
+	ProcessedAt   *time.Time   `gorm:"column:processed_at"`
+	CreatedAt     time.Time    `gorm:"column:created_at"`
+	UpdatedAt     time.Time    `gorm:"column:updated_at"`
+}
+
+func (ledgerPayout) TableName() string { return "payouts" }
+
+type ledgerEntry struct {
+	ID                  uint                      `gorm:"primaryKey;column:id"`
+	UserID              uint                      `gorm:"column:id_user;index"`
+	User                baselineUser              `gorm:"foreignKey:UserID"`
+	Type                string                    `gorm:"column:jenis;type:varchar(8);not null"`
+	Amount              int64                     `gorm:"column:jumlah;type:bigint;not null"`
+	Reference           string                    `gorm:"column:referensi;type:varchar(64);not null;uniqueIndex"`
+	TransactionID       *uint                     `gorm:"column:id_trx"`
+	Transaction         baselineTransaction       `gorm:"foreignKey:TransactionID"`
+	TransactionDetailID *uint                     `gorm:"column:id_detail_trx"`
+	TransactionDetail   baselineTransactionDetail `gorm:"foreignKey:TransactionDetailID"`
+	ProductLogID        *uint                     `gorm:"column:id_log_produk"`
+	ProductLog          baselineProductLog        `gorm:"foreignKey:ProductLogID"`
+	PayoutID            *uint                     `gorm:"column:id_payout"`
+	Payout              ledgerPayout              `gorm:"foreignKey:PayoutID"`
+	Note                string                    `gorm:"column:keterangan"`
+	CreatedAt           time.Time                 `gorm:"column:created_at"`
+}
+
+func (ledgerEntry) TableName() string { return "ledger_entries" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 9,
+		Name:    "commission_ledger",
+		Up: func(tx *gorm.DB) error {
+			// Not AutoMigrate: it would also migrate the referenced tables
+			// to their baseline snapshots, undoing 00007_money
+			return tx.Migrator().CreateTable(&ledgerPayout{}, &ledgerEntry{})
+		},
+		Down: func(tx *gorm.DB) error {
+			// One at a time: DropTable reorders the tables it is given
+			if err := tx.Migrator().DropTable(&ledgerEntry{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&ledgerPayout{})
+		},
+	})
+}
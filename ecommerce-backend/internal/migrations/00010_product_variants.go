@@ -0,0 +1,125 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Products get options such as size and color, and variants combining their
+// values with their own SKU, prices, stock and photo. Order lines snapshot
+// the variant they sold and cart lines hold a product once per variant, 0
+// standing for none.
+
+type variantOption struct {
+	ID        uint            `gorm:"primaryKey;column:id"`
+	ProductID uint            `gorm:"column:id_produk;index"`
+	Product   baselineProduct `gorm:"foreignKey:ProductID"`
+	Position  int             `gorm:"column:urutan;not null;default:0"`
+	Name      string          `gorm:"column:nama;type:varchar(32);not null"`
+	Values    string          `gorm:"column:nilai;type:text"`
+	CreatedAt time.Time       `gorm:"column:created_at"`
+	UpdatedAt time.Time       `gorm:"column:updated_at"`
+}
+
+func (variantOption) TableName() string { return "product_options" }
+
+type variantVariant struct {
+	ID            uint                 `gorm:"primaryKey;column:id"`
+	ProductID     uint                 `gorm:"column:id_produk;index"`
+	Product       baselineProduct      `gorm:"foreignKey:ProductID"`
+	SKU           string               `gorm:"column:sku;type:varchar(64);not null;uniqueIndex"`
+	Name          string               `gorm:"column:nama_varian;type:varchar(100)"`
+	Values        string               `gorm:"column:nilai_opsi;type:text"`
+	ResellerPrice int64                `gorm:"column:harga_reseller;type:bigint;not null;default:0"`
+	ConsumerPrice int64                `gorm:"column:harga_konsumen;type:bigint;not null;default:0"`
+	Stock         int                  `gorm:"column:stok;not null;default:0"`
+	PhotoID       *uint                `gorm:"column:id_foto"`
+	Photo         baselineProductPhoto `gorm:"foreignKey:PhotoID;constraint:OnDelete:SET NULL"`
+	CreatedAt     time.Time            `gorm:"column:created_at"`
+	UpdatedAt     time.Time            `gorm:"column:updated_at"`
+	DeletedAt     gorm.DeletedAt       `gorm:"index"`
+}
+
+func (variantVariant) TableName() string { return "product_variants" }
+
+type variantProductLog struct {
+	VariantID   *uint  `gorm:"column:id_varian"`
+	SKU         string `gorm:"column:sku;type:varchar(64)"`
+	VariantName string `gorm:"column:nama_varian;type:varchar(100)"`
+}
+
+func (variantProductLog) TableName() string { return "product_logs" }
+
+type variantCartItem struct {
+	CartID    uint `gorm:"uniqueIndex:idx_cart_items_product;column:id_cart"`
+	ProductID uint `gorm:"uniqueIndex:idx_cart_items_product;column:id_produk"`
+	VariantID uint `gorm:"uniqueIndex:idx_cart_items_product;column:id_varian;not null;default:0"`
+}
+
+func (variantCartItem) TableName() string { return "cart_items" }
+
+// variantLogColumns lists the columns added to product_logs, in order.
+var variantLogColumns = []struct {
+	field string
+	name  string
+}{
+	{"VariantID", "id_varian"},
+	{"SKU", "sku"},
+	{"VariantName", "nama_varian"},
+}
+
+func init() {
+	register(migrate.Migration{
+		Version: 10,
+		Name:    "product_variants",
+		Up: func(tx *gorm.DB) error {
+			// Not AutoMigrate, see 00009_commission_ledger
+			if err := tx.Migrator().CreateTable(&variantOption{}, &variantVariant{}); err != nil {
+				return err
+			}
+			for _, c := range variantLogColumns {
+				if err := tx.Migrator().AddColumn(&variantProductLog{}, c.field); err != nil {
+					return err
+				}
+			}
+
+			// The unique index of cart lines gains the variant
+			if err := tx.Migrator().DropIndex(&cartsCartItem{}, "idx_cart_items_product"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&variantCartItem{}, "VariantID"); err != nil {
+				return err
+			}
+			return tx.Migrator().CreateIndex(&variantCartItem{}, "idx_cart_items_product")
+		},
+		Down: func(tx *gorm.DB) error {
+			// Lines of variants would break the unique index of 00002_carts
+			if err := tx.Exec("DELETE FROM cart_items WHERE id_varian <> 0").Error; err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropIndex(&variantCartItem{}, "idx_cart_items_product"); err != nil {
+				return err
+			}
+			// Plain ALTER TABLE, see 00003_order_status
+			if err := tx.Exec("ALTER TABLE cart_items DROP COLUMN id_varian").Error; err != nil {
+				return err
+			}
+			if err := tx.Migrator().CreateIndex(&cartsCartItem{}, "idx_cart_items_product"); err != nil {
+				return err
+			}
+
+			for i := len(variantLogColumns) - 1; i >= 0; i-- {
+				if err := tx.Exec("ALTER TABLE product_logs DROP COLUMN " + variantLogColumns[i].name).Error; err != nil {
+					return err
+				}
+			}
+			// One at a time, see 00009_commission_ledger
+			if err := tx.Migrator().DropTable(&variantVariant{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&variantOption{})
+		},
+	})
+}
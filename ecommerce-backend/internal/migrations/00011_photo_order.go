@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+
+	"gorm.io/gorm"
+)
+
+// Product photos get an explicit order, the first being the primary image.
+// Existing photos keep the order they were uploaded in: their id serves as
+// position, as only the relative order matters.
+
+type photoOrderPhoto struct {
+	Position int `gorm:"column:urutan;not null;default:0"`
+}
+
+func (photoOrderPhoto) TableName() string { return "product_photos" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 11,
+		Name:    "photo_order",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&photoOrderPhoto{}, "Position"); err != nil {
+				return err
+			}
+			return tx.Exec("UPDATE product_photos SET urutan = id").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			// Plain ALTER TABLE, see 00003_order_status
+			return tx.Exec("ALTER TABLE product_photos DROP COLUMN urutan").Error
+		},
+	})
+}
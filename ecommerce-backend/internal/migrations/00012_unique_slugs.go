@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"ecommerce-backend/pkg/slug"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Product slugs become unique and renamed products keep their old slugs so
+// old storefront URLs redirect. Slugs stored before this migration only had
+// their spaces replaced, so every product, deleted ones included, gets its
+// slug made again in id order, the first product keeping a shared slug. Old
+// slugs that changed and are not taken by the new ones go to the history.
+
+type slugProduct struct {
+	ID   uint   `gorm:"primaryKey;column:id"`
+	Name string `gorm:"column:nama_produk"`
+	Slug string `gorm:"column:slug;type:varchar(100);uniqueIndex:idx_products_slug"`
+}
+
+func (slugProduct) TableName() string { return "products" }
+
+type slugHistory struct {
+	ID        uint            `gorm:"primaryKey;column:id"`
+	ProductID uint            `gorm:"column:id_produk;index"`
+	Product   baselineProduct `gorm:"foreignKey:ProductID"`
+	Slug      string          `gorm:"column:slug;type:varchar(100);not null;uniqueIndex"`
+	CreatedAt time.Time       `gorm:"column:created_at"`
+}
+
+func (slugHistory) TableName() string { return "product_slugs" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 12,
+		Name:    "unique_slugs",
+		Up: func(tx *gorm.DB) error {
+			var products []slugProduct
+			if err := tx.Order("id").Find(&products).Error; err != nil {
+				return err
+			}
+			taken := make(map[string]bool)
+			slugs := make([]string, len(products))
+			for i, p := range products {
+				base := slug.Make(p.Name)
+				if base == "" {
+					// As the product service names them
+					base = "produk"
+				}
+				s := base
+				for n := 2; taken[s]; n++ {
+					s = slug.WithSuffix(base, n)
+				}
+				taken[s] = true
+				slugs[i] = s
+			}
+
+			var history []slugHistory
+			for i, p := range products {
+				old := p.Slug
+				if old == slugs[i] {
+					continue
+				}
+				if err := tx.Model(&p).Update("slug", slugs[i]).Error; err != nil {
+					return err
+				}
+				if old != "" && !taken[old] {
+					taken[old] = true
+					history = append(history, slugHistory{ProductID: p.ID, Slug: old})
+				}
+			}
+
+			m := tx.Migrator()
+			// Not AutoMigrate, see 00009_commission_ledger
+			if err := m.CreateTable(&slugHistory{}); err != nil {
+				return err
+			}
+			if len(history) > 0 {
+				if err := tx.Create(&history).Error; err != nil {
+					return err
+				}
+			}
+			// As for invoice numbers, see 00006_invoice_numbers
+			if tx.Dialector.Name() != "sqlite" {
+				if err := m.AlterColumn(&slugProduct{}, "Slug"); err != nil {
+					return err
+				}
+			}
+			return m.CreateIndex(&slugProduct{}, "idx_products_slug")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&slugProduct{}, "idx_products_slug"); err != nil {
+				return err
+			}
+			if tx.Dialector.Name() != "sqlite" {
+				if err := m.AlterColumn(&baselineProduct{}, "Slug"); err != nil {
+					return err
+				}
+			}
+			return m.DropTable(&slugHistory{})
+		},
+	})
+}
@@ -0,0 +1,112 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Buyers review the lines of their completed orders, once per line, with
+// photos; sellers reply and admins hide reviews. Products and stores keep
+// the count, sum and average of the ratings of their visible reviews, so
+// lists can be sorted by rating without aggregating reviews.
+
+type reviewReview struct {
+	ID                  uint                      `gorm:"primaryKey;column:id"`
+	TransactionDetailID uint                      `gorm:"column:id_detail_trx;not null;uniqueIndex"`
+	TransactionDetail   baselineTransactionDetail `gorm:"foreignKey:TransactionDetailID"`
+	ProductLogID        uint                      `gorm:"column:id_log_produk;not null"`
+	ProductLog          baselineProductLog        `gorm:"foreignKey:ProductLogID"`
+	ProductID           uint                      `gorm:"column:id_produk;index"`
+	Product             baselineProduct           `gorm:"foreignKey:ProductID"`
+	StoreID             uint                      `gorm:"column:id_toko;index"`
+	Store               baselineStore             `gorm:"foreignKey:StoreID"`
+	UserID              uint                      `gorm:"column:id_user;index"`
+	User                baselineUser              `gorm:"foreignKey:UserID"`
+	Rating              int                       `gorm:"column:rating;not null"`
+	Text                string                    `gorm:"column:ulasan;type:text"`
+	Reply               string                    `gorm:"column:balasan;type:text"`
+	RepliedAt           *time.Time                `gorm:"column:replied_at"`
+	Hidden              bool                      `gorm:"column:disembunyikan;not null;default:false"`
+	HiddenReason        string                    `gorm:"column:alasan_disembunyikan;type:varchar(255)"`
+	HiddenBy            *uint                     `gorm:"column:id_admin"`
+	Admin               baselineUser              `gorm:"foreignKey:HiddenBy"`
+	CreatedAt           time.Time                 `gorm:"column:created_at"`
+	UpdatedAt           time.Time                 `gorm:"column:updated_at"`
+}
+
+func (reviewReview) TableName() string { return "reviews" }
+
+type reviewPhoto struct {
+	ID        uint         `gorm:"primaryKey;column:id"`
+	ReviewID  uint         `gorm:"column:id_ulasan;index"`
+	Review    reviewReview `gorm:"foreignKey:ReviewID"`
+	URL       string       `gorm:"column:url"`
+	CreatedAt time.Time    `gorm:"column:created_at"`
+}
+
+func (reviewPhoto) TableName() string { return "review_photos" }
+
+type reviewProduct struct {
+	Rating      float64 `gorm:"column:rating;not null;default:0"`
+	RatingCount int     `gorm:"column:jumlah_ulasan;not null;default:0"`
+	RatingTotal int64   `gorm:"column:total_rating;type:bigint;not null;default:0"`
+}
+
+func (reviewProduct) TableName() string { return "products" }
+
+type reviewStore struct {
+	Rating      float64 `gorm:"column:rating;not null;default:0"`
+	RatingCount int     `gorm:"column:jumlah_ulasan;not null;default:0"`
+	RatingTotal int64   `gorm:"column:total_rating;type:bigint;not null;default:0"`
+}
+
+func (reviewStore) TableName() string { return "stores" }
+
+// reviewColumns lists the added columns, in the order they are added.
+var reviewColumns = []struct {
+	model interface{ TableName() string }
+	field string
+	name  string
+}{
+	{&reviewProduct{}, "Rating", "rating"},
+	{&reviewProduct{}, "RatingCount", "jumlah_ulasan"},
+	{&reviewProduct{}, "RatingTotal", "total_rating"},
+	{&reviewStore{}, "Rating", "rating"},
+	{&reviewStore{}, "RatingCount", "jumlah_ulasan"},
+	{&reviewStore{}, "RatingTotal", "total_rating"},
+}
+
+func init() {
+	register(migrate.Migration{
+		Version: 13,
+		Name:    "reviews",
+		Up: func(tx *gorm.DB) error {
+			for _, c := range reviewColumns {
+				if err := tx.Migrator().AddColumn(c.model, c.field); err != nil {
+					return err
+				}
+			}
+			// Not AutoMigrate, see 00009_commission_ledger
+			return tx.Migrator().CreateTable(&reviewReview{}, &reviewPhoto{})
+		},
+		Down: func(tx *gorm.DB) error {
+			// One at a time, see 00009_commission_ledger
+			if err := tx.Migrator().DropTable(&reviewPhoto{}); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropTable(&reviewReview{}); err != nil {
+				return err
+			}
+			// Plain ALTER TABLE, see 00003_order_status
+			for i := len(reviewColumns) - 1; i >= 0; i-- {
+				c := reviewColumns[i]
+				if err := tx.Exec("ALTER TABLE " + c.model.TableName() + " DROP COLUMN " + c.name).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Buyers save products to a wishlist, each product at most once, along
+// with its price at the time so price drops can be shown.
+
+type wishlistItem struct {
+	ID         uint            `gorm:"primaryKey;column:id"`
+	UserID     uint            `gorm:"uniqueIndex:idx_wishlist_items_product;column:id_user"`
+	User       baselineUser    `gorm:"foreignKey:UserID"`
+	ProductID  uint            `gorm:"uniqueIndex:idx_wishlist_items_product;column:id_produk"`
+	Product    baselineProduct `gorm:"foreignKey:ProductID"`
+	SavedPrice int64           `gorm:"column:harga_disimpan;type:bigint;not null;default:0"`
+	CreatedAt  time.Time       `gorm:"column:created_at"`
+}
+
+func (wishlistItem) TableName() string { return "wishlist_items" }
+
+func init() {
+	register(migrate.Migration{
+		Version: 14,
+		Name:    "wishlist",
+		Up: func(tx *gorm.DB) error {
+			// Not AutoMigrate, see 00009_commission_ledger
+			return tx.Migrator().CreateTable(&wishlistItem{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&wishlistItem{})
+		},
+	})
+}
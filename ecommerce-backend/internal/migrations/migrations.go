@@ -0,0 +1,24 @@
+// Package migrations holds the numbered schema migrations of the service.
+//
+// Each file NNNNN_name.go registers one migration with an Up and a Down
+// function. Migrations declare their own snapshot structs instead of using
+// the models package, so later changes to models do not rewrite history.
+package migrations
+
+import (
+	"ecommerce-backend/pkg/migrate"
+	"sort"
+)
+
+var registry []migrate.Migration
+
+func register(m migrate.Migration) {
+	registry = append(registry, m)
+}
+
+// All returns every registered migration ordered by version.
+func All() []migrate.Migration {
+	all := append([]migrate.Migration(nil), registry...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
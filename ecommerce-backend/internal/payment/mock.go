@@ -0,0 +1,136 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"ecommerce-backend/pkg/money"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MockSignatureHeader carries the hex HMAC-SHA256 of the webhook body.
+const MockSignatureHeader = "X-Mock-Signature"
+
+// Mock is a local provider for development and tests. It keeps charges in
+// memory and only changes them when told to through Simulate.
+type Mock struct {
+	secret []byte
+
+	mu      sync.Mutex
+	charges map[string]Charge
+}
+
+func NewMock(secret string) *Mock {
+	return &Mock{secret: []byte(secret), charges: make(map[string]Charge)}
+}
+
+func (m *Mock) Name() string { return "mock" }
+
+func (m *Mock) CreateCharge(ctx context.Context, req ChargeRequest) (Charge, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return Charge{}, err
+	}
+	charge := Charge{
+		ID:        "mock_" + hex.EncodeToString(id),
+		Status:    StatusPending,
+		Amount:    req.Amount,
+		ExpiresAt: req.ExpiresAt,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.charges[charge.ID] = charge
+	return charge, nil
+}
+
+func (m *Mock) Status(ctx context.Context, chargeID string) (Charge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	charge, ok := m.charges[chargeID]
+	if !ok {
+		return Charge{}, ErrChargeNotFound
+	}
+	if charge.Status == StatusPending && !charge.ExpiresAt.IsZero() && time.Now().After(charge.ExpiresAt) {
+		charge.Status = StatusExpired
+		m.charges[chargeID] = charge
+	}
+	return charge, nil
+}
+
+func (m *Mock) Refund(ctx context.Context, chargeID string, amount money.Amount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	charge, ok := m.charges[chargeID]
+	if !ok {
+		return ErrChargeNotFound
+	}
+	if charge.Status != StatusPaid {
+		return fmt.Errorf("mock: cannot refund a %s charge", charge.Status)
+	}
+	charge.Status = StatusRefunded
+	m.charges[chargeID] = charge
+	return nil
+}
+
+type mockWebhook struct {
+	ChargeID string       `json:"charge_id"`
+	Status   Status       `json:"status"`
+	Amount   money.Amount `json:"amount"`
+}
+
+func (m *Mock) ParseWebhook(header http.Header, body []byte) (Event, error) {
+	got, err := hex.DecodeString(header.Get(MockSignatureHeader))
+	if err != nil || !hmac.Equal(got, m.sign(body)) {
+		return Event{}, ErrInvalidSignature
+	}
+
+	var w mockWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return Event{}, fmt.Errorf("mock: decode webhook: %w", err)
+	}
+	return Event{ChargeID: w.ChargeID, Status: w.Status, Amount: w.Amount}, nil
+}
+
+// Simulate settles a pending charge with outcome (paid, failed or expired)
+// and returns the signed webhook a real gateway would send for it.
+func (m *Mock) Simulate(chargeID string, outcome Status) (body []byte, signature string, err error) {
+	switch outcome {
+	case StatusPaid, StatusFailed, StatusExpired:
+	default:
+		return nil, "", fmt.Errorf("mock: cannot simulate %q", outcome)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	charge, ok := m.charges[chargeID]
+	if !ok {
+		return nil, "", ErrChargeNotFound
+	}
+	if charge.Status != StatusPending {
+		return nil, "", fmt.Errorf("mock: charge is already %s", charge.Status)
+	}
+	charge.Status = outcome
+	m.charges[chargeID] = charge
+
+	body, err = json.Marshal(mockWebhook{ChargeID: chargeID, Status: outcome, Amount: charge.Amount})
+	if err != nil {
+		return nil, "", err
+	}
+	return body, hex.EncodeToString(m.sign(body)), nil
+}
+
+func (m *Mock) sign(body []byte) []byte {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
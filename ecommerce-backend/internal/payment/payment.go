@@ -0,0 +1,98 @@
+// Package payment abstracts payment gateways. Each gateway implements
+// Provider and is registered under the name buyers send as method_bayar, so
+// adding a real gateway does not touch the order flow.
+package payment
+
+import (
+	"context"
+	"ecommerce-backend/pkg/money"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned for webhooks that fail verification.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrChargeNotFound is returned when a provider does not know a charge.
+	ErrChargeNotFound = errors.New("charge not found")
+)
+
+// Status is the state of a charge, shared by every provider.
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusPaid     Status = "paid"
+	StatusFailed   Status = "failed"
+	StatusExpired  Status = "expired"
+	StatusRefunded Status = "refunded"
+)
+
+// ChargeRequest asks a provider to collect Amount for an order.
+type ChargeRequest struct {
+	TransactionID uint
+	// Reference is shown to the buyer and in the gateway dashboard,
+	// usually the invoice code.
+	Reference string
+	Amount    money.Amount
+	ExpiresAt time.Time
+}
+
+// Charge is a provider's view of one payment attempt.
+type Charge struct {
+	ID     string
+	Status Status
+	Amount money.Amount
+	// PaymentURL is where the buyer completes the payment, if the gateway
+	// has a hosted page.
+	PaymentURL string
+	ExpiresAt  time.Time
+}
+
+// Event is a verified webhook notification about a charge.
+type Event struct {
+	ChargeID string
+	Status   Status
+	Amount   money.Amount
+}
+
+type Provider interface {
+	Name() string
+	CreateCharge(ctx context.Context, req ChargeRequest) (Charge, error)
+	// Status queries the current state of a charge.
+	Status(ctx context.Context, chargeID string) (Charge, error)
+	Refund(ctx context.Context, chargeID string, amount money.Amount) error
+	// ParseWebhook verifies the signature of a notification and decodes
+	// it. It returns ErrInvalidSignature for forged or tampered requests.
+	ParseWebhook(header http.Header, body []byte) (Event, error)
+}
+
+// Registry holds the enabled providers by name.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func NewRegistry(providers ...Provider) *Registry {
+	r := &Registry{providers: make(map[string]Provider)}
+	for _, p := range providers {
+		r.providers[p.Name()] = p
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+// Names returns the registered provider names in order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
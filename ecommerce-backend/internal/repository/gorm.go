@@ -0,0 +1,1210 @@
+package repository
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/invoice"
+	"ecommerce-backend/pkg/money"
+	"ecommerce-backend/pkg/pagination"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGormRepositories returns repositories backed by db.
+func NewGormRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:        &gormUserRepository{db: db},
+		Addresses:    &gormAddressRepository{db: db},
+		Stores:       &gormStoreRepository{db: db},
+		Categories:   &gormCategoryRepository{db: db},
+		Products:     &gormProductRepository{db: db},
+		Transactions: &gormTransactionRepository{db: db},
+		Carts:        &gormCartRepository{db: db},
+		Payments:     &gormPaymentRepository{db: db},
+		Idempotency:  &gormIdempotencyRepository{db: db},
+		Ledger:       &gormLedgerRepository{db: db},
+		Reviews:      &gormReviewRepository{db: db},
+		Wishlist:     &gormWishlistRepository{db: db},
+	}
+}
+
+// translate maps GORM's errors to the repository errors. It relies on
+// gorm.Config.TranslateError for duplicate keys.
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
+}
+
+// paged orders query by the rows of table and selects the page of them:
+// by offset, or as the rows after the values of the cursor in that order.
+// The cursor carries the values of its row, so paging goes on after the row
+// is deleted. Sort columns come from a pagination.Sortable, never from
+// clients.
+func paged(query *gorm.DB, table string, page pagination.Params) *gorm.DB {
+	order := page.Order()
+	for _, key := range order {
+		query = query.Order(clause.OrderByColumn{Column: clause.Column{Table: table, Name: key.Column}, Desc: key.Desc})
+	}
+	if page.After == nil {
+		return query.Offset(page.Offset()).Limit(page.Limit)
+	}
+
+	// (a > a0) OR (a = a0 AND b > b0) OR ..., with < for descending keys
+	var after []string
+	var args []interface{}
+	for i, key := range order {
+		var terms []string
+		for j, prev := range order[:i+1] {
+			op := "="
+			if j == i {
+				op = ">"
+				if key.Desc {
+					op = "<"
+				}
+			}
+			terms = append(terms, fmt.Sprintf("%s.%s %s ?", table, prev.Column, op))
+			args = append(args, page.After[j])
+		}
+		after = append(after, "("+strings.Join(terms, " AND ")+")")
+	}
+	return query.Where(strings.Join(after, " OR "), args...).Limit(page.Limit)
+}
+
+// User Repository
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepository) Create(ctx context.Context, user *models.User) error {
+	return translate(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r *gormUserRepository) FindByPhone(ctx context.Context, phone string) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("notelp = ?", phone).First(&user).Error
+	return user, translate(err)
+}
+
+func (r *gormUserRepository) FindByID(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Preload("Store").First(&user, id).Error
+	return user, translate(err)
+}
+
+func (r *gormUserRepository) Update(ctx context.Context, user *models.User) error {
+	return translate(r.db.WithContext(ctx).Save(user).Error)
+}
+
+// Address Repository
+type gormAddressRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormAddressRepository) ListByUserID(ctx context.Context, userID uint) ([]models.Address, error) {
+	var addresses []models.Address
+	err := r.db.WithContext(ctx).Where("id_user = ?", userID).Find(&addresses).Error
+	return addresses, err
+}
+
+func (r *gormAddressRepository) FindByID(ctx context.Context, id uint) (models.Address, error) {
+	var address models.Address
+	err := r.db.WithContext(ctx).First(&address, id).Error
+	return address, translate(err)
+}
+
+func (r *gormAddressRepository) Create(ctx context.Context, address *models.Address) error {
+	return r.db.WithContext(ctx).Create(address).Error
+}
+
+func (r *gormAddressRepository) Update(ctx context.Context, address *models.Address) error {
+	return r.db.WithContext(ctx).Save(address).Error
+}
+
+func (r *gormAddressRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Address{}, id).Error
+}
+
+// Store Repository
+type gormStoreRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormStoreRepository) FindByUserID(ctx context.Context, userID uint) (models.Store, error) {
+	var store models.Store
+	err := r.db.WithContext(ctx).Where("id_user = ?", userID).First(&store).Error
+	return store, translate(err)
+}
+
+func (r *gormStoreRepository) FindByID(ctx context.Context, id uint) (models.Store, error) {
+	var store models.Store
+	err := r.db.WithContext(ctx).First(&store, id).Error
+	return store, translate(err)
+}
+
+func (r *gormStoreRepository) Create(ctx context.Context, store *models.Store) error {
+	return r.db.WithContext(ctx).Create(store).Error
+}
+
+func (r *gormStoreRepository) Update(ctx context.Context, store *models.Store) error {
+	return r.db.WithContext(ctx).Omit("rating", "jumlah_ulasan", "total_rating").Save(store).Error
+}
+
+func (r *gormStoreRepository) List(ctx context.Context, filter StoreFilter) ([]models.Store, int64, error) {
+	var stores []models.Store
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Store{})
+	if filter.Name != "" {
+		query = query.Where("LOWER(nama_toko) LIKE ?", "%"+strings.ToLower(filter.Name)+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := paged(query, "stores", filter.Page).Find(&stores).Error
+	return stores, total, err
+}
+
+// Category Repository
+type gormCategoryRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormCategoryRepository) List(ctx context.Context) ([]models.Category, error) {
+	var categories []models.Category
+	err := r.db.WithContext(ctx).Find(&categories).Error
+	return categories, err
+}
+
+func (r *gormCategoryRepository) FindByID(ctx context.Context, id uint) (models.Category, error) {
+	var category models.Category
+	err := r.db.WithContext(ctx).First(&category, id).Error
+	return category, translate(err)
+}
+
+func (r *gormCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	return r.db.WithContext(ctx).Create(category).Error
+}
+
+func (r *gormCategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	return r.db.WithContext(ctx).Save(category).Error
+}
+
+func (r *gormCategoryRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Category{}, id).Error
+}
+
+// Product Repository
+type gormProductRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormProductRepository) List(ctx context.Context, filter ProductFilter) ([]models.Product, int64, error) {
+	var products []models.Product
+	var total int64
+
+	query := withProductRelations(r.db.WithContext(ctx).Model(&models.Product{}))
+
+	if filter.CategoryID != "" {
+		query = query.Where("id_category = ?", filter.CategoryID)
+	}
+	if filter.StoreID != "" {
+		query = query.Where("id_toko = ?", filter.StoreID)
+	}
+	if filter.MaxPrice != nil {
+		query = query.Where("harga_konsumen <= ?", *filter.MaxPrice)
+	}
+	if filter.MinPrice != nil {
+		query = query.Where("harga_konsumen >= ?", *filter.MinPrice)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := paged(query, "products", filter.Page).Find(&products).Error
+	return products, total, err
+}
+
+func (r *gormProductRepository) FindByID(ctx context.Context, id uint) (models.Product, error) {
+	var product models.Product
+	err := withProductRelations(r.db.WithContext(ctx)).First(&product, id).Error
+	return product, translate(err)
+}
+
+func (r *gormProductRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Product, error) {
+	products := []models.Product{}
+	if len(ids) == 0 {
+		return products, nil
+	}
+	err := withProductRelations(r.db.WithContext(ctx)).Where("id IN ?", ids).Find(&products).Error
+	return products, err
+}
+
+func (r *gormProductRepository) ChangedSince(ctx context.Context, since time.Time) ([]models.Product, []uint, error) {
+	changed := []models.Product{}
+	err := withProductRelations(r.db.WithContext(ctx)).Where("updated_at >= ?", since).Order("id").Find(&changed).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	var deleted []uint
+	err = r.db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("deleted_at >= ?", since).Order("id").Pluck("id", &deleted).Error
+	return changed, deleted, err
+}
+
+func (r *gormProductRepository) FindBySlug(ctx context.Context, slug string) (models.Product, error) {
+	var product models.Product
+	err := withProductRelations(r.db.WithContext(ctx)).Where("slug = ?", slug).First(&product).Error
+	return product, translate(err)
+}
+
+func (r *gormProductRepository) FindPreviousSlug(ctx context.Context, slug string) (models.ProductSlug, error) {
+	var previous models.ProductSlug
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&previous).Error
+	return previous, translate(err)
+}
+
+func (r *gormProductRepository) SlugTaken(ctx context.Context, slug string, productID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.Product{}).
+		Where("slug = ? AND id <> ?", slug, productID).Count(&count).Error
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+	err = r.db.WithContext(ctx).Model(&models.ProductSlug{}).
+		Where("slug = ? AND id_produk <> ?", slug, productID).Count(&count).Error
+	return count > 0, err
+}
+
+// orderPhotos sorts photos as they are shown, the primary one first.
+func orderPhotos(db *gorm.DB) *gorm.DB {
+	return db.Order("urutan, id")
+}
+
+// withProductRelations preloads what List and FindByID return: the store,
+// category and photos, and the option and variant matrix.
+func withProductRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Store").Preload("Category").Preload("Photos", orderPhotos).
+		Preload("Options", func(db *gorm.DB) *gorm.DB { return db.Order("urutan") }).
+		Preload("Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Variants.Photo")
+}
+
+func (r *gormProductRepository) Create(ctx context.Context, product *models.Product) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return translate(tx.Create(product).Error)
+	})
+}
+
+func (r *gormProductRepository) Update(ctx context.Context, id uint, changes ProductChanges) error {
+	updates := make(map[string]interface{})
+	if changes.Name != nil {
+		updates["nama_produk"] = *changes.Name
+	}
+	if changes.Slug != nil {
+		updates["slug"] = *changes.Slug
+	}
+	if changes.CategoryID != nil {
+		updates["id_category"] = *changes.CategoryID
+	}
+	if changes.ResellerPrice != nil {
+		updates["harga_reseller"] = *changes.ResellerPrice
+	}
+	if changes.ConsumerPrice != nil {
+		updates["harga_konsumen"] = *changes.ConsumerPrice
+	}
+	if changes.Stock != nil {
+		updates["stok"] = *changes.Stock
+	}
+	if changes.Description != nil {
+		updates["deskripsi"] = *changes.Description
+	}
+	if len(updates) == 0 && len(changes.PhotoURLs) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if changes.Slug != nil {
+			if err := moveSlug(tx, id, *changes.Slug); err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&models.Product{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return translate(err)
+			}
+		}
+		return addPhotos(tx, id, changes.PhotoURLs)
+	})
+}
+
+// addPhotos stores urls as photos of product id after its last one.
+func addPhotos(tx *gorm.DB, id uint, urls []string) error {
+	if len(urls) == 0 {
+		return nil
+	}
+	var last int
+	err := tx.Model(&models.ProductPhoto{}).Where("id_produk = ?", id).
+		Select("COALESCE(MAX(urutan), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+	photos := make([]models.ProductPhoto, len(urls))
+	for i, url := range urls {
+		photos[i] = models.ProductPhoto{ProductID: id, URL: url, Position: last + i + 1}
+	}
+	return tx.Create(&photos).Error
+}
+
+// moveSlug keeps the current slug of product id in its history when the
+// product changes to slug, and takes slug out of the history in case the
+// product had it before.
+func moveSlug(tx *gorm.DB, id uint, slug string) error {
+	var product models.Product
+	if err := tx.Select("id", "slug").First(&product, id).Error; err != nil {
+		return translate(err)
+	}
+	if product.Slug == slug {
+		return nil
+	}
+	if err := tx.Where("id_produk = ? AND slug = ?", id, slug).Delete(&models.ProductSlug{}).Error; err != nil {
+		return err
+	}
+	if product.Slug == "" {
+		return nil
+	}
+	return translate(tx.Create(&models.ProductSlug{ProductID: id, Slug: product.Slug}).Error)
+}
+
+func (r *gormProductRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.Product{}, id).Error
+}
+
+func (r *gormProductRepository) DeletePhoto(ctx context.Context, productID, photoID uint) error {
+	res := r.db.WithContext(ctx).Where("id = ? AND id_produk = ?", photoID, productID).Delete(&models.ProductPhoto{})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return res.Error
+}
+
+func (r *gormProductRepository) DeletePhotos(ctx context.Context, productID uint) error {
+	return r.db.WithContext(ctx).Where("id_produk = ?", productID).Delete(&models.ProductPhoto{}).Error
+}
+
+func (r *gormProductRepository) ReorderPhotos(ctx context.Context, productID uint, ids []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			res := tx.Model(&models.ProductPhoto{}).Where("id = ? AND id_produk = ?", id, productID).Update("urutan", i+1)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrNotFound
+			}
+		}
+		return nil
+	})
+}
+
+func (r *gormProductRepository) SetVariants(ctx context.Context, productID uint, options []models.ProductOption, variants []models.ProductVariant) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock the product so orders wait for the new stock
+		var product models.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, productID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("id_produk = ?", productID).Delete(&models.ProductOption{}).Error; err != nil {
+			return err
+		}
+		for i := range options {
+			options[i].ProductID = productID
+			if err := tx.Create(&options[i]).Error; err != nil {
+				return err
+			}
+		}
+
+		var stored []models.ProductVariant
+		if err := tx.Unscoped().Where("id_produk = ?", productID).Find(&stored).Error; err != nil {
+			return err
+		}
+		bySKU := make(map[string]models.ProductVariant, len(stored))
+		for _, v := range stored {
+			bySKU[v.SKU] = v
+		}
+		kept := make(map[uint]bool, len(variants))
+		for i := range variants {
+			v := &variants[i]
+			v.ProductID = productID
+			if old, ok := bySKU[v.SKU]; ok {
+				// Saving unscoped also restores variants listed again
+				v.ID, v.CreatedAt = old.ID, old.CreatedAt
+				if err := tx.Unscoped().Save(v).Error; err != nil {
+					return err
+				}
+			} else if err := tx.Create(v).Error; err != nil {
+				return err
+			}
+			kept[v.ID] = true
+		}
+		for _, v := range stored {
+			if kept[v.ID] || v.DeletedAt.Valid {
+				continue
+			}
+			if err := tx.Delete(&models.ProductVariant{}, v.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(variants) == 0 {
+			return nil
+		}
+		stock, reseller, consumer := variantTotals(variants)
+		return tx.Model(&models.Product{}).Where("id = ?", productID).Updates(map[string]interface{}{
+			"stok":           stock,
+			"harga_reseller": reseller,
+			"harga_konsumen": consumer,
+		}).Error
+	})
+	return translate(err)
+}
+
+// Transaction Repository
+type gormTransactionRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormTransactionRepository) Create(ctx context.Context, trx models.Transaction, reqDetails []models.TrxItemRequest) ([]models.Transaction, error) {
+	var orders []models.Transaction
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. Lock the ordered products (SELECT ... FOR UPDATE) in id order,
+		// so concurrent checkouts wait for each other instead of deadlocking
+		byProduct, byVariant, ids, variantIDs := groupItems(reqDetails)
+		var products []models.Product
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", ids).Order("id").Find(&products).Error
+		if err != nil {
+			return err
+		}
+		if len(products) != len(ids) {
+			return ErrNotFound
+		}
+		byID := make(map[uint]models.Product, len(products))
+		for _, p := range products {
+			byID[p.ID] = p
+		}
+		var variants []models.ProductVariant
+		if err := tx.Where("id_produk IN ?", ids).Find(&variants).Error; err != nil {
+			return err
+		}
+		variantByID := make(map[uint]models.ProductVariant, len(variants))
+		for _, v := range variants {
+			variantByID[v.ID] = v
+		}
+		if err := checkVariants(reqDetails, variantByID); err != nil {
+			return err
+		}
+
+		// 2. Check stock of every line before writing anything
+		if shortages := findShortages(byID, variantByID, byProduct, byVariant, ids, variantIDs); len(shortages) > 0 {
+			return &InsufficientStockError{Items: shortages}
+		}
+
+		// 3. Decrement stock. The stok >= ? guards keep this safe on
+		// databases that ignore FOR UPDATE (SQLite serializes writers instead)
+		for _, id := range variantIDs {
+			res := tx.Model(&models.ProductVariant{}).
+				Where("id = ? AND stok >= ?", id, byVariant[id]).
+				Update("stok", gorm.Expr("stok - ?", byVariant[id]))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				var current models.ProductVariant
+				if err := tx.First(&current, id).Error; err != nil {
+					return translate(err)
+				}
+				return &InsufficientStockError{Items: []StockShortage{
+					NewStockShortage(byID[current.ProductID], current, byVariant[id]),
+				}}
+			}
+		}
+		for _, id := range ids {
+			res := tx.Model(&models.Product{}).
+				Where("id = ? AND stok >= ?", id, byProduct[id]).
+				Update("stok", gorm.Expr("stok - ?", byProduct[id]))
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				var current models.Product
+				if err := tx.First(&current, id).Error; err != nil {
+					return translate(err)
+				}
+				return &InsufficientStockError{Items: []StockShortage{
+					NewStockShortage(current, models.ProductVariant{}, byProduct[id]),
+				}}
+			}
+		}
+
+		now := time.Now()
+		for _, lines := range byStore(reqDetails, byID) {
+			order := trx
+
+			// 4. Create Transaction Header
+			scope := invoice.Scope(now, byID[lines[0].ProductID].StoreID)
+			seq, err := nextInvoiceSequence(tx, scope)
+			if err != nil {
+				return err
+			}
+			order.InvoiceCode = invoice.Number(scope, seq)
+			order.TotalPrice = 0
+			for _, item := range lines {
+				price, _ := unitPrice(withVariant(byID[item.ProductID], variantByID[item.VariantID]), order.OrderType)
+				order.TotalPrice += price.Mul(item.Kuantitas)
+			}
+			if err := tx.Create(&order).Error; err != nil {
+				return err
+			}
+			history := models.TransactionStatusHistory{
+				TransactionID: order.ID,
+				ToStatus:      order.Status,
+				ActorID:       order.UserID,
+				ActorRole:     models.ActorBuyer,
+			}
+			if err := tx.Create(&history).Error; err != nil {
+				return err
+			}
+
+			for _, item := range lines {
+				product, variant := byID[item.ProductID], variantByID[item.VariantID]
+				price, margin := unitPrice(withVariant(product, variant), order.OrderType)
+
+				// 5. Create Product Log (Snapshot)
+				log := productLog(product, variant)
+				if err := tx.Create(&log).Error; err != nil {
+					return err
+				}
+
+				// 6. Create Transaction Detail linked to Log
+				detail := models.TransactionDetail{
+					TransactionID: order.ID,
+					ProductLogID:  log.ID,
+					StoreID:       product.StoreID,
+					Quantity:      item.Kuantitas,
+					TotalPrice:    price.Mul(item.Kuantitas),
+					Margin:        margin.Mul(item.Kuantitas),
+				}
+				if err := tx.Create(&detail).Error; err != nil {
+					return err
+				}
+			}
+			orders = append(orders, order)
+		}
+
+		// 7. Take the ordered quantities off the cart lines
+		for _, item := range reqDetails {
+			if item.CartItemID == 0 {
+				continue
+			}
+			err := tx.Model(&models.CartItem{}).Where("id = ?", item.CartItemID).
+				Update("kuantitas", gorm.Expr("kuantitas - ?", item.Kuantitas)).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("id = ? AND kuantitas <= 0", item.CartItemID).Delete(&models.CartItem{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// nextInvoiceSequence increments the sequence of scope and returns the new
+// value. The upsert locks the sequence row until tx ends, so concurrent
+// orders of the same store take turns.
+func nextInvoiceSequence(tx *gorm.DB, scope string) (int64, error) {
+	seq := models.InvoiceSequence{Scope: scope, LastValue: 1}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scope"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"last_value": gorm.Expr("invoice_sequences.last_value + 1")}),
+	}).Create(&seq).Error
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Where("scope = ?", scope).First(&seq).Error; err != nil {
+		return 0, err
+	}
+	return seq.LastValue, nil
+}
+
+func (r *gormTransactionRepository) ListByUserID(ctx context.Context, userID uint) ([]models.Transaction, error) {
+	var trxs []models.Transaction
+	// Preload Log via Details
+	err := r.db.WithContext(ctx).Preload("Address").Preload("Details").Preload("Details.ProductLog").Where("id_user = ?", userID).Find(&trxs).Error
+	return trxs, err
+}
+
+func (r *gormTransactionRepository) List(ctx context.Context, filter TransactionFilter) ([]models.Transaction, int64, error) {
+	var trxs []models.Transaction
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Transaction{}).Where("id_user = ?", filter.UserID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := paged(query, "transactions", filter.Page).
+		Preload("Address").Preload("Details").Preload("Details.ProductLog").
+		Find(&trxs).Error
+	return trxs, total, err
+}
+
+func (r *gormTransactionRepository) FindByID(ctx context.Context, id uint) (models.Transaction, error) {
+	var trx models.Transaction
+	err := r.withDetails(ctx).First(&trx, id).Error
+	return trx, translate(err)
+}
+
+func (r *gormTransactionRepository) FindDetail(ctx context.Context, id uint) (models.TransactionDetail, error) {
+	var detail models.TransactionDetail
+	err := r.db.WithContext(ctx).Preload("Transaction").Preload("ProductLog").First(&detail, id).Error
+	return detail, translate(err)
+}
+
+func (r *gormTransactionRepository) FindByInvoiceCode(ctx context.Context, code string) (models.Transaction, error) {
+	var trx models.Transaction
+	err := r.withDetails(ctx).Where("kode_invoice = ?", code).First(&trx).Error
+	return trx, translate(err)
+}
+
+// withDetails preloads everything FindByID returns.
+func (r *gormTransactionRepository) withDetails(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Preload("Address").Preload("Details").Preload("Details.ProductLog").
+		Preload("StatusHistory", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Payments", func(db *gorm.DB) *gorm.DB { return db.Order("id") })
+}
+
+func (r *gormTransactionRepository) UpdateStatus(ctx context.Context, id uint, change StatusChange) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{"status": change.To}
+		if change.TrackingNumber != "" {
+			updates["no_resi"] = change.TrackingNumber
+		}
+		res := tx.Model(&models.Transaction{}).Where("id = ? AND status = ?", id, change.From).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			var count int64
+			if err := tx.Model(&models.Transaction{}).Where("id = ?", id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrNotFound
+			}
+			return ErrStatusChanged
+		}
+
+		history := models.TransactionStatusHistory{
+			TransactionID: id,
+			FromStatus:    change.From,
+			ToStatus:      change.To,
+			ActorID:       change.ActorID,
+			ActorRole:     change.ActorRole,
+			Note:          change.Note,
+		}
+		if err := tx.Create(&history).Error; err != nil {
+			return err
+		}
+		if len(change.Ledger) > 0 {
+			if err := tx.Create(&change.Ledger).Error; err != nil {
+				return translate(err)
+			}
+		}
+		if !change.Restock {
+			return nil
+		}
+
+		// Products and variants deleted since the order was placed get their
+		// stock back too, in case they are restored
+		var details []models.TransactionDetail
+		if err := tx.Preload("ProductLog").Where("id_trx = ?", id).Order("id").Find(&details).Error; err != nil {
+			return err
+		}
+		for _, d := range details {
+			if d.ProductLog.VariantID != nil {
+				err := tx.Unscoped().Model(&models.ProductVariant{}).Where("id = ?", *d.ProductLog.VariantID).
+					Update("stok", gorm.Expr("stok + ?", d.Quantity)).Error
+				if err != nil {
+					return err
+				}
+			}
+			err := tx.Unscoped().Model(&models.Product{}).Where("id = ?", d.ProductLog.ProductID).
+				Update("stok", gorm.Expr("stok + ?", d.Quantity)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *gormTransactionRepository) ListUnpaid(ctx context.Context, placedBefore time.Time, afterID uint, limit int) ([]models.Transaction, error) {
+	var trxs []models.Transaction
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND created_at < ? AND id > ?", models.TrxStatusPendingPayment, placedBefore, afterID).
+		Order("id").Limit(limit).Find(&trxs).Error
+	return trxs, err
+}
+
+// Cart Repository
+type gormCartRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormCartRepository) FindByUserID(ctx context.Context, userID uint) (models.Cart, error) {
+	var cart models.Cart
+	err := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Items.Product").Preload("Items.Product.Photos", orderPhotos).
+		Preload("Items.Product.Variants").Preload("Items.Product.Variants.Photo").
+		Where("id_user = ?", userID).First(&cart).Error
+	return cart, translate(err)
+}
+
+func (r *gormCartRepository) AddItem(ctx context.Context, userID, productID, variantID uint, quantity int) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var cart models.Cart
+		if err := tx.Where(models.Cart{UserID: userID}).FirstOrCreate(&cart).Error; err != nil {
+			return err
+		}
+
+		res := tx.Model(&models.CartItem{}).
+			Where("id_cart = ? AND id_produk = ? AND id_varian = ?", cart.ID, productID, variantID).
+			Update("kuantitas", gorm.Expr("kuantitas + ?", quantity))
+		if res.Error != nil || res.RowsAffected > 0 {
+			return res.Error
+		}
+		item := models.CartItem{CartID: cart.ID, ProductID: productID, VariantID: variantID, Quantity: quantity}
+		return tx.Create(&item).Error
+	})
+	return translate(err)
+}
+
+func (r *gormCartRepository) UpdateItemQuantity(ctx context.Context, itemID uint, quantity int) error {
+	res := r.db.WithContext(ctx).Model(&models.CartItem{}).Where("id = ?", itemID).Update("kuantitas", quantity)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return res.Error
+}
+
+func (r *gormCartRepository) DeleteItem(ctx context.Context, itemID uint) error {
+	return r.db.WithContext(ctx).Delete(&models.CartItem{}, itemID).Error
+}
+
+func (r *gormCartRepository) Clear(ctx context.Context, userID uint) error {
+	carts := r.db.Model(&models.Cart{}).Select("id").Where("id_user = ?", userID)
+	return r.db.WithContext(ctx).Where("id_cart IN (?)", carts).Delete(&models.CartItem{}).Error
+}
+
+func (r *gormTransactionRepository) ListStoreLines(ctx context.Context, filter StoreOrderFilter) ([]models.TransactionDetail, int64, error) {
+	var lines []models.TransactionDetail
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.TransactionDetail{}).
+		Joins("JOIN transactions ON transactions.id = transaction_details.id_trx").
+		Where("transaction_details.id_toko = ?", filter.StoreID)
+	if filter.Status != "" {
+		query = query.Where("transactions.status = ?", filter.Status)
+	}
+	if filter.InvoiceCode != "" {
+		query = query.Where("transactions.kode_invoice = ?", filter.InvoiceCode)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("transactions.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("transactions.created_at < ?", filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (filter.Page - 1) * filter.Limit
+	err := query.Select("transaction_details.*").
+		Preload("ProductLog").Preload("Transaction").Preload("Transaction.Address").
+		Order("transaction_details.id DESC").Limit(filter.Limit).Offset(offset).
+		Find(&lines).Error
+	return lines, total, err
+}
+
+// Payment Repository
+type gormPaymentRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormPaymentRepository) Create(ctx context.Context, payment *models.Payment) error {
+	return translate(r.db.WithContext(ctx).Create(payment).Error)
+}
+
+func (r *gormPaymentRepository) FindByCharge(ctx context.Context, provider, chargeID string) (models.Payment, error) {
+	var payment models.Payment
+	err := r.db.WithContext(ctx).Where("provider = ? AND charge_id = ?", provider, chargeID).First(&payment).Error
+	return payment, translate(err)
+}
+
+func (r *gormPaymentRepository) LatestByTransactionID(ctx context.Context, trxID uint) (models.Payment, error) {
+	var payment models.Payment
+	err := r.db.WithContext(ctx).Where("id_trx = ?", trxID).Order("id DESC").First(&payment).Error
+	return payment, translate(err)
+}
+
+func (r *gormPaymentRepository) UpdateStatus(ctx context.Context, id uint, from, to string, paidAt *time.Time) error {
+	updates := map[string]interface{}{"status": to}
+	if paidAt != nil {
+		updates["paid_at"] = *paidAt
+	}
+	res := r.db.WithContext(ctx).Model(&models.Payment{}).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrStatusChanged
+	}
+	return nil
+}
+
+// Idempotency Repository
+type gormIdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormIdempotencyRepository) Create(ctx context.Context, record *models.IdempotencyKey) error {
+	return translate(r.db.WithContext(ctx).Create(record).Error)
+}
+
+func (r *gormIdempotencyRepository) Find(ctx context.Context, userID uint, key string) (models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.WithContext(ctx).Where("id_user = ? AND idempotency_key = ?", userID, key).First(&record).Error
+	return record, translate(err)
+}
+
+func (r *gormIdempotencyRepository) Complete(ctx context.Context, id uint, statusCode int, contentType string, response []byte) error {
+	return r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status_code":  statusCode,
+		"content_type": contentType,
+		"response":     response,
+	}).Error
+}
+
+func (r *gormIdempotencyRepository) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&models.IdempotencyKey{}, id).Error
+}
+
+func (r *gormIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expired_at < ?", before).Delete(&models.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
+
+// Ledger Repository
+type gormLedgerRepository struct {
+	db *gorm.DB
+}
+
+// ledgerSums adds up the credits and debits of userID.
+func ledgerSums(tx *gorm.DB, userID uint) (credit, debit money.Amount, err error) {
+	var sums struct {
+		Credit money.Amount
+		Debit  money.Amount
+	}
+	err = tx.Model(&models.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN jenis = ? THEN jumlah ELSE 0 END), 0) AS credit, "+
+			"COALESCE(SUM(CASE WHEN jenis = ? THEN jumlah ELSE 0 END), 0) AS debit",
+			models.LedgerCredit, models.LedgerDebit).
+		Where("id_user = ?", userID).Scan(&sums).Error
+	return sums.Credit, sums.Debit, err
+}
+
+func (r *gormLedgerRepository) Balance(ctx context.Context, userID uint) (models.CommissionBalance, error) {
+	db := r.db.WithContext(ctx)
+	credit, debit, err := ledgerSums(db, userID)
+	if err != nil {
+		return models.CommissionBalance{}, err
+	}
+	var pending struct{ Amount money.Amount }
+	err = db.Model(&models.Payout{}).Select("COALESCE(SUM(jumlah), 0) AS amount").
+		Where("id_user = ? AND status = ?", userID, models.PayoutPending).Scan(&pending).Error
+	if err != nil {
+		return models.CommissionBalance{}, err
+	}
+	return models.CommissionBalance{
+		Balance:       credit - debit,
+		Credit:        credit,
+		Debit:         debit,
+		PendingPayout: pending.Amount,
+	}, nil
+}
+
+func (r *gormLedgerRepository) ListEntries(ctx context.Context, userID uint, page, limit int) ([]models.LedgerEntry, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.LedgerEntry{}).Where("id_user = ?", userID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []models.LedgerEntry
+	err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&entries).Error
+	return entries, total, err
+}
+
+func (r *gormLedgerRepository) RequestPayout(ctx context.Context, payout *models.Payout) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Requests of the same user queue up on the user row, so each one
+		// sees the debits of the previous ones
+		var user models.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, payout.UserID).Error
+		if err != nil {
+			return translate(err)
+		}
+		credit, debit, err := ledgerSums(tx, payout.UserID)
+		if err != nil {
+			return err
+		}
+		if credit-debit < payout.Amount {
+			return ErrInsufficientBalance
+		}
+
+		payout.Status = models.PayoutPending
+		if err := tx.Create(payout).Error; err != nil {
+			return err
+		}
+		entry := payoutEntry(*payout, models.LedgerDebit)
+		return translate(tx.Create(&entry).Error)
+	})
+}
+
+func (r *gormLedgerRepository) FindPayout(ctx context.Context, id uint) (models.Payout, error) {
+	var payout models.Payout
+	err := r.db.WithContext(ctx).First(&payout, id).Error
+	return payout, translate(err)
+}
+
+func (r *gormLedgerRepository) ListPayouts(ctx context.Context, filter PayoutFilter) ([]models.Payout, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Payout{})
+	if filter.UserID != 0 {
+		query = query.Where("id_user = ?", filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var payouts []models.Payout
+	err := query.Order("id DESC").Offset((filter.Page - 1) * filter.Limit).Limit(filter.Limit).Find(&payouts).Error
+	return payouts, total, err
+}
+
+func (r *gormLedgerRepository) ResolvePayout(ctx context.Context, id uint, status string, adminID uint, note string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&models.Payout{}).Where("id = ? AND status = ?", id, models.PayoutPending).
+			Updates(map[string]interface{}{"status": status, "catatan": note, "id_admin": adminID, "processed_at": now})
+		if res.Error != nil {
+			return res.Error
+		}
+		var payout models.Payout
+		if err := tx.First(&payout, id).Error; err != nil {
+			return translate(err)
+		}
+		if res.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+		if status != models.PayoutRejected {
+			return nil
+		}
+		entry := payoutEntry(payout, models.LedgerCredit)
+		return translate(tx.Create(&entry).Error)
+	})
+}
+
+// Review Repository
+type gormReviewRepository struct {
+	db *gorm.DB
+}
+
+func withReviewRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Photos", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Preload("ProductLog")
+}
+
+func (r *gormReviewRepository) Create(ctx context.Context, review *models.Review) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(review).Error; err != nil {
+			return translate(err)
+		}
+		return addRating(tx, *review, 1)
+	})
+}
+
+// addRating adds the rating of review to those of its product and store,
+// or takes it out again for sign -1. Counts are incremented in place
+// rather than recomputed so concurrent reviews cannot miss each other;
+// the average is then taken from the row the increment keeps locked.
+func addRating(tx *gorm.DB, review models.Review, sign int) error {
+	for _, row := range []struct {
+		model interface{}
+		id    uint
+	}{
+		{&models.Product{}, review.ProductID},
+		{&models.Store{}, review.StoreID},
+	} {
+		// Deleted products keep their rating should they come back
+		rows := tx.Unscoped().Model(row.model).Where("id = ?", row.id)
+		err := rows.UpdateColumns(map[string]interface{}{
+			"jumlah_ulasan": gorm.Expr("jumlah_ulasan + ?", sign),
+			"total_rating":  gorm.Expr("total_rating + ?", sign*review.Rating),
+		}).Error
+		if err != nil {
+			return err
+		}
+		rows = tx.Unscoped().Model(row.model).Where("id = ?", row.id)
+		err = rows.UpdateColumn("rating", gorm.Expr("CASE WHEN jumlah_ulasan > 0 THEN total_rating * 1.0 / jumlah_ulasan ELSE 0 END")).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *gormReviewRepository) FindByID(ctx context.Context, id uint) (models.Review, error) {
+	var review models.Review
+	err := withReviewRelations(r.db.WithContext(ctx)).First(&review, id).Error
+	return review, translate(err)
+}
+
+func (r *gormReviewRepository) List(ctx context.Context, filter ReviewFilter) ([]models.Review, int64, error) {
+	var reviews []models.Review
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.Review{})
+	if filter.ProductID != 0 {
+		query = query.Where("id_produk = ?", filter.ProductID)
+	}
+	if filter.StoreID != 0 {
+		query = query.Where("id_toko = ?", filter.StoreID)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("id_user = ?", filter.UserID)
+	}
+	if filter.Rating != 0 {
+		query = query.Where("rating = ?", filter.Rating)
+	}
+	if filter.Hidden != nil {
+		query = query.Where("disembunyikan = ?", *filter.Hidden)
+	} else {
+		query = query.Where("disembunyikan = ?", false)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := withReviewRelations(paged(query, "reviews", filter.Page)).Find(&reviews).Error
+	return reviews, total, err
+}
+
+func (r *gormReviewRepository) Reply(ctx context.Context, id uint, reply string) error {
+	res := r.db.WithContext(ctx).Model(&models.Review{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"balasan": reply, "replied_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormReviewRepository) SetHidden(ctx context.Context, id uint, hidden bool, adminID uint, reason string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Review{}).Where("id = ? AND disembunyikan = ?", id, !hidden).
+			Updates(map[string]interface{}{"disembunyikan": hidden, "alasan_disembunyikan": reason, "id_admin": adminID})
+		if res.Error != nil {
+			return res.Error
+		}
+		var review models.Review
+		if err := tx.First(&review, id).Error; err != nil {
+			return translate(err)
+		}
+		if res.RowsAffected == 0 {
+			return ErrStatusChanged
+		}
+		sign := 1
+		if hidden {
+			sign = -1
+		}
+		return addRating(tx, review, sign)
+	})
+}
+
+type gormWishlistRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormWishlistRepository) Add(ctx context.Context, item *models.WishlistItem) error {
+	return translate(r.db.WithContext(ctx).Create(item).Error)
+}
+
+func (r *gormWishlistRepository) Remove(ctx context.Context, userID, productID uint) error {
+	res := r.db.WithContext(ctx).Where("id_user = ? AND id_produk = ?", userID, productID).Delete(&models.WishlistItem{})
+	if res.Error == nil && res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return res.Error
+}
+
+func (r *gormWishlistRepository) List(ctx context.Context, userID uint, page pagination.Params) ([]models.WishlistItem, int64, error) {
+	var items []models.WishlistItem
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.WishlistItem{}).Where("id_user = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := paged(query, "wishlist_items", page).
+		Preload("Product").Preload("Product.Store").Preload("Product.Category").
+		Preload("Product.Photos", orderPhotos).
+		Preload("Product.Options", func(db *gorm.DB) *gorm.DB { return db.Order("urutan") }).
+		Preload("Product.Variants", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Preload("Product.Variants.Photo").
+		Find(&items).Error
+	return items, total, err
+}
+
+func (r *gormWishlistRepository) Saved(ctx context.Context, userID uint, productIDs []uint) (map[uint]bool, error) {
+	saved := make(map[uint]bool)
+	if len(productIDs) == 0 {
+		return saved, nil
+	}
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&models.WishlistItem{}).
+		Where("id_user = ? AND id_produk IN ?", userID, productIDs).
+		Pluck("id_produk", &ids).Error
+	for _, id := range ids {
+		saved[id] = true
+	}
+	return saved, err
+}
@@ -0,0 +1,1661 @@
+package repository
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/invoice"
+	"ecommerce-backend/pkg/money"
+	"ecommerce-backend/pkg/pagination"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewMemoryRepositories returns repositories that keep everything in
+// process memory. They mirror the GORM implementations closely enough for
+// unit tests and local experiments, but do nothing to persist data.
+func NewMemoryRepositories() *Repositories {
+	m := &memoryStore{
+		nextID:          make(map[string]uint),
+		users:           make(map[uint]models.User),
+		addresses:       make(map[uint]models.Address),
+		stores:          make(map[uint]models.Store),
+		categories:      make(map[uint]models.Category),
+		products:        make(map[uint]models.Product),
+		deletedProducts: make(map[uint]time.Time),
+		slugs:           make(map[uint]models.ProductSlug),
+		photos:          make(map[uint]models.ProductPhoto),
+		options:         make(map[uint]models.ProductOption),
+		variants:        make(map[uint]models.ProductVariant),
+		transactions:    make(map[uint]models.Transaction),
+		details:         make(map[uint]models.TransactionDetail),
+		logs:            make(map[uint]models.ProductLog),
+		carts:           make(map[uint]models.Cart),
+		cartItems:       make(map[uint]models.CartItem),
+		history:         make(map[uint]models.TransactionStatusHistory),
+		payments:        make(map[uint]models.Payment),
+		idempotency:     make(map[uint]models.IdempotencyKey),
+		invoiceSeqs:     make(map[string]int64),
+		ledger:          make(map[uint]models.LedgerEntry),
+		payouts:         make(map[uint]models.Payout),
+		reviews:         make(map[uint]models.Review),
+		reviewPhotos:    make(map[uint]models.ReviewPhoto),
+		wishlist:        make(map[uint]models.WishlistItem),
+	}
+	return &Repositories{
+		Users:        &memoryUserRepository{m},
+		Addresses:    &memoryAddressRepository{m},
+		Stores:       &memoryStoreRepository{m},
+		Categories:   &memoryCategoryRepository{m},
+		Products:     &memoryProductRepository{m},
+		Transactions: &memoryTransactionRepository{m},
+		Carts:        &memoryCartRepository{m},
+		Payments:     &memoryPaymentRepository{m},
+		Idempotency:  &memoryIdempotencyRepository{m},
+		Ledger:       &memoryLedgerRepository{m},
+		Reviews:      &memoryReviewRepository{m},
+		Wishlist:     &memoryWishlistRepository{m},
+	}
+}
+
+type memoryStore struct {
+	mu     sync.Mutex
+	nextID map[string]uint
+
+	users      map[uint]models.User
+	addresses  map[uint]models.Address
+	stores     map[uint]models.Store
+	categories map[uint]models.Category
+	products   map[uint]models.Product
+	// deletedProducts holds when each deleted product was deleted.
+	deletedProducts map[uint]time.Time
+	slugs           map[uint]models.ProductSlug
+	photos          map[uint]models.ProductPhoto
+	options         map[uint]models.ProductOption
+	variants        map[uint]models.ProductVariant
+	transactions    map[uint]models.Transaction
+	details         map[uint]models.TransactionDetail
+	logs            map[uint]models.ProductLog
+	carts           map[uint]models.Cart
+	cartItems       map[uint]models.CartItem
+	history         map[uint]models.TransactionStatusHistory
+	payments        map[uint]models.Payment
+	idempotency     map[uint]models.IdempotencyKey
+	invoiceSeqs     map[string]int64
+	ledger          map[uint]models.LedgerEntry
+	payouts         map[uint]models.Payout
+	reviews         map[uint]models.Review
+	reviewPhotos    map[uint]models.ReviewPhoto
+	wishlist        map[uint]models.WishlistItem
+}
+
+func (m *memoryStore) id(table string) uint {
+	m.nextID[table]++
+	return m.nextID[table]
+}
+
+// sortedValues returns the map values ordered by primary key, like an
+// unordered SELECT on an auto-increment table usually does.
+func sortedValues[T any](rows map[uint]T) []T {
+	ids := make([]uint, 0, len(rows))
+	for id := range rows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	values := make([]T, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, rows[id])
+	}
+	return values
+}
+
+func paginate[T any](rows []T, page, limit int) []T {
+	offset := (page - 1) * limit
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(rows) {
+		return []T{}
+	}
+	end := len(rows)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+	return rows[offset:end]
+}
+
+// pageOf sorts rows like paged does and returns the page of them: by
+// number, or as the rows after the values of the cursor.
+func pageOf[T any](rows []T, page pagination.Params, keys pagination.Keys[T]) []T {
+	order := page.Order()
+	compare := func(a T, b func(i int, column string) interface{}) int {
+		for i, key := range order {
+			c := pagination.Compare(keys[key.Column](a), b(i, key.Column))
+			if key.Desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	slices.SortStableFunc(rows, func(a, b T) int {
+		return compare(a, func(_ int, column string) interface{} { return keys[column](b) })
+	})
+	if page.After == nil {
+		return paginate(rows, page.Page, page.Limit)
+	}
+
+	i := 0
+	for i < len(rows) && compare(rows[i], func(i int, _ string) interface{} { return page.After[i] }) <= 0 {
+		i++
+	}
+	return paginate(rows[i:], 1, page.Limit)
+}
+
+// User Repository
+type memoryUserRepository struct {
+	m *memoryStore
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, u := range r.m.users {
+		if u.Phone == user.Phone || u.Email == user.Email {
+			return ErrDuplicate
+		}
+	}
+	now := time.Now()
+	user.ID = r.m.id("users")
+	user.CreatedAt, user.UpdatedAt = now, now
+	r.m.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) FindByPhone(ctx context.Context, phone string) (models.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, u := range sortedValues(r.m.users) {
+		if u.Phone == phone {
+			return u, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, id uint) (models.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	user, ok := r.m.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	for _, s := range sortedValues(r.m.stores) {
+		if s.UserID == user.ID {
+			user.Store = s
+			break
+		}
+	}
+	return user, nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, u := range r.m.users {
+		if u.ID != user.ID && (u.Phone == user.Phone || u.Email == user.Email) {
+			return ErrDuplicate
+		}
+	}
+	user.UpdatedAt = time.Now()
+	r.m.users[user.ID] = *user
+	return nil
+}
+
+// Address Repository
+type memoryAddressRepository struct {
+	m *memoryStore
+}
+
+func (r *memoryAddressRepository) ListByUserID(ctx context.Context, userID uint) ([]models.Address, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	addresses := []models.Address{}
+	for _, a := range sortedValues(r.m.addresses) {
+		if a.UserID == userID {
+			addresses = append(addresses, a)
+		}
+	}
+	return addresses, nil
+}
+
+func (r *memoryAddressRepository) FindByID(ctx context.Context, id uint) (models.Address, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	address, ok := r.m.addresses[id]
+	if !ok {
+		return models.Address{}, ErrNotFound
+	}
+	return address, nil
+}
+
+func (r *memoryAddressRepository) Create(ctx context.Context, address *models.Address) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	address.ID = r.m.id("addresses")
+	address.CreatedAt, address.UpdatedAt = now, now
+	r.m.addresses[address.ID] = *address
+	return nil
+}
+
+func (r *memoryAddressRepository) Update(ctx context.Context, address *models.Address) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	address.UpdatedAt = time.Now()
+	r.m.addresses[address.ID] = *address
+	return nil
+}
+
+func (r *memoryAddressRepository) Delete(ctx context.Context, id uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.addresses, id)
+	return nil
+}
+
+// Store Repository
+type memoryStoreRepository struct {
+	m *memoryStore
+}
+
+func (r *memoryStoreRepository) FindByUserID(ctx context.Context, userID uint) (models.Store, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, s := range sortedValues(r.m.stores) {
+		if s.UserID == userID {
+			return s, nil
+		}
+	}
+	return models.Store{}, ErrNotFound
+}
+
+func (r *memoryStoreRepository) FindByID(ctx context.Context, id uint) (models.Store, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	store, ok := r.m.stores[id]
+	if !ok {
+		return models.Store{}, ErrNotFound
+	}
+	return store, nil
+}
+
+func (r *memoryStoreRepository) Create(ctx context.Context, store *models.Store) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	store.ID = r.m.id("stores")
+	store.CreatedAt, store.UpdatedAt = now, now
+	r.m.stores[store.ID] = *store
+	return nil
+}
+
+func (r *memoryStoreRepository) Update(ctx context.Context, store *models.Store) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	current := r.m.stores[store.ID]
+	store.Rating, store.RatingCount, store.RatingTotal = current.Rating, current.RatingCount, current.RatingTotal
+	store.UpdatedAt = time.Now()
+	r.m.stores[store.ID] = *store
+	return nil
+}
+
+func (r *memoryStoreRepository) List(ctx context.Context, filter StoreFilter) ([]models.Store, int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var stores []models.Store
+	for _, s := range sortedValues(r.m.stores) {
+		if filter.Name == "" || strings.Contains(strings.ToLower(s.Name), strings.ToLower(filter.Name)) {
+			stores = append(stores, s)
+		}
+	}
+	page := pageOf(stores, filter.Page, StoreKeys)
+	return page, int64(len(stores)), nil
+}
+
+// Category Repository
+type memoryCategoryRepository struct {
+	m *memoryStore
+}
+
+func (r *memoryCategoryRepository) List(ctx context.Context) ([]models.Category, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	return sortedValues(r.m.categories), nil
+}
+
+func (r *memoryCategoryRepository) FindByID(ctx context.Context, id uint) (models.Category, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	category, ok := r.m.categories[id]
+	if !ok {
+		return models.Category{}, ErrNotFound
+	}
+	return category, nil
+}
+
+func (r *memoryCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	category.ID = r.m.id("categories")
+	category.CreatedAt, category.UpdatedAt = now, now
+	r.m.categories[category.ID] = *category
+	return nil
+}
+
+func (r *memoryCategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	category.UpdatedAt = time.Now()
+	r.m.categories[category.ID] = *category
+	return nil
+}
+
+func (r *memoryCategoryRepository) Delete(ctx context.Context, id uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.categories, id)
+	return nil
+}
+
+// Product Repository
+type memoryProductRepository struct {
+	m *memoryStore
+}
+
+// withRelations fills the associations GORM would preload. Callers hold mu.
+func (r *memoryProductRepository) withRelations(p models.Product) models.Product {
+	p.Store = r.m.stores[p.StoreID]
+	p.Category = r.m.categories[p.CategoryID]
+	p.Photos = []models.ProductPhoto{}
+	for _, photo := range sortedValues(r.m.photos) {
+		if photo.ProductID == p.ID {
+			p.Photos = append(p.Photos, photo)
+		}
+	}
+	sort.SliceStable(p.Photos, func(i, j int) bool { return p.Photos[i].Position < p.Photos[j].Position })
+	p.Options = []models.ProductOption{}
+	for _, option := range sortedValues(r.m.options) {
+		if option.ProductID == p.ID {
+			p.Options = append(p.Options, option)
+		}
+	}
+	sort.SliceStable(p.Options, func(i, j int) bool { return p.Options[i].Position < p.Options[j].Position })
+	p.Variants = r.m.activeVariants(p.ID)
+	for i, v := range p.Variants {
+		if v.PhotoID == nil {
+			continue
+		}
+		if photo, ok := r.m.photos[*v.PhotoID]; ok {
+			p.Variants[i].Photo = &photo
+		} else {
+			p.Variants[i].PhotoID = nil
+		}
+	}
+	return p
+}
+
+// activeVariants returns the variants of productID that are not deleted.
+// Callers hold mu.
+func (m *memoryStore) activeVariants(productID uint) []models.ProductVariant {
+	variants := []models.ProductVariant{}
+	for _, v := range sortedValues(m.variants) {
+		if v.ProductID == productID && !v.DeletedAt.Valid {
+			variants = append(variants, v)
+		}
+	}
+	return variants
+}
+
+func (r *memoryProductRepository) List(ctx context.Context, filter ProductFilter) ([]models.Product, int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var products []models.Product
+	for _, p := range sortedValues(r.m.products) {
+		if filter.CategoryID != "" && strconv.FormatUint(uint64(p.CategoryID), 10) != filter.CategoryID {
+			continue
+		}
+		if filter.StoreID != "" && strconv.FormatUint(uint64(p.StoreID), 10) != filter.StoreID {
+			continue
+		}
+		if filter.MaxPrice != nil && p.ConsumerPrice > *filter.MaxPrice {
+			continue
+		}
+		if filter.MinPrice != nil && p.ConsumerPrice < *filter.MinPrice {
+			continue
+		}
+		products = append(products, r.withRelations(p))
+	}
+	page := pageOf(products, filter.Page, ProductKeys)
+	return page, int64(len(products)), nil
+}
+
+func (r *memoryProductRepository) FindByID(ctx context.Context, id uint) (models.Product, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	product, ok := r.m.products[id]
+	if !ok {
+		return models.Product{}, ErrNotFound
+	}
+	return r.withRelations(product), nil
+}
+
+func (r *memoryProductRepository) FindByIDs(ctx context.Context, ids []uint) ([]models.Product, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	products := []models.Product{}
+	for _, id := range ids {
+		if p, ok := r.m.products[id]; ok {
+			products = append(products, r.withRelations(p))
+		}
+	}
+	return products, nil
+}
+
+func (r *memoryProductRepository) ChangedSince(ctx context.Context, since time.Time) ([]models.Product, []uint, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	changed := []models.Product{}
+	for _, p := range sortedValues(r.m.products) {
+		if !p.UpdatedAt.Before(since) {
+			changed = append(changed, r.withRelations(p))
+		}
+	}
+	var deleted []uint
+	for id, at := range r.m.deletedProducts {
+		if !at.Before(since) {
+			deleted = append(deleted, id)
+		}
+	}
+	slices.Sort(deleted)
+	return changed, deleted, nil
+}
+
+func (r *memoryProductRepository) FindBySlug(ctx context.Context, slug string) (models.Product, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, p := range r.m.products {
+		if p.Slug == slug {
+			return r.withRelations(p), nil
+		}
+	}
+	return models.Product{}, ErrNotFound
+}
+
+func (r *memoryProductRepository) FindPreviousSlug(ctx context.Context, slug string) (models.ProductSlug, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, previous := range r.m.slugs {
+		if previous.Slug == slug {
+			return previous, nil
+		}
+	}
+	return models.ProductSlug{}, ErrNotFound
+}
+
+func (r *memoryProductRepository) SlugTaken(ctx context.Context, slug string, productID uint) (bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if r.m.slugOwner(slug, productID) {
+		return true, nil
+	}
+	for _, previous := range r.m.slugs {
+		if previous.Slug == slug && previous.ProductID != productID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// slugOwner reports whether a product other than productID has slug, as
+// the unique index of the database would. Callers hold mu.
+func (m *memoryStore) slugOwner(slug string, productID uint) bool {
+	for _, p := range m.products {
+		if p.Slug == slug && p.ID != productID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryProductRepository) Create(ctx context.Context, product *models.Product) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if r.m.slugOwner(product.Slug, 0) {
+		return ErrDuplicate
+	}
+	now := time.Now()
+	product.ID = r.m.id("products")
+	product.CreatedAt, product.UpdatedAt = now, now
+	for i := range product.Photos {
+		photo := &product.Photos[i]
+		photo.ID = r.m.id("product_photos")
+		photo.ProductID = product.ID
+		photo.CreatedAt, photo.UpdatedAt = now, now
+		r.m.photos[photo.ID] = *photo
+	}
+	stored := *product
+	stored.Photos = nil
+	r.m.products[product.ID] = stored
+	return nil
+}
+
+func (r *memoryProductRepository) Update(ctx context.Context, id uint, changes ProductChanges) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	product, ok := r.m.products[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if changes.Name != nil {
+		product.Name = *changes.Name
+	}
+	if changes.Slug != nil && *changes.Slug != product.Slug {
+		if r.m.slugOwner(*changes.Slug, id) {
+			return ErrDuplicate
+		}
+		for sid, previous := range r.m.slugs {
+			if previous.ProductID == id && previous.Slug == *changes.Slug {
+				delete(r.m.slugs, sid)
+			}
+		}
+		if product.Slug != "" {
+			sid := r.m.id("product_slugs")
+			r.m.slugs[sid] = models.ProductSlug{ID: sid, ProductID: id, Slug: product.Slug, CreatedAt: time.Now()}
+		}
+		product.Slug = *changes.Slug
+	}
+	if changes.CategoryID != nil {
+		product.CategoryID = *changes.CategoryID
+	}
+	if changes.ResellerPrice != nil {
+		product.ResellerPrice = *changes.ResellerPrice
+	}
+	if changes.ConsumerPrice != nil {
+		product.ConsumerPrice = *changes.ConsumerPrice
+	}
+	if changes.Stock != nil {
+		product.Stock = *changes.Stock
+	}
+	if changes.Description != nil {
+		product.Description = *changes.Description
+	}
+	now := time.Now()
+	last := 0
+	for _, p := range r.m.photos {
+		if p.ProductID == id && p.Position > last {
+			last = p.Position
+		}
+	}
+	for i, url := range changes.PhotoURLs {
+		pid := r.m.id("product_photos")
+		r.m.photos[pid] = models.ProductPhoto{ID: pid, ProductID: id, URL: url, Position: last + i + 1, CreatedAt: now, UpdatedAt: now}
+	}
+	product.UpdatedAt = now
+	r.m.products[id] = product
+	return nil
+}
+
+func (r *memoryProductRepository) Delete(ctx context.Context, id uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.products[id]; ok {
+		delete(r.m.products, id)
+		r.m.deletedProducts[id] = time.Now()
+	}
+	return nil
+}
+
+func (r *memoryProductRepository) DeletePhoto(ctx context.Context, productID, photoID uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if photo, ok := r.m.photos[photoID]; !ok || photo.ProductID != productID {
+		return ErrNotFound
+	}
+	delete(r.m.photos, photoID)
+	return nil
+}
+
+func (r *memoryProductRepository) DeletePhotos(ctx context.Context, productID uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for id, photo := range r.m.photos {
+		if photo.ProductID == productID {
+			delete(r.m.photos, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryProductRepository) ReorderPhotos(ctx context.Context, productID uint, ids []uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, id := range ids {
+		if photo, ok := r.m.photos[id]; !ok || photo.ProductID != productID {
+			return ErrNotFound
+		}
+	}
+	now := time.Now()
+	for i, id := range ids {
+		photo := r.m.photos[id]
+		photo.Position = i + 1
+		photo.UpdatedAt = now
+		r.m.photos[id] = photo
+	}
+	return nil
+}
+
+func (r *memoryProductRepository) SetVariants(ctx context.Context, productID uint, options []models.ProductOption, variants []models.ProductVariant) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	product, ok := r.m.products[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	bySKU := make(map[string]models.ProductVariant)
+	takenSKUs := make(map[string]bool)
+	for _, v := range r.m.variants {
+		if v.ProductID == productID {
+			bySKU[v.SKU] = v
+		} else {
+			takenSKUs[v.SKU] = true
+		}
+	}
+	for _, v := range variants {
+		if takenSKUs[v.SKU] {
+			return ErrDuplicate
+		}
+	}
+
+	now := time.Now()
+	for id, option := range r.m.options {
+		if option.ProductID == productID {
+			delete(r.m.options, id)
+		}
+	}
+	for i := range options {
+		options[i].ID = r.m.id("product_options")
+		options[i].ProductID = productID
+		options[i].CreatedAt, options[i].UpdatedAt = now, now
+		r.m.options[options[i].ID] = options[i]
+	}
+
+	kept := make(map[uint]bool, len(variants))
+	for i := range variants {
+		v := &variants[i]
+		v.ProductID = productID
+		if old, ok := bySKU[v.SKU]; ok {
+			v.ID, v.CreatedAt = old.ID, old.CreatedAt
+		} else {
+			v.ID, v.CreatedAt = r.m.id("product_variants"), now
+		}
+		v.UpdatedAt = now
+		r.m.variants[v.ID] = *v
+		kept[v.ID] = true
+	}
+	for id, v := range r.m.variants {
+		if v.ProductID == productID && !kept[id] && !v.DeletedAt.Valid {
+			v.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+			r.m.variants[id] = v
+		}
+	}
+
+	if len(variants) > 0 {
+		product.Stock, product.ResellerPrice, product.ConsumerPrice = variantTotals(variants)
+		product.UpdatedAt = now
+		r.m.products[productID] = product
+	}
+	return nil
+}
+
+// Transaction Repository
+type memoryTransactionRepository struct {
+	m *memoryStore
+}
+
+func (r *memoryTransactionRepository) Create(ctx context.Context, trx models.Transaction, reqDetails []models.TrxItemRequest) ([]models.Transaction, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	// Check everything first so a failure leaves no partial writes behind,
+	// like the rolled back database transaction.
+	byProduct, byVariant, ids, variantIDs := groupItems(reqDetails)
+	variants := make(map[uint]models.ProductVariant)
+	for _, id := range ids {
+		if _, ok := r.m.products[id]; !ok {
+			return nil, ErrNotFound
+		}
+		for _, v := range r.m.activeVariants(id) {
+			variants[v.ID] = v
+		}
+	}
+	if err := checkVariants(reqDetails, variants); err != nil {
+		return nil, err
+	}
+	if shortages := findShortages(r.m.products, variants, byProduct, byVariant, ids, variantIDs); len(shortages) > 0 {
+		return nil, &InsufficientStockError{Items: shortages}
+	}
+
+	now := time.Now()
+	if trx.Status == "" {
+		trx.Status = models.TrxStatusPendingPayment
+	}
+	if trx.OrderType == "" {
+		trx.OrderType = models.OrderTypeRegular
+	}
+	var orders []models.Transaction
+	for _, lines := range byStore(reqDetails, r.m.products) {
+		order := trx
+		order.TotalPrice = 0
+		for _, item := range lines {
+			price, _ := unitPrice(withVariant(r.m.products[item.ProductID], variants[item.VariantID]), order.OrderType)
+			order.TotalPrice += price.Mul(item.Kuantitas)
+		}
+		scope := invoice.Scope(now, r.m.products[lines[0].ProductID].StoreID)
+		r.m.invoiceSeqs[scope]++
+		order.InvoiceCode = invoice.Number(scope, r.m.invoiceSeqs[scope])
+		order.ID = r.m.id("transactions")
+		order.CreatedAt, order.UpdatedAt = now, now
+		r.m.transactions[order.ID] = order
+		r.addHistory(models.TransactionStatusHistory{
+			TransactionID: order.ID,
+			ToStatus:      order.Status,
+			ActorID:       order.UserID,
+			ActorRole:     models.ActorBuyer,
+			CreatedAt:     now,
+		})
+
+		for _, item := range lines {
+			product, variant := r.m.products[item.ProductID], variants[item.VariantID]
+			price, margin := unitPrice(withVariant(product, variant), order.OrderType)
+
+			log := productLog(product, variant)
+			log.ID = r.m.id("product_logs")
+			log.CreatedAt, log.UpdatedAt = now, now
+			r.m.logs[log.ID] = log
+
+			detail := models.TransactionDetail{
+				ID:            r.m.id("transaction_details"),
+				TransactionID: order.ID,
+				ProductLogID:  log.ID,
+				StoreID:       product.StoreID,
+				Quantity:      item.Kuantitas,
+				TotalPrice:    price.Mul(item.Kuantitas),
+				Margin:        margin.Mul(item.Kuantitas),
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			}
+			r.m.details[detail.ID] = detail
+		}
+		orders = append(orders, order)
+	}
+
+	for _, id := range variantIDs {
+		variant := r.m.variants[id]
+		variant.Stock -= byVariant[id]
+		variant.UpdatedAt = now
+		r.m.variants[id] = variant
+	}
+	for _, id := range ids {
+		product := r.m.products[id]
+		product.Stock -= byProduct[id]
+		product.UpdatedAt = now
+		r.m.products[id] = product
+	}
+	for _, item := range reqDetails {
+		line, ok := r.m.cartItems[item.CartItemID]
+		if item.CartItemID == 0 || !ok {
+			continue
+		}
+		line.Quantity -= item.Kuantitas
+		line.UpdatedAt = now
+		r.m.cartItems[line.ID] = line
+		if line.Quantity <= 0 {
+			delete(r.m.cartItems, line.ID)
+		}
+	}
+	return orders, nil
+}
+
+// addHistory stores one status history entry. Callers hold mu.
+func (r *memoryTransactionRepository) addHistory(h models.TransactionStatusHistory) {
+	h.ID = r.m.id("transaction_status_histories")
+	r.m.history[h.ID] = h
+}
+
+// withRelations fills the associations GORM would preload. Callers hold mu.
+func (r *memoryTransactionRepository) withRelations(trx models.Transaction) models.Transaction {
+	trx.Address = r.m.addresses[trx.AddressID]
+	trx.Details = []models.TransactionDetail{}
+	for _, d := range sortedValues(r.m.details) {
+		if d.TransactionID == trx.ID {
+			d.ProductLog = r.m.logs[d.ProductLogID]
+			trx.Details = append(trx.Details, d)
+		}
+	}
+	return trx
+}
+
+func (r *memoryTransactionRepository) FindDetail(ctx context.Context, id uint) (models.TransactionDetail, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	detail, ok := r.m.details[id]
+	if !ok {
+		return models.TransactionDetail{}, ErrNotFound
+	}
+	trx := r.m.transactions[detail.TransactionID]
+	detail.Transaction = &trx
+	detail.ProductLog = r.m.logs[detail.ProductLogID]
+	return detail, nil
+}
+
+func (r *memoryTransactionRepository) ListByUserID(ctx context.Context, userID uint) ([]models.Transaction, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	trxs := []models.Transaction{}
+	for _, trx := range sortedValues(r.m.transactions) {
+		if trx.UserID == userID {
+			trxs = append(trxs, r.withRelations(trx))
+		}
+	}
+	return trxs, nil
+}
+
+func (r *memoryTransactionRepository) List(ctx context.Context, filter TransactionFilter) ([]models.Transaction, int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var trxs []models.Transaction
+	for _, trx := range sortedValues(r.m.transactions) {
+		if trx.UserID == filter.UserID {
+			trxs = append(trxs, trx)
+		}
+	}
+	page := pageOf(trxs, filter.Page, TransactionKeys)
+	for i := range page {
+		page[i] = r.withRelations(page[i])
+	}
+	return page, int64(len(trxs)), nil
+}
+
+func (r *memoryTransactionRepository) FindByID(ctx context.Context, id uint) (models.Transaction, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	trx, ok := r.m.transactions[id]
+	if !ok {
+		return models.Transaction{}, ErrNotFound
+	}
+	return r.withDetails(trx), nil
+}
+
+func (r *memoryTransactionRepository) FindByInvoiceCode(ctx context.Context, code string) (models.Transaction, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, trx := range r.m.transactions {
+		if trx.InvoiceCode == code {
+			return r.withDetails(trx), nil
+		}
+	}
+	return models.Transaction{}, ErrNotFound
+}
+
+// withDetails fills everything FindByID returns. Callers hold mu.
+func (r *memoryTransactionRepository) withDetails(trx models.Transaction) models.Transaction {
+	trx = r.withRelations(trx)
+	trx.StatusHistory = []models.TransactionStatusHistory{}
+	for _, h := range sortedValues(r.m.history) {
+		if h.TransactionID == trx.ID {
+			trx.StatusHistory = append(trx.StatusHistory, h)
+		}
+	}
+	trx.Payments = []models.Payment{}
+	for _, p := range sortedValues(r.m.payments) {
+		if p.TransactionID == trx.ID {
+			trx.Payments = append(trx.Payments, p)
+		}
+	}
+	return trx
+}
+
+func (r *memoryTransactionRepository) UpdateStatus(ctx context.Context, id uint, change StatusChange) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	trx, ok := r.m.transactions[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if trx.Status != change.From {
+		return ErrStatusChanged
+	}
+	for _, entry := range change.Ledger {
+		if r.m.ledgerReferenceTaken(entry.Reference) {
+			return ErrDuplicate
+		}
+	}
+
+	now := time.Now()
+	trx.Status = change.To
+	if change.TrackingNumber != "" {
+		trx.TrackingNumber = change.TrackingNumber
+	}
+	trx.UpdatedAt = now
+	r.m.transactions[id] = trx
+	r.addHistory(models.TransactionStatusHistory{
+		TransactionID: id,
+		FromStatus:    change.From,
+		ToStatus:      change.To,
+		ActorID:       change.ActorID,
+		ActorRole:     change.ActorRole,
+		Note:          change.Note,
+		CreatedAt:     now,
+	})
+
+	for _, entry := range change.Ledger {
+		r.m.addLedgerEntry(entry)
+	}
+
+	if change.Restock {
+		for _, d := range sortedValues(r.m.details) {
+			if d.TransactionID != id {
+				continue
+			}
+			log := r.m.logs[d.ProductLogID]
+			if log.VariantID != nil {
+				if variant, ok := r.m.variants[*log.VariantID]; ok {
+					variant.Stock += d.Quantity
+					variant.UpdatedAt = now
+					r.m.variants[variant.ID] = variant
+				}
+			}
+			productID := log.ProductID
+			if product, ok := r.m.products[productID]; ok {
+				product.Stock += d.Quantity
+				product.UpdatedAt = now
+				r.m.products[productID] = product
+			}
+		}
+	}
+	return nil
+}
+
+func (r *memoryTransactionRepository) ListUnpaid(ctx context.Context, placedBefore time.Time, afterID uint, limit int) ([]models.Transaction, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	trxs := []models.Transaction{}
+	for _, trx := range sortedValues(r.m.transactions) {
+		if len(trxs) == limit {
+			break
+		}
+		if trx.ID > afterID && trx.Status == models.TrxStatusPendingPayment && trx.CreatedAt.Before(placedBefore) {
+			trxs = append(trxs, trx)
+		}
+	}
+	return trxs, nil
+}
+
+// Cart Repository
+type memoryCartRepository struct {
+	m *memoryStore
+}
+
+// cartOf returns the cart of userID. Callers hold mu.
+func (r *memoryCartRepository) cartOf(userID uint) (models.Cart, bool) {
+	for _, cart := range sortedValues(r.m.carts) {
+		if cart.UserID == userID {
+			return cart, true
+		}
+	}
+	return models.Cart{}, false
+}
+
+func (r *memoryCartRepository) FindByUserID(ctx context.Context, userID uint) (models.Cart, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	cart, ok := r.cartOf(userID)
+	if !ok {
+		return models.Cart{}, ErrNotFound
+	}
+	products := &memoryProductRepository{r.m}
+	cart.Items = []models.CartItem{}
+	for _, item := range sortedValues(r.m.cartItems) {
+		if item.CartID == cart.ID {
+			if p, ok := r.m.products[item.ProductID]; ok {
+				item.Product = products.withRelations(p)
+			}
+			cart.Items = append(cart.Items, item)
+		}
+	}
+	return cart, nil
+}
+
+func (r *memoryCartRepository) AddItem(ctx context.Context, userID, productID, variantID uint, quantity int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	now := time.Now()
+	cart, ok := r.cartOf(userID)
+	if !ok {
+		cart = models.Cart{ID: r.m.id("carts"), UserID: userID, CreatedAt: now, UpdatedAt: now}
+		r.m.carts[cart.ID] = cart
+	}
+	for id, item := range r.m.cartItems {
+		if item.CartID == cart.ID && item.ProductID == productID && item.VariantID == variantID {
+			item.Quantity += quantity
+			item.UpdatedAt = now
+			r.m.cartItems[id] = item
+			return nil
+		}
+	}
+	item := models.CartItem{
+		ID:        r.m.id("cart_items"),
+		CartID:    cart.ID,
+		ProductID: productID,
+		VariantID: variantID,
+		Quantity:  quantity,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.m.cartItems[item.ID] = item
+	return nil
+}
+
+func (r *memoryCartRepository) UpdateItemQuantity(ctx context.Context, itemID uint, quantity int) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	item, ok := r.m.cartItems[itemID]
+	if !ok {
+		return ErrNotFound
+	}
+	item.Quantity = quantity
+	item.UpdatedAt = time.Now()
+	r.m.cartItems[itemID] = item
+	return nil
+}
+
+func (r *memoryCartRepository) DeleteItem(ctx context.Context, itemID uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.cartItems, itemID)
+	return nil
+}
+
+func (r *memoryCartRepository) Clear(ctx context.Context, userID uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	cart, ok := r.cartOf(userID)
+	if !ok {
+		return nil
+	}
+	for id, item := range r.m.cartItems {
+		if item.CartID == cart.ID {
+			delete(r.m.cartItems, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryTransactionRepository) ListStoreLines(ctx context.Context, filter StoreOrderFilter) ([]models.TransactionDetail, int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	details := sortedValues(r.m.details)
+	var lines []models.TransactionDetail
+	for i := len(details) - 1; i >= 0; i-- {
+		d := details[i]
+		trx := r.m.transactions[d.TransactionID]
+		switch {
+		case d.StoreID != filter.StoreID,
+			filter.Status != "" && trx.Status != filter.Status,
+			filter.InvoiceCode != "" && trx.InvoiceCode != filter.InvoiceCode,
+			!filter.From.IsZero() && trx.CreatedAt.Before(filter.From),
+			!filter.To.IsZero() && !trx.CreatedAt.Before(filter.To):
+			continue
+		}
+		d.ProductLog = r.m.logs[d.ProductLogID]
+		trx.Address = r.m.addresses[trx.AddressID]
+		d.Transaction = &trx
+		lines = append(lines, d)
+	}
+	return paginate(lines, filter.Page, filter.Limit), int64(len(lines)), nil
+}
+
+// Payment Repository
+type memoryPaymentRepository struct {
+	m *memoryStore
+}
+
+func (r *memoryPaymentRepository) Create(ctx context.Context, payment *models.Payment) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, p := range r.m.payments {
+		if p.Provider == payment.Provider && p.ChargeID == payment.ChargeID {
+			return ErrDuplicate
+		}
+	}
+	now := time.Now()
+	payment.ID = r.m.id("payments")
+	payment.CreatedAt, payment.UpdatedAt = now, now
+	r.m.payments[payment.ID] = *payment
+	return nil
+}
+
+func (r *memoryPaymentRepository) FindByCharge(ctx context.Context, provider, chargeID string) (models.Payment, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, p := range r.m.payments {
+		if p.Provider == provider && p.ChargeID == chargeID {
+			return p, nil
+		}
+	}
+	return models.Payment{}, ErrNotFound
+}
+
+func (r *memoryPaymentRepository) LatestByTransactionID(ctx context.Context, trxID uint) (models.Payment, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	payments := sortedValues(r.m.payments)
+	for i := len(payments) - 1; i >= 0; i-- {
+		if payments[i].TransactionID == trxID {
+			return payments[i], nil
+		}
+	}
+	return models.Payment{}, ErrNotFound
+}
+
+func (r *memoryPaymentRepository) UpdateStatus(ctx context.Context, id uint, from, to string, paidAt *time.Time) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	payment, ok := r.m.payments[id]
+	if !ok || payment.Status != from {
+		return ErrStatusChanged
+	}
+	payment.Status = to
+	if paidAt != nil {
+		payment.PaidAt = paidAt
+	}
+	payment.UpdatedAt = time.Now()
+	r.m.payments[id] = payment
+	return nil
+}
+
+// Idempotency Repository
+type memoryIdempotencyRepository struct {
+	m *memoryStore
+}
+
+func (r *memoryIdempotencyRepository) Create(ctx context.Context, record *models.IdempotencyKey) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, k := range r.m.idempotency {
+		if k.UserID == record.UserID && k.Key == record.Key {
+			return ErrDuplicate
+		}
+	}
+	record.ID = r.m.id("idempotency_keys")
+	record.CreatedAt = time.Now()
+	r.m.idempotency[record.ID] = *record
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Find(ctx context.Context, userID uint, key string) (models.IdempotencyKey, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, k := range r.m.idempotency {
+		if k.UserID == userID && k.Key == key {
+			return k, nil
+		}
+	}
+	return models.IdempotencyKey{}, ErrNotFound
+}
+
+func (r *memoryIdempotencyRepository) Complete(ctx context.Context, id uint, statusCode int, contentType string, response []byte) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	record, ok := r.m.idempotency[id]
+	if !ok {
+		return nil
+	}
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Response = append([]byte(nil), response...)
+	r.m.idempotency[id] = record
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Delete(ctx context.Context, id uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	delete(r.m.idempotency, id)
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var n int64
+	for id, k := range r.m.idempotency {
+		if k.ExpiresAt.Before(before) {
+			delete(r.m.idempotency, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Ledger Repository
+type memoryLedgerRepository struct {
+	m *memoryStore
+}
+
+// ledgerReferenceTaken mirrors the unique index on the ledger reference.
+// Callers hold mu.
+func (m *memoryStore) ledgerReferenceTaken(reference string) bool {
+	for _, e := range m.ledger {
+		if e.Reference == reference {
+			return true
+		}
+	}
+	return false
+}
+
+// addLedgerEntry stores entry, whose reference the caller checked. Callers
+// hold mu.
+func (m *memoryStore) addLedgerEntry(entry models.LedgerEntry) {
+	entry.ID = m.id("ledger_entries")
+	entry.CreatedAt = time.Now()
+	m.ledger[entry.ID] = entry
+}
+
+// ledgerSums adds up the credits and debits of userID. Callers hold mu.
+func (m *memoryStore) ledgerSums(userID uint) (credit, debit money.Amount) {
+	for _, e := range m.ledger {
+		switch {
+		case e.UserID != userID:
+		case e.Type == models.LedgerCredit:
+			credit += e.Amount
+		case e.Type == models.LedgerDebit:
+			debit += e.Amount
+		}
+	}
+	return credit, debit
+}
+
+func (r *memoryLedgerRepository) Balance(ctx context.Context, userID uint) (models.CommissionBalance, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	credit, debit := r.m.ledgerSums(userID)
+	balance := models.CommissionBalance{Balance: credit - debit, Credit: credit, Debit: debit}
+	for _, p := range r.m.payouts {
+		if p.UserID == userID && p.Status == models.PayoutPending {
+			balance.PendingPayout += p.Amount
+		}
+	}
+	return balance, nil
+}
+
+func (r *memoryLedgerRepository) ListEntries(ctx context.Context, userID uint, page, limit int) ([]models.LedgerEntry, int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	all := sortedValues(r.m.ledger)
+	entries := []models.LedgerEntry{}
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].UserID == userID {
+			entries = append(entries, all[i])
+		}
+	}
+	return paginate(entries, page, limit), int64(len(entries)), nil
+}
+
+func (r *memoryLedgerRepository) RequestPayout(ctx context.Context, payout *models.Payout) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	if _, ok := r.m.users[payout.UserID]; !ok {
+		return ErrNotFound
+	}
+	if credit, debit := r.m.ledgerSums(payout.UserID); credit-debit < payout.Amount {
+		return ErrInsufficientBalance
+	}
+
+	now := time.Now()
+	payout.ID = r.m.id("payouts")
+	payout.Status = models.PayoutPending
+	payout.CreatedAt, payout.UpdatedAt = now, now
+	r.m.payouts[payout.ID] = *payout
+	r.m.addLedgerEntry(payoutEntry(*payout, models.LedgerDebit))
+	return nil
+}
+
+func (r *memoryLedgerRepository) FindPayout(ctx context.Context, id uint) (models.Payout, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	payout, ok := r.m.payouts[id]
+	if !ok {
+		return models.Payout{}, ErrNotFound
+	}
+	return payout, nil
+}
+
+func (r *memoryLedgerRepository) ListPayouts(ctx context.Context, filter PayoutFilter) ([]models.Payout, int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	all := sortedValues(r.m.payouts)
+	payouts := []models.Payout{}
+	for i := len(all) - 1; i >= 0; i-- {
+		p := all[i]
+		if (filter.UserID == 0 || p.UserID == filter.UserID) && (filter.Status == "" || p.Status == filter.Status) {
+			payouts = append(payouts, p)
+		}
+	}
+	return paginate(payouts, filter.Page, filter.Limit), int64(len(payouts)), nil
+}
+
+func (r *memoryLedgerRepository) ResolvePayout(ctx context.Context, id uint, status string, adminID uint, note string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	payout, ok := r.m.payouts[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if payout.Status != models.PayoutPending {
+		return ErrStatusChanged
+	}
+
+	now := time.Now()
+	payout.Status = status
+	payout.Note = note
+	payout.ProcessedBy = &adminID
+	payout.ProcessedAt = &now
+	payout.UpdatedAt = now
+	r.m.payouts[id] = payout
+	if status == models.PayoutRejected {
+		r.m.addLedgerEntry(payoutEntry(payout, models.LedgerCredit))
+	}
+	return nil
+}
+
+// Review Repository
+type memoryReviewRepository struct {
+	m *memoryStore
+}
+
+// withRelations fills the associations GORM would preload. Callers hold mu.
+func (r *memoryReviewRepository) withRelations(review models.Review) models.Review {
+	review.Photos = []models.ReviewPhoto{}
+	for _, photo := range sortedValues(r.m.reviewPhotos) {
+		if photo.ReviewID == review.ID {
+			review.Photos = append(review.Photos, photo)
+		}
+	}
+	review.ProductLog = r.m.logs[review.ProductLogID]
+	return review
+}
+
+// addRating mirrors the GORM addRating. Callers hold mu.
+func (m *memoryStore) addRating(review models.Review, sign int) {
+	average := func(total int64, count int) float64 {
+		if count == 0 {
+			return 0
+		}
+		return float64(total) / float64(count)
+	}
+	if p, ok := m.products[review.ProductID]; ok {
+		p.RatingCount += sign
+		p.RatingTotal += int64(sign * review.Rating)
+		p.Rating = average(p.RatingTotal, p.RatingCount)
+		m.products[p.ID] = p
+	}
+	if s, ok := m.stores[review.StoreID]; ok {
+		s.RatingCount += sign
+		s.RatingTotal += int64(sign * review.Rating)
+		s.Rating = average(s.RatingTotal, s.RatingCount)
+		m.stores[s.ID] = s
+	}
+}
+
+func (r *memoryReviewRepository) Create(ctx context.Context, review *models.Review) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, existing := range r.m.reviews {
+		if existing.TransactionDetailID == review.TransactionDetailID {
+			return ErrDuplicate
+		}
+	}
+	now := time.Now()
+	review.ID = r.m.id("reviews")
+	review.CreatedAt, review.UpdatedAt = now, now
+	for i := range review.Photos {
+		photo := &review.Photos[i]
+		photo.ID = r.m.id("review_photos")
+		photo.ReviewID = review.ID
+		photo.CreatedAt = now
+		r.m.reviewPhotos[photo.ID] = *photo
+	}
+	stored := *review
+	stored.Photos = nil
+	r.m.reviews[review.ID] = stored
+	r.m.addRating(stored, 1)
+	return nil
+}
+
+func (r *memoryReviewRepository) FindByID(ctx context.Context, id uint) (models.Review, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	review, ok := r.m.reviews[id]
+	if !ok {
+		return models.Review{}, ErrNotFound
+	}
+	return r.withRelations(review), nil
+}
+
+func (r *memoryReviewRepository) List(ctx context.Context, filter ReviewFilter) ([]models.Review, int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	hidden := false
+	if filter.Hidden != nil {
+		hidden = *filter.Hidden
+	}
+	var reviews []models.Review
+	for _, review := range sortedValues(r.m.reviews) {
+		if filter.ProductID != 0 && review.ProductID != filter.ProductID ||
+			filter.StoreID != 0 && review.StoreID != filter.StoreID ||
+			filter.UserID != 0 && review.UserID != filter.UserID ||
+			filter.Rating != 0 && review.Rating != filter.Rating ||
+			review.Hidden != hidden {
+			continue
+		}
+		reviews = append(reviews, review)
+	}
+	page := pageOf(reviews, filter.Page, ReviewKeys)
+	for i := range page {
+		page[i] = r.withRelations(page[i])
+	}
+	return page, int64(len(reviews)), nil
+}
+
+func (r *memoryReviewRepository) Reply(ctx context.Context, id uint, reply string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	review, ok := r.m.reviews[id]
+	if !ok {
+		return ErrNotFound
+	}
+	now := time.Now()
+	review.Reply = reply
+	review.RepliedAt = &now
+	review.UpdatedAt = now
+	r.m.reviews[id] = review
+	return nil
+}
+
+func (r *memoryReviewRepository) SetHidden(ctx context.Context, id uint, hidden bool, adminID uint, reason string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	review, ok := r.m.reviews[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if review.Hidden == hidden {
+		return ErrStatusChanged
+	}
+
+	review.Hidden = hidden
+	review.HiddenReason = reason
+	review.HiddenBy = &adminID
+	review.UpdatedAt = time.Now()
+	r.m.reviews[id] = review
+	sign := 1
+	if hidden {
+		sign = -1
+	}
+	r.m.addRating(review, sign)
+	return nil
+}
+
+type memoryWishlistRepository struct {
+	m *memoryStore
+}
+
+func (r *memoryWishlistRepository) Add(ctx context.Context, item *models.WishlistItem) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for _, existing := range r.m.wishlist {
+		if existing.UserID == item.UserID && existing.ProductID == item.ProductID {
+			return ErrDuplicate
+		}
+	}
+	item.ID = r.m.id("wishlist_items")
+	item.CreatedAt = time.Now()
+	stored := *item
+	stored.Product = models.Product{}
+	r.m.wishlist[item.ID] = stored
+	return nil
+}
+
+func (r *memoryWishlistRepository) Remove(ctx context.Context, userID, productID uint) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	for id, item := range r.m.wishlist {
+		if item.UserID == userID && item.ProductID == productID {
+			delete(r.m.wishlist, id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryWishlistRepository) List(ctx context.Context, userID uint, page pagination.Params) ([]models.WishlistItem, int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	var items []models.WishlistItem
+	for _, item := range sortedValues(r.m.wishlist) {
+		if item.UserID == userID {
+			items = append(items, item)
+		}
+	}
+	result := pageOf(items, page, WishlistKeys)
+	products := &memoryProductRepository{r.m}
+	for i, item := range result {
+		if p, ok := r.m.products[item.ProductID]; ok {
+			result[i].Product = products.withRelations(p)
+		}
+	}
+	return result, int64(len(items)), nil
+}
+
+func (r *memoryWishlistRepository) Saved(ctx context.Context, userID uint, productIDs []uint) (map[uint]bool, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	saved := make(map[uint]bool)
+	for _, item := range r.m.wishlist {
+		if item.UserID == userID && slices.Contains(productIDs, item.ProductID) {
+			saved[item.ProductID] = true
+		}
+	}
+	return saved, nil
+}
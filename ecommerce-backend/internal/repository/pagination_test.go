@@ -0,0 +1,129 @@
+package repository_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"ecommerce-backend/pkg/pagination"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+var implementations = map[string]func(t *testing.T) *repository.Repositories{
+	"gorm":   openTestDB,
+	"memory": func(*testing.T) *repository.Repositories { return repository.NewMemoryRepositories() },
+}
+
+// follow lists every page of a list by cursor and returns the ids of the
+// rows in order.
+func follow[T any](t *testing.T, query pagination.Query, sortable pagination.Sortable, keys pagination.Keys[T], list func(pagination.Params) []T, id func(T) uint) []uint {
+	t.Helper()
+	var ids []uint
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("cursors never end")
+		}
+		page, err := pagination.Parse(query, sortable)
+		must(t, err)
+		rows := list(page)
+		for _, row := range rows {
+			ids = append(ids, id(row))
+		}
+		if query.Cursor = pagination.Next(page, rows, keys); query.Cursor == "" {
+			return ids
+		}
+	}
+}
+
+func TestPagedSortsAndFollowsCursors(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := open(t)
+			f := seed(t, repos, 1)
+
+			// prices in rupiah, with ties broken by id
+			for i, price := range []int64{20000, 5000, 20000, 12500, 5000} {
+				product := models.Product{StoreID: 1, CategoryID: 1, Name: fmt.Sprintf("Produk %d", i), Slug: fmt.Sprintf("produk-%d", i), ConsumerPrice: money.Rupiah(price), Stock: i}
+				must(t, repos.Products.Create(ctx, &product))
+			}
+			list := func(page pagination.Params) []models.Product {
+				products, total, err := repos.Products.List(ctx, repository.ProductFilter{Page: page})
+				must(t, err)
+				if total != 6 {
+					t.Fatalf("total = %d, want 6", total)
+				}
+				return products
+			}
+			id := func(p models.Product) uint { return p.ID }
+
+			tests := []struct {
+				sort string
+				want []uint
+			}{
+				{"harga_konsumen", []uint{3, 6, 5, f.productID, 2, 4}},
+				{"harga_konsumen:desc", []uint{4, 2, f.productID, 5, 6, 3}},
+				{"harga_konsumen:desc,stok", []uint{2, 4, f.productID, 5, 3, 6}},
+				{"nama_produk:desc", []uint{6, 5, 4, 3, 2, f.productID}},
+			}
+			for _, tt := range tests {
+				got := follow(t, pagination.Query{Limit: 4, Sort: tt.sort}, repository.ProductSort, repository.ProductKeys, list, id)
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s by cursor: got %v, want %v", tt.sort, got, tt.want)
+				}
+
+				got = nil
+				for n := 1; n <= 3; n++ {
+					page, err := pagination.Parse(pagination.Query{Page: n, Limit: 2, Sort: tt.sort}, repository.ProductSort)
+					must(t, err)
+					for _, p := range list(page) {
+						got = append(got, p.ID)
+					}
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("%s by page: got %v, want %v", tt.sort, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCursorAfterDeletedRow(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := open(t)
+			f := seed(t, repos, 1)
+
+			var products []uint
+			for i := 0; i < 3; i++ {
+				product := models.Product{StoreID: 1, CategoryID: 1, Name: "Produk", Slug: fmt.Sprintf("produk-%d", i)}
+				must(t, repos.Products.Create(ctx, &product))
+				must(t, repos.Wishlist.Add(ctx, &models.WishlistItem{UserID: f.userID, ProductID: product.ID}))
+				products = append(products, product.ID)
+			}
+
+			page, err := pagination.Parse(pagination.Query{Limit: 1}, repository.WishlistSort)
+			must(t, err)
+			items, _, err := repos.Wishlist.List(ctx, f.userID, page)
+			must(t, err)
+			if len(items) != 1 || items[0].ProductID != products[2] {
+				t.Fatalf("first page = %+v, want the last item saved", items)
+			}
+			cursor := pagination.Next(page, items, repository.WishlistKeys)
+
+			// the row the cursor points after is gone, hard deleted
+			must(t, repos.Wishlist.Remove(ctx, f.userID, products[2]))
+
+			page, err = pagination.Parse(pagination.Query{Limit: 1, Cursor: cursor}, repository.WishlistSort)
+			must(t, err)
+			items, total, err := repos.Wishlist.List(ctx, f.userID, page)
+			must(t, err)
+			if total != 2 || len(items) != 1 || items[0].ProductID != products[1] {
+				t.Fatalf("page after a deleted row = %+v of %d, want the item before it", items, total)
+			}
+		})
+	}
+}
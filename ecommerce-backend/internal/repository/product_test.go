@@ -0,0 +1,95 @@
+package repository_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// failPhotos makes every insert of product photos on db fail, as a full
+// disk would.
+func failPhotos(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	err := db.Callback().Create().Before("gorm:create").Register("test:fail_photos", func(tx *gorm.DB) {
+		if tx.Statement.Table == "product_photos" {
+			tx.AddError(errors.New("disk full"))
+		}
+	})
+	must(t, err)
+}
+
+func TestProductPhotosAreSavedWithTheProduct(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	repos := repository.NewGormRepositories(db)
+	f := seed(t, repos, 5)
+	existing, err := repos.Products.FindByID(ctx, f.productID)
+	must(t, err)
+	failPhotos(t, db)
+
+	product := models.Product{
+		StoreID:       existing.StoreID,
+		CategoryID:    existing.CategoryID,
+		Name:          "Kemeja",
+		Slug:          "kemeja",
+		ConsumerPrice: money.Rupiah(50000),
+		Photos:        []models.ProductPhoto{{URL: "a.png", Position: 1}, {URL: "b.png", Position: 2}},
+	}
+	if err := repos.Products.Create(ctx, &product); err == nil {
+		t.Fatal("create: want the photo insert to fail")
+	}
+	if taken, err := repos.Products.SlugTaken(ctx, "kemeja", 0); err != nil || taken {
+		t.Fatalf("the product was saved without its photos (taken %v, %v)", taken, err)
+	}
+
+	name := "Kaos Polos"
+	err = repos.Products.Update(ctx, f.productID, repository.ProductChanges{Name: &name, PhotoURLs: []string{"c.png"}})
+	if err == nil {
+		t.Fatal("update: want the photo insert to fail")
+	}
+	product, err = repos.Products.FindByID(ctx, f.productID)
+	must(t, err)
+	if product.Name != existing.Name || len(product.Photos) != 0 {
+		t.Fatalf("after the failed update the product is %q with %d photos", product.Name, len(product.Photos))
+	}
+}
+
+func TestUpdateAddsPhotosAfterTheLast(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := open(t)
+			f := seed(t, repos, 5)
+			existing, err := repos.Products.FindByID(ctx, f.productID)
+			must(t, err)
+
+			product := models.Product{
+				StoreID:    existing.StoreID,
+				CategoryID: existing.CategoryID,
+				Name:       "Kemeja",
+				Slug:       "kemeja",
+				Photos:     []models.ProductPhoto{{URL: "a.png", Position: 1}, {URL: "b.png", Position: 2}},
+			}
+			must(t, repos.Products.Create(ctx, &product))
+			must(t, repos.Products.Update(ctx, product.ID, repository.ProductChanges{PhotoURLs: []string{"c.png", "d.png"}}))
+
+			product, err = repos.Products.FindByID(ctx, product.ID)
+			must(t, err)
+			var urls []string
+			for i, photo := range product.Photos {
+				if photo.Position != i+1 {
+					t.Fatalf("photo %s is at %d, want %d", photo.URL, photo.Position, i+1)
+				}
+				urls = append(urls, photo.URL)
+			}
+			if len(urls) != 4 || urls[0] != "a.png" || urls[3] != "d.png" {
+				t.Fatalf("photos = %v, want a, b, c, d", urls)
+			}
+		})
+	}
+}
@@ -0,0 +1,650 @@
+package repository
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"ecommerce-backend/pkg/pagination"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by every repository when the requested record
+	// does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate is returned when a write violates a unique constraint.
+	ErrDuplicate = errors.New("duplicate record")
+	// ErrInsufficientStock matches every *InsufficientStockError.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrStatusChanged is returned when an order left the expected status
+	// before a transition could be applied.
+	ErrStatusChanged = errors.New("order status changed")
+	// ErrInsufficientBalance is returned when a payout exceeds the
+	// commission balance.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+)
+
+// StockShortage describes one product, or variant of it, an order asked
+// too much of.
+type StockShortage struct {
+	ProductID uint   `json:"product_id"`
+	VariantID uint   `json:"variant_id,omitempty"`
+	SKU       string `json:"sku,omitempty"`
+	Name      string `json:"nama_produk"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// NewStockShortage describes requested units of p, or of its variant v
+// unless v is the zero variant, with the stock of either.
+func NewStockShortage(p models.Product, v models.ProductVariant, requested int) StockShortage {
+	if v.ID == 0 {
+		return StockShortage{ProductID: p.ID, Name: p.Name, Requested: requested, Available: p.Stock}
+	}
+	return StockShortage{
+		ProductID: p.ID,
+		VariantID: v.ID,
+		SKU:       v.SKU,
+		Name:      fmt.Sprintf("%s (%s)", p.Name, v.Name),
+		Requested: requested,
+		Available: v.Stock,
+	}
+}
+
+// InsufficientStockError lists every product of an order that is short.
+type InsufficientStockError struct {
+	Items []StockShortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	parts := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		parts = append(parts, fmt.Sprintf("%s (requested %d, available %d)", item.Name, item.Requested, item.Available))
+	}
+	return "insufficient stock for " + strings.Join(parts, ", ")
+}
+
+func (e *InsufficientStockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+// groupItems sums the quantities per product, variants included, and per
+// variant, since an order may list the same one twice. It returns the
+// product ids and variant ids in ascending order.
+func groupItems(items []models.TrxItemRequest) (byProduct, byVariant map[uint]int, ids, variantIDs []uint) {
+	byProduct = make(map[uint]int)
+	byVariant = make(map[uint]int)
+	for _, item := range items {
+		if _, seen := byProduct[item.ProductID]; !seen {
+			ids = append(ids, item.ProductID)
+		}
+		byProduct[item.ProductID] += item.Kuantitas
+		if item.VariantID == 0 {
+			continue
+		}
+		if _, seen := byVariant[item.VariantID]; !seen {
+			variantIDs = append(variantIDs, item.VariantID)
+		}
+		byVariant[item.VariantID] += item.Kuantitas
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	sort.Slice(variantIDs, func(i, j int) bool { return variantIDs[i] < variantIDs[j] })
+	return byProduct, byVariant, ids, variantIDs
+}
+
+// checkVariants makes sure every line names a variant of its product when,
+// and only when, the product has variants. variants holds the active
+// variants of the ordered products.
+func checkVariants(items []models.TrxItemRequest, variants map[uint]models.ProductVariant) error {
+	withVariants := make(map[uint]bool)
+	for _, v := range variants {
+		withVariants[v.ProductID] = true
+	}
+	for _, item := range items {
+		if item.VariantID == 0 {
+			if withVariants[item.ProductID] {
+				return ErrNotFound
+			}
+			continue
+		}
+		if v, ok := variants[item.VariantID]; !ok || v.ProductID != item.ProductID {
+			return ErrNotFound
+		}
+	}
+	return nil
+}
+
+// findShortages checks the stock of every ordered variant, and of every
+// product ordered without one.
+func findShortages(products map[uint]models.Product, variants map[uint]models.ProductVariant, byProduct, byVariant map[uint]int, ids, variantIDs []uint) []StockShortage {
+	var shortages []StockShortage
+	for _, id := range ids {
+		p := products[id]
+		ordered := false
+		for _, vid := range variantIDs {
+			v := variants[vid]
+			if v.ProductID != id {
+				continue
+			}
+			ordered = true
+			if v.Stock < byVariant[vid] {
+				shortages = append(shortages, NewStockShortage(p, v, byVariant[vid]))
+			}
+		}
+		if !ordered && p.Stock < byProduct[id] {
+			shortages = append(shortages, NewStockShortage(p, models.ProductVariant{}, byProduct[id]))
+		}
+	}
+	return shortages
+}
+
+// withVariant returns p as sold in variant v, with the prices and stock of
+// v. The zero variant leaves p as it is.
+func withVariant(p models.Product, v models.ProductVariant) models.Product {
+	if v.ID == 0 {
+		return p
+	}
+	p.ResellerPrice, p.ConsumerPrice, p.Stock = v.ResellerPrice, v.ConsumerPrice, v.Stock
+	return p
+}
+
+// byStore splits items into the lines of each store, in the order the
+// stores first appear. products holds every ordered product.
+func byStore(items []models.TrxItemRequest, products map[uint]models.Product) [][]models.TrxItemRequest {
+	var stores []uint
+	lines := make(map[uint][]models.TrxItemRequest)
+	for _, item := range items {
+		store := products[item.ProductID].StoreID
+		if _, seen := lines[store]; !seen {
+			stores = append(stores, store)
+		}
+		lines[store] = append(lines[store], item)
+	}
+	split := make([][]models.TrxItemRequest, 0, len(stores))
+	for _, store := range stores {
+		split = append(split, lines[store])
+	}
+	return split
+}
+
+// productLog snapshots p, as sold in variant v, for an order line.
+func productLog(p models.Product, v models.ProductVariant) models.ProductLog {
+	sold := withVariant(p, v)
+	log := models.ProductLog{
+		ProductID:     p.ID,
+		StoreID:       p.StoreID,
+		CategoryID:    p.CategoryID,
+		Name:          p.Name,
+		Slug:          p.Slug,
+		ResellerPrice: sold.ResellerPrice,
+		ConsumerPrice: sold.ConsumerPrice,
+		Description:   p.Description,
+	}
+	if v.ID != 0 {
+		log.VariantID = &v.ID
+		log.SKU = v.SKU
+		log.VariantName = v.Name
+	}
+	return log
+}
+
+// variantTotals returns what a product with variants shows: the stock of
+// all of them and the prices of the cheapest.
+func variantTotals(variants []models.ProductVariant) (stock int, reseller, consumer money.Amount) {
+	for i, v := range variants {
+		stock += v.Stock
+		if i == 0 || v.ConsumerPrice < consumer {
+			reseller, consumer = v.ResellerPrice, v.ConsumerPrice
+		}
+	}
+	return stock, reseller, consumer
+}
+
+// IsResellerOrder reports whether orders of orderType are priced at
+// harga_reseller.
+func IsResellerOrder(orderType string) bool {
+	return orderType == models.OrderTypeReseller || orderType == models.OrderTypeDropship
+}
+
+// ResellerPrice returns what a reseller pays for one unit priced reseller
+// and consumer, and the margin earned reselling it at consumer. Products
+// without a reseller price, or with one above the consumer price, cost
+// resellers the consumer price and earn no margin.
+func ResellerPrice(reseller, consumer money.Amount) (price, margin money.Amount) {
+	if reseller <= 0 || reseller > consumer {
+		return consumer, 0
+	}
+	return reseller, consumer - reseller
+}
+
+// unitPrice returns what one unit of p costs on an order of orderType and
+// the margin a reseller earns on it.
+func unitPrice(p models.Product, orderType string) (price, margin money.Amount) {
+	if !IsResellerOrder(orderType) {
+		return p.ConsumerPrice, 0
+	}
+	return ResellerPrice(p.ResellerPrice, p.ConsumerPrice)
+}
+
+// Repositories bundles the storage dependencies of the application.
+type Repositories struct {
+	Users        UserRepository
+	Addresses    AddressRepository
+	Stores       StoreRepository
+	Categories   CategoryRepository
+	Products     ProductRepository
+	Transactions TransactionRepository
+	Carts        CartRepository
+	Payments     PaymentRepository
+	Idempotency  IdempotencyRepository
+	Ledger       LedgerRepository
+	Reviews      ReviewRepository
+	Wishlist     WishlistRepository
+}
+
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	FindByPhone(ctx context.Context, phone string) (models.User, error)
+	FindByID(ctx context.Context, id uint) (models.User, error)
+	Update(ctx context.Context, user *models.User) error
+}
+
+type AddressRepository interface {
+	ListByUserID(ctx context.Context, userID uint) ([]models.Address, error)
+	FindByID(ctx context.Context, id uint) (models.Address, error)
+	Create(ctx context.Context, address *models.Address) error
+	Update(ctx context.Context, address *models.Address) error
+	Delete(ctx context.Context, id uint) error
+}
+
+type StoreRepository interface {
+	FindByUserID(ctx context.Context, userID uint) (models.Store, error)
+	FindByID(ctx context.Context, id uint) (models.Store, error)
+	Create(ctx context.Context, store *models.Store) error
+	// Update saves store except for its rating, which only reviews change.
+	Update(ctx context.Context, store *models.Store) error
+	List(ctx context.Context, filter StoreFilter) ([]models.Store, int64, error)
+}
+
+// StoreSort is what the store list can be sorted by.
+var StoreSort = pagination.Sortable{Fields: map[string]string{
+	"nama_toko":     "nama_toko",
+	"rating":        "rating",
+	"jumlah_ulasan": "jumlah_ulasan",
+	"created_at":    "created_at",
+}}
+
+// StoreFilter selects a page of stores. An empty Name is ignored.
+type StoreFilter struct {
+	Name string
+	Page pagination.Params
+}
+
+// StoreKeys reads the values StoreSort sorts stores by.
+var StoreKeys = pagination.Keys[models.Store]{
+	"id":            func(s models.Store) interface{} { return s.ID },
+	"nama_toko":     func(s models.Store) interface{} { return s.Name },
+	"rating":        func(s models.Store) interface{} { return s.Rating },
+	"jumlah_ulasan": func(s models.Store) interface{} { return s.RatingCount },
+	"created_at":    func(s models.Store) interface{} { return s.CreatedAt },
+}
+
+type CategoryRepository interface {
+	List(ctx context.Context) ([]models.Category, error)
+	FindByID(ctx context.Context, id uint) (models.Category, error)
+	Create(ctx context.Context, category *models.Category) error
+	Update(ctx context.Context, category *models.Category) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// ProductSort is what the product list can be sorted by.
+var ProductSort = pagination.Sortable{Fields: map[string]string{
+	"nama_produk":    "nama_produk",
+	"harga_konsumen": "harga_konsumen",
+	"harga_reseller": "harga_reseller",
+	"stok":           "stok",
+	"rating":         "rating",
+	"jumlah_ulasan":  "jumlah_ulasan",
+	"created_at":     "created_at",
+	"updated_at":     "updated_at",
+}}
+
+// ProductFilter holds the optional filters of the product list. Empty
+// strings are ignored. Searching by name is up to the search index.
+type ProductFilter struct {
+	Page       pagination.Params
+	CategoryID string
+	StoreID    string
+	MaxPrice   *money.Amount
+	MinPrice   *money.Amount
+}
+
+// ProductKeys reads the values ProductSort sorts products by.
+var ProductKeys = pagination.Keys[models.Product]{
+	"id":             func(p models.Product) interface{} { return p.ID },
+	"nama_produk":    func(p models.Product) interface{} { return p.Name },
+	"harga_konsumen": func(p models.Product) interface{} { return p.ConsumerPrice },
+	"harga_reseller": func(p models.Product) interface{} { return p.ResellerPrice },
+	"stok":           func(p models.Product) interface{} { return p.Stock },
+	"rating":         func(p models.Product) interface{} { return p.Rating },
+	"jumlah_ulasan":  func(p models.Product) interface{} { return p.RatingCount },
+	"created_at":     func(p models.Product) interface{} { return p.CreatedAt },
+	"updated_at":     func(p models.Product) interface{} { return p.UpdatedAt },
+}
+
+// ProductChanges holds the fields of a product to update. Nil fields keep
+// their value.
+type ProductChanges struct {
+	Name          *string
+	Slug          *string
+	CategoryID    *uint
+	ResellerPrice *money.Amount
+	ConsumerPrice *money.Amount
+	Stock         *int
+	Description   *string
+	// PhotoURLs are stored after the current photos of the product.
+	PhotoURLs []string
+}
+
+type ProductRepository interface {
+	List(ctx context.Context, filter ProductFilter) ([]models.Product, int64, error)
+	FindByID(ctx context.Context, id uint) (models.Product, error)
+	// FindByIDs returns those products of ids that exist, in no particular
+	// order.
+	FindByIDs(ctx context.Context, ids []uint) ([]models.Product, error)
+	// ChangedSince returns the products created or updated at or after
+	// since, and the ids of those deleted since.
+	ChangedSince(ctx context.Context, since time.Time) (changed []models.Product, deleted []uint, err error)
+	// FindBySlug returns the product whose current slug is slug.
+	FindBySlug(ctx context.Context, slug string) (models.Product, error)
+	// FindPreviousSlug returns the history entry of slug, a slug a product
+	// had before it was renamed.
+	FindPreviousSlug(ctx context.Context, slug string) (models.ProductSlug, error)
+	// SlugTaken reports whether a product other than productID has slug,
+	// deleted products included, or had it before.
+	SlugTaken(ctx context.Context, slug string, productID uint) (bool, error)
+	// Create stores product together with its Photos, or nothing at all.
+	// It returns ErrDuplicate when the slug of product is taken.
+	Create(ctx context.Context, product *models.Product) error
+	// Update writes the fields set in changes and nothing else, so it does
+	// not undo stock taken by orders in the meantime. A new slug moves the
+	// current one to the history of the product; it returns ErrDuplicate
+	// when another product has the new slug. Nothing of changes is stored
+	// when a part of it fails.
+	Update(ctx context.Context, id uint, changes ProductChanges) error
+	Delete(ctx context.Context, id uint) error
+	// DeletePhoto removes one photo of productID. Variants showing it are
+	// left without a photo.
+	DeletePhoto(ctx context.Context, productID, photoID uint) error
+	DeletePhotos(ctx context.Context, productID uint) error
+	// ReorderPhotos puts the photos of productID in the order of ids, which
+	// lists each of them once.
+	ReorderPhotos(ctx context.Context, productID uint, ids []uint) error
+	// SetVariants replaces the options and variants of a product in one
+	// database transaction, filling in their ids. Variants are matched to
+	// the stored ones by SKU, so those kept keep their id; the others are
+	// soft deleted, since orders refer to them. While variants remain, the
+	// stock and prices of the product are taken from them. It returns
+	// ErrDuplicate when a SKU belongs to another product.
+	SetVariants(ctx context.Context, productID uint, options []models.ProductOption, variants []models.ProductVariant) error
+}
+
+// StatusChange is one transition of an order, applied only while the order
+// is still in status From.
+type StatusChange struct {
+	From      string
+	To        string
+	ActorID   uint
+	ActorRole string
+	Note      string
+	// TrackingNumber replaces the stored one when not empty.
+	TrackingNumber string
+	// Restock puts the ordered quantities back into stock.
+	Restock bool
+	// Ledger entries are recorded with the change, so commission is
+	// credited exactly once with the order completing.
+	Ledger []models.LedgerEntry
+}
+
+// StoreOrderFilter selects the order lines of one store. Zero values are
+// ignored; To is exclusive.
+type StoreOrderFilter struct {
+	StoreID     uint
+	Page        int
+	Limit       int
+	Status      string
+	InvoiceCode string
+	From        time.Time
+	To          time.Time
+}
+
+// TransactionSort is what the transaction list can be sorted by, newest
+// first by default.
+var TransactionSort = pagination.Sortable{
+	Fields: map[string]string{
+		"created_at":  "created_at",
+		"harga_total": "harga_total",
+	},
+	Default: "created_at:desc",
+}
+
+// TransactionKeys reads the values TransactionSort sorts transactions by.
+var TransactionKeys = pagination.Keys[models.Transaction]{
+	"id":          func(t models.Transaction) interface{} { return t.ID },
+	"created_at":  func(t models.Transaction) interface{} { return t.CreatedAt },
+	"harga_total": func(t models.Transaction) interface{} { return t.TotalPrice },
+}
+
+// TransactionFilter selects a page of the transactions of a buyer.
+type TransactionFilter struct {
+	UserID uint
+	Page   pagination.Params
+}
+
+type TransactionRepository interface {
+	// Create places one order per store selling items, each a copy of trx
+	// with the lines of that store, so every order has a single seller to
+	// fulfil it. It stores the headers, decrements stock and snapshots
+	// every product into a ProductLog, all in one database transaction.
+	// Lines of a variant take stock from the variant as well as the
+	// product. It fills in the totals from the locked product rows and
+	// returns an *InsufficientStockError when any product is short, or
+	// ErrNotFound when a line names no variant of a product with variants,
+	// or a variant of another product. The initial status is recorded as
+	// the first history entry, placed by the buyer. The invoice number of
+	// each order is taken from the sequence of its store for the current
+	// day, in the same transaction, so it is unique across replicas and
+	// rolled back orders leave no gaps. Lines are priced for trx.OrderType.
+	// The orders are returned in the order their stores first appear in
+	// items. Items ordered from a cart line take their quantity off the
+	// line, deleting it once nothing is left, in the same transaction; so
+	// quantity added to the line meanwhile stays in the cart.
+	Create(ctx context.Context, trx models.Transaction, items []models.TrxItemRequest) ([]models.Transaction, error)
+	ListByUserID(ctx context.Context, userID uint) ([]models.Transaction, error)
+	// List returns a page of the transactions of filter.UserID and their
+	// number.
+	List(ctx context.Context, filter TransactionFilter) ([]models.Transaction, int64, error)
+	// FindByID returns the transaction with its details and status history.
+	FindByID(ctx context.Context, id uint) (models.Transaction, error)
+	// FindDetail returns an order line with its transaction and ProductLog.
+	FindDetail(ctx context.Context, id uint) (models.TransactionDetail, error)
+	// FindByInvoiceCode is FindByID by invoice number.
+	FindByInvoiceCode(ctx context.Context, code string) (models.Transaction, error)
+	// UpdateStatus applies change and records it in the status history in
+	// one database transaction. It returns ErrStatusChanged when the order
+	// is no longer in change.From, so concurrent transitions cannot both win.
+	UpdateStatus(ctx context.Context, id uint, change StatusChange) error
+	// ListUnpaid returns up to limit orders with an id above afterID still
+	// awaiting payment that were placed before the given time, by id.
+	ListUnpaid(ctx context.Context, placedBefore time.Time, afterID uint, limit int) ([]models.Transaction, error)
+	// ListStoreLines returns the lines sold by a store, newest first, with
+	// their transaction and its address, plus the number of matching lines.
+	ListStoreLines(ctx context.Context, filter StoreOrderFilter) ([]models.TransactionDetail, int64, error)
+}
+
+type CartRepository interface {
+	// FindByUserID returns the cart with its items and their products, with
+	// the active variants. Items of deleted products keep a zero Product.
+	FindByUserID(ctx context.Context, userID uint) (models.Cart, error)
+	// AddItem creates the cart on first use and adds quantity to the line of
+	// the product and variant, creating the line if needed. variantID is 0
+	// for products without variants.
+	AddItem(ctx context.Context, userID, productID, variantID uint, quantity int) error
+	UpdateItemQuantity(ctx context.Context, itemID uint, quantity int) error
+	DeleteItem(ctx context.Context, itemID uint) error
+	// Clear removes every item of the cart of userID.
+	Clear(ctx context.Context, userID uint) error
+}
+
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *models.Payment) error
+	FindByCharge(ctx context.Context, provider, chargeID string) (models.Payment, error)
+	// LatestByTransactionID returns the most recent charge of an order.
+	LatestByTransactionID(ctx context.Context, trxID uint) (models.Payment, error)
+	// UpdateStatus moves a payment from status from to status to, setting
+	// PaidAt when paidAt is not nil. It returns ErrStatusChanged when the
+	// payment is no longer in status from.
+	UpdateStatus(ctx context.Context, id uint, from, to string, paidAt *time.Time) error
+}
+
+type IdempotencyRepository interface {
+	// Create claims the key of record for its user. It returns ErrDuplicate
+	// when the user already has a record with that key.
+	Create(ctx context.Context, record *models.IdempotencyKey) error
+	Find(ctx context.Context, userID uint, key string) (models.IdempotencyKey, error)
+	// Complete stores the response of the request that claimed the key.
+	Complete(ctx context.Context, id uint, statusCode int, contentType string, response []byte) error
+	Delete(ctx context.Context, id uint) error
+	// DeleteExpired removes the records that expired before the given time
+	// and returns how many there were.
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// PayoutFilter selects payouts. Zero values are ignored.
+type PayoutFilter struct {
+	UserID uint
+	Status string
+	Page   int
+	Limit  int
+}
+
+// payoutEntry is the ledger entry of a payout: its debit when requested,
+// or the credit giving the amount back when rejected.
+func payoutEntry(p models.Payout, entryType string) models.LedgerEntry {
+	entry := models.LedgerEntry{
+		UserID:    p.UserID,
+		Type:      entryType,
+		Amount:    p.Amount,
+		Reference: fmt.Sprintf("payout:%d", p.ID),
+		PayoutID:  &p.ID,
+		Note:      fmt.Sprintf("payout to %s %s", p.BankName, p.AccountNumber),
+	}
+	if entryType == models.LedgerCredit {
+		entry.Reference += ":rejected"
+		entry.Note = "payout rejected"
+		if p.Note != "" {
+			entry.Note += ": " + p.Note
+		}
+	}
+	return entry
+}
+
+type LedgerRepository interface {
+	// Balance sums the ledger of userID, with the payouts still pending.
+	Balance(ctx context.Context, userID uint) (models.CommissionBalance, error)
+	// ListEntries returns the ledger of userID, newest first, and its size.
+	ListEntries(ctx context.Context, userID uint, page, limit int) ([]models.LedgerEntry, int64, error)
+	// RequestPayout stores payout as pending and debits its amount in one
+	// database transaction, holding a lock on the user so concurrent
+	// requests cannot overdraw. It returns ErrInsufficientBalance when the
+	// balance is short.
+	RequestPayout(ctx context.Context, payout *models.Payout) error
+	FindPayout(ctx context.Context, id uint) (models.Payout, error)
+	// ListPayouts returns the matching payouts, newest first, and their
+	// number.
+	ListPayouts(ctx context.Context, filter PayoutFilter) ([]models.Payout, int64, error)
+	// ResolvePayout moves a pending payout to status, approved or rejected,
+	// on behalf of adminID. Rejected payouts are credited back in the same
+	// database transaction. It returns ErrStatusChanged when the payout is
+	// no longer pending.
+	ResolvePayout(ctx context.Context, id uint, status string, adminID uint, note string) error
+}
+
+// ReviewSort is what review lists can be sorted by, newest first by
+// default.
+var ReviewSort = pagination.Sortable{
+	Fields: map[string]string{
+		"created_at": "created_at",
+		"rating":     "rating",
+	},
+	Default: "created_at:desc",
+}
+
+// ReviewKeys reads the values ReviewSort sorts reviews by.
+var ReviewKeys = pagination.Keys[models.Review]{
+	"id":         func(r models.Review) interface{} { return r.ID },
+	"created_at": func(r models.Review) interface{} { return r.CreatedAt },
+	"rating":     func(r models.Review) interface{} { return r.Rating },
+}
+
+// ReviewFilter selects a page of reviews. Zero values are ignored; hidden
+// reviews are left out unless Hidden says otherwise.
+type ReviewFilter struct {
+	ProductID uint
+	StoreID   uint
+	UserID    uint
+	Rating    int
+	Hidden    *bool
+	Page      pagination.Params
+}
+
+type ReviewRepository interface {
+	// Create stores review with its photos and adds its rating to those of
+	// its product and store, in one database transaction. It returns
+	// ErrDuplicate when the order line already has a review.
+	Create(ctx context.Context, review *models.Review) error
+	// FindByID returns the review with its photos and ProductLog.
+	FindByID(ctx context.Context, id uint) (models.Review, error)
+	// List returns the matching reviews with their photos and ProductLog,
+	// and their number.
+	List(ctx context.Context, filter ReviewFilter) ([]models.Review, int64, error)
+	// Reply sets the reply of the seller, replacing any earlier one.
+	Reply(ctx context.Context, id uint, reply string) error
+	// SetHidden hides a review on behalf of adminID, taking its rating out
+	// of those of its product and store, or shows it again, in one database
+	// transaction. It returns ErrStatusChanged when the review is already
+	// hidden or shown, so a rating is never counted twice.
+	SetHidden(ctx context.Context, id uint, hidden bool, adminID uint, reason string) error
+}
+
+// WishlistSort is what wishlists can be sorted by, last saved first by
+// default.
+var WishlistSort = pagination.Sortable{
+	Fields: map[string]string{
+		"created_at": "created_at",
+	},
+	Default: "created_at:desc",
+}
+
+// WishlistKeys reads the values WishlistSort sorts wishlist items by.
+var WishlistKeys = pagination.Keys[models.WishlistItem]{
+	"id":         func(i models.WishlistItem) interface{} { return i.ID },
+	"created_at": func(i models.WishlistItem) interface{} { return i.CreatedAt },
+}
+
+type WishlistRepository interface {
+	// Add saves item. It returns ErrDuplicate when the user already saved
+	// the product.
+	Add(ctx context.Context, item *models.WishlistItem) error
+	// Remove returns ErrNotFound when userID did not save productID.
+	Remove(ctx context.Context, userID, productID uint) error
+	// List returns a page of the items of userID with their products, plus
+	// the number of items. Items of deleted products keep a zero Product.
+	List(ctx context.Context, userID uint, page pagination.Params) ([]models.WishlistItem, int64, error)
+	// Saved returns which of productIDs userID saved.
+	Saved(ctx context.Context, userID uint, productIDs []uint) (map[uint]bool, error)
+}
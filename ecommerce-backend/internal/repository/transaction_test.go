@@ -0,0 +1,216 @@
+package repository_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/migrations"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/config"
+	"ecommerce-backend/pkg/database"
+	"ecommerce-backend/pkg/migrate"
+	"ecommerce-backend/pkg/money"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// openTestDB opens the repositories on a migrated database.
+func openTestDB(t *testing.T) *repository.Repositories {
+	t.Helper()
+	return repository.NewGormRepositories(openDB(t))
+}
+
+// openDB opens a migrated database. It uses a SQLite file by default; set
+// TEST_DB_DRIVER and TEST_DB_DSN to run against MySQL or Postgres, where
+// the SELECT ... FOR UPDATE path is exercised for real.
+func openDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	cfg := config.DatabaseConfig{
+		Driver:       config.DriverSQLite,
+		DSN:          filepath.Join(t.TempDir(), "test.db"),
+		MaxOpenConns: 10,
+		MaxIdleConns: 10,
+	}
+	if driver := os.Getenv("TEST_DB_DRIVER"); driver != "" {
+		cfg.Driver = driver
+		cfg.DSN = os.Getenv("TEST_DB_DSN")
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if _, err := migrate.New(db, migrations.All()).Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+type fixture struct {
+	userID    uint
+	addressID uint
+	productID uint
+}
+
+func seed(t *testing.T, repos *repository.Repositories, stock int) fixture {
+	t.Helper()
+	ctx := context.Background()
+
+	user := models.User{Name: "Buyer", Phone: "0811", Email: "buyer@example.com"}
+	must(t, repos.Users.Create(ctx, &user))
+	store := models.Store{UserID: user.ID, Name: "Toko"}
+	must(t, repos.Stores.Create(ctx, &store))
+	category := models.Category{Name: "Baju"}
+	must(t, repos.Categories.Create(ctx, &category))
+	product := models.Product{StoreID: store.ID, CategoryID: category.ID, Name: "Kaos", ConsumerPrice: money.Rupiah(15000), Stock: stock}
+	must(t, repos.Products.Create(ctx, &product))
+	address := models.Address{UserID: user.ID, Title: "Rumah"}
+	must(t, repos.Addresses.Create(ctx, &address))
+
+	return fixture{userID: user.ID, addressID: address.ID, productID: product.ID}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateTransactionDoesNotOversell(t *testing.T) {
+	implementations := map[string]func(t *testing.T) *repository.Repositories{
+		"gorm":   openTestDB,
+		"memory": func(*testing.T) *repository.Repositories { return repository.NewMemoryRepositories() },
+	}
+
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			const stock, buyers = 10, 40
+			repos := open(t)
+			f := seed(t, repos, stock)
+
+			var (
+				wg        sync.WaitGroup
+				mu        sync.Mutex
+				succeeded int
+				short     int
+				unexpect  []error
+			)
+			for i := 0; i < buyers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					trx := models.Transaction{UserID: f.userID, AddressID: f.addressID}
+					_, err := repos.Transactions.Create(context.Background(), trx, []models.TrxItemRequest{
+						{ProductID: f.productID, Kuantitas: 1},
+					})
+
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case err == nil:
+						succeeded++
+					case errors.Is(err, repository.ErrInsufficientStock):
+						short++
+					default:
+						unexpect = append(unexpect, err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if len(unexpect) > 0 {
+				t.Fatalf("unexpected errors: %v", unexpect)
+			}
+			if succeeded != stock || short != buyers-stock {
+				t.Fatalf("got %d orders and %d shortages, want %d and %d", succeeded, short, stock, buyers-stock)
+			}
+
+			product, err := repos.Products.FindByID(context.Background(), f.productID)
+			must(t, err)
+			if product.Stock != 0 {
+				t.Fatalf("stock = %d, want 0", product.Stock)
+			}
+
+			trxs, err := repos.Transactions.ListByUserID(context.Background(), f.userID)
+			must(t, err)
+			var sold int
+			for _, trx := range trxs {
+				for _, d := range trx.Details {
+					sold += d.Quantity
+				}
+			}
+			if sold != stock {
+				t.Fatalf("sold %d units, want %d", sold, stock)
+			}
+		})
+	}
+}
+
+func TestCreateTransactionReportsShortages(t *testing.T) {
+	repos := openTestDB(t)
+	f := seed(t, repos, 3)
+
+	trx := models.Transaction{UserID: f.userID, AddressID: f.addressID}
+	_, err := repos.Transactions.Create(context.Background(), trx, []models.TrxItemRequest{
+		{ProductID: f.productID, Kuantitas: 2},
+		{ProductID: f.productID, Kuantitas: 2},
+	})
+
+	var stockErr *repository.InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		t.Fatalf("err = %v, want *InsufficientStockError", err)
+	}
+	want := repository.StockShortage{ProductID: f.productID, Name: "Kaos", Requested: 4, Available: 3}
+	if len(stockErr.Items) != 1 || stockErr.Items[0] != want {
+		t.Fatalf("shortages = %+v, want [%+v]", stockErr.Items, want)
+	}
+
+	product, err := repos.Products.FindByID(context.Background(), f.productID)
+	must(t, err)
+	if product.Stock != 3 {
+		t.Fatalf("stock = %d after failed order, want 3", product.Stock)
+	}
+	trxs, err := repos.Transactions.ListByUserID(context.Background(), f.userID)
+	must(t, err)
+	if len(trxs) != 0 {
+		t.Fatalf("failed order left %d transactions behind", len(trxs))
+	}
+}
+
+func TestCreateTransactionTakesOrderedQuantityOffCart(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := open(t)
+			f := seed(t, repos, 10)
+			other := models.Product{StoreID: 1, CategoryID: 1, Name: "Topi", Slug: "topi", ConsumerPrice: money.Rupiah(5000), Stock: 10}
+			must(t, repos.Products.Create(ctx, &other))
+
+			must(t, repos.Carts.AddItem(ctx, f.userID, f.productID, 0, 2))
+			must(t, repos.Carts.AddItem(ctx, f.userID, other.ID, 0, 1))
+			cart, err := repos.Carts.FindByUserID(ctx, f.userID)
+			must(t, err)
+			var items []models.TrxItemRequest
+			for _, line := range cart.Items {
+				items = append(items, models.TrxItemRequest{ProductID: line.ProductID, Kuantitas: line.Quantity, CartItemID: line.ID})
+			}
+
+			// the buyer adds to a line while the checkout runs
+			must(t, repos.Carts.AddItem(ctx, f.userID, f.productID, 0, 3))
+			_, err = repos.Transactions.Create(ctx, models.Transaction{UserID: f.userID, AddressID: f.addressID}, items)
+			must(t, err)
+
+			cart, err = repos.Carts.FindByUserID(ctx, f.userID)
+			must(t, err)
+			if len(cart.Items) != 1 || cart.Items[0].ProductID != f.productID || cart.Items[0].Quantity != 3 {
+				t.Fatalf("cart after checkout = %+v, want the 3 added meanwhile", cart.Items)
+			}
+		})
+	}
+}
@@ -0,0 +1,512 @@
+// Package search is the embedded full-text index of products. It keeps an
+// inverted index of their name, category, store, variants and description
+// in memory, ranks matches with BM25 over those weighted fields, tolerates
+// typos and counts facets by category, store and price.
+//
+// Every server process holds its own index: the services update it on every
+// product change they make, Sync applies the changes other replicas made to
+// the database since, and Rebuild reloads it whole at startup and now and
+// then to pick up renamed stores and categories.
+package search
+
+import (
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Fields of a product, by weight: a match in the name counts three times as
+// much as one in the store name, one in the description half as much.
+const (
+	fieldName = iota
+	fieldCategory
+	fieldStore
+	fieldVariants
+	fieldDescription
+	numFields
+)
+
+var fieldWeights = [numFields]float64{3, 1.5, 1, 1, 0.5}
+
+// BM25 parameters: term frequency saturation and length normalization.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Match weights of the indexed terms a query word matches. Its stems and
+// longer words it begins score a little less than the word itself, words
+// one or two typos away less again.
+const (
+	weightExact  = 1.0
+	weightStem   = 0.9
+	weightPrefix = 0.8
+	weightTypo1  = 0.6
+	weightTypo2  = 0.4
+)
+
+// minPrefix is the length of the shortest last query word completed to
+// longer words, so "kao" finds "kaos" while searches are typed.
+const minPrefix = 3
+
+// PriceBuckets are the upper bounds of the price facet; the last bucket
+// holds everything from the last bound up.
+var PriceBuckets = []money.Amount{
+	money.Rupiah(50_000),
+	money.Rupiah(100_000),
+	money.Rupiah(250_000),
+	money.Rupiah(500_000),
+	money.Rupiah(1_000_000),
+}
+
+// Query is one search. Zero fields do not filter.
+type Query struct {
+	Text       string
+	CategoryID uint
+	StoreID    uint
+	MinPrice   *money.Amount
+	MaxPrice   *money.Amount
+	Offset     int
+	Limit      int
+}
+
+// Result is one page of matches, best first, with the total number of
+// matches and their facets.
+type Result struct {
+	IDs    []uint
+	Total  int
+	Facets models.SearchFacets
+}
+
+type document struct {
+	id         uint
+	categoryID uint
+	category   string
+	storeID    uint
+	store      string
+	price      money.Amount
+	texts      [numFields]string
+	// terms holds the weighted frequency of every word and stem, length
+	// the weighted number of words.
+	terms  map[string]float64
+	length float64
+}
+
+// analyze fills in the terms of d from its texts.
+func (d *document) analyze() {
+	d.texts[fieldCategory], d.texts[fieldStore] = d.category, d.store
+	d.terms = make(map[string]float64)
+	d.length = 0
+	for f, text := range d.texts {
+		for _, word := range words(text) {
+			for _, term := range forms(word) {
+				d.terms[term] += fieldWeights[f]
+			}
+			d.length += fieldWeights[f]
+		}
+	}
+}
+
+func newDocument(p models.Product) *document {
+	d := &document{
+		id:         p.ID,
+		categoryID: p.CategoryID,
+		category:   p.Category.Name,
+		storeID:    p.StoreID,
+		store:      p.Store.Name,
+		price:      p.ConsumerPrice,
+	}
+	variants := make([]string, 0, 2*len(p.Variants))
+	for _, v := range p.Variants {
+		variants = append(variants, v.Name, v.SKU)
+	}
+	d.texts[fieldName] = p.Name
+	d.texts[fieldVariants] = strings.Join(variants, " ")
+	d.texts[fieldDescription] = p.Description
+	d.analyze()
+	return d
+}
+
+// Index is safe for concurrent use.
+type Index struct {
+	mu   sync.RWMutex
+	docs map[uint]*document
+	// postings maps every term to the weighted frequency of the term in
+	// the documents that have it.
+	postings    map[string]map[uint]float64
+	totalLength float64
+	// version counts the calls to Put and Delete, changed holds the
+	// version of the last of them for every product.
+	version uint64
+	changed map[uint]uint64
+}
+
+func New() *Index {
+	return &Index{
+		docs:     make(map[uint]*document),
+		postings: make(map[string]map[uint]float64),
+		changed:  make(map[uint]uint64),
+	}
+}
+
+// Version returns the current version of the index. Products loaded from
+// the database after it is taken go to Rebuild and Sync with it.
+func (ix *Index) Version() uint64 {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return ix.version
+}
+
+// Put adds p to the index or replaces its previous version. It expects
+// the store and category of p loaded.
+func (ix *Index) Put(p models.Product) {
+	d := newDocument(p)
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.touch(p.ID)
+	ix.remove(p.ID)
+	ix.add(d)
+}
+
+// Delete removes a product from the index.
+func (ix *Index) Delete(id uint) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.touch(id)
+	ix.remove(id)
+}
+
+// Rebuild replaces the whole index with products, loaded from the database
+// after version was taken. Products Put or Deleted since keep what they
+// got then, which is at least as new as what was loaded.
+func (ix *Index) Rebuild(products []models.Product, version uint64) {
+	docs := make([]*document, 0, len(products))
+	for _, p := range products {
+		docs = append(docs, newDocument(p))
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	old := ix.docs
+	ix.docs = make(map[uint]*document, len(docs))
+	ix.postings = make(map[string]map[uint]float64)
+	ix.totalLength = 0
+	for _, d := range docs {
+		if ix.changed[d.id] <= version {
+			ix.add(d)
+		}
+	}
+	for id, v := range ix.changed {
+		if d, ok := old[id]; ok && v > version {
+			ix.add(d)
+		}
+	}
+}
+
+// Sync puts changed and removes the products of deleted, all loaded from
+// the database after version was taken. Like Rebuild, it leaves alone the
+// products Put or Deleted since.
+func (ix *Index) Sync(changed []models.Product, deleted []uint, version uint64) {
+	docs := make([]*document, 0, len(changed))
+	for _, p := range changed {
+		docs = append(docs, newDocument(p))
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for _, d := range docs {
+		if ix.changed[d.id] <= version {
+			ix.remove(d.id)
+			ix.add(d)
+		}
+	}
+	for _, id := range deleted {
+		if ix.changed[id] <= version {
+			ix.remove(id)
+		}
+	}
+}
+
+// RenameCategory updates the category name of the indexed products.
+func (ix *Index) RenameCategory(id uint, name string) {
+	ix.rename(func(d *document) bool { return d.categoryID == id }, func(d *document) { d.category = name })
+}
+
+// RenameStore updates the store name of the indexed products.
+func (ix *Index) RenameStore(id uint, name string) {
+	ix.rename(func(d *document) bool { return d.storeID == id }, func(d *document) { d.store = name })
+}
+
+func (ix *Index) rename(match func(*document) bool, set func(*document)) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for id, d := range ix.docs {
+		if !match(d) {
+			continue
+		}
+		renamed := *d
+		set(&renamed)
+		renamed.analyze()
+		ix.remove(id)
+		ix.add(&renamed)
+	}
+}
+
+// touch records a change of product id. Callers hold mu for writing.
+func (ix *Index) touch(id uint) {
+	ix.version++
+	ix.changed[id] = ix.version
+}
+
+// add indexes d. Callers hold mu for writing.
+func (ix *Index) add(d *document) {
+	ix.docs[d.id] = d
+	ix.totalLength += d.length
+	for term, freq := range d.terms {
+		if ix.postings[term] == nil {
+			ix.postings[term] = make(map[uint]float64)
+		}
+		ix.postings[term][d.id] = freq
+	}
+}
+
+// remove unindexes a document if present. Callers hold mu for writing.
+func (ix *Index) remove(id uint) {
+	d, ok := ix.docs[id]
+	if !ok {
+		return
+	}
+	for term := range d.terms {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	ix.totalLength -= d.length
+	delete(ix.docs, id)
+}
+
+// expansion is an indexed term a query word matches, and how well.
+type expansion struct {
+	term   string
+	weight float64
+}
+
+// expand returns the indexed terms matching a query word: the word and its
+// stems, longer words it begins when it is the last query word, and, when
+// the word itself is not indexed, words a few typos away. Callers hold mu.
+func (ix *Index) expand(word string, last bool) []expansion {
+	var matches []expansion
+	_, exact := ix.postings[word]
+	for i, term := range forms(word) {
+		if _, ok := ix.postings[term]; !ok {
+			continue
+		}
+		weight := weightStem
+		if i == 0 {
+			weight = weightExact
+		}
+		matches = append(matches, expansion{term, weight})
+	}
+	prefix := last && len(word) >= minPrefix
+	typos := maxTypos(word)
+	if exact {
+		typos = 0
+	}
+	if !prefix && typos == 0 {
+		return matches
+	}
+	for indexed := range ix.postings {
+		if indexed == word {
+			continue
+		}
+		if prefix && strings.HasPrefix(indexed, word) {
+			matches = append(matches, expansion{indexed, weightPrefix})
+			continue
+		}
+		if typos == 0 {
+			continue
+		}
+		if d := distance(word, indexed, typos); d <= typos {
+			weight := weightTypo1
+			if d == 2 {
+				weight = weightTypo2
+			}
+			matches = append(matches, expansion{indexed, weight})
+		}
+	}
+	return matches
+}
+
+// Search returns the products matching every word of q.Text, or all
+// products when it is empty, that pass the filters of q. Matches are ranked
+// by relevance, then by id; without text by id alone.
+//
+// Facets count the matches as if the filter of their own dimension were
+// not set, so the other categories stay visible once one is picked.
+func (ix *Index) Search(q Query) Result {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	scores := ix.score(q.Text)
+	if scores == nil {
+		scores = make(map[uint]float64, len(ix.docs))
+		for id := range ix.docs {
+			scores[id] = 0
+		}
+	}
+
+	categories := make(map[uint]*models.FacetCount)
+	stores := make(map[uint]*models.FacetCount)
+	prices := make([]int, len(PriceBuckets)+1)
+	var hits []uint
+	for id := range scores {
+		d := ix.docs[id]
+		inCategory := q.CategoryID == 0 || d.categoryID == q.CategoryID
+		inStore := q.StoreID == 0 || d.storeID == q.StoreID
+		inPrice := (q.MinPrice == nil || d.price >= *q.MinPrice) && (q.MaxPrice == nil || d.price <= *q.MaxPrice)
+		if inStore && inPrice {
+			count(categories, d.categoryID, d.category)
+		}
+		if inCategory && inPrice {
+			count(stores, d.storeID, d.store)
+		}
+		if inCategory && inStore {
+			prices[bucket(d.price)]++
+		}
+		if inCategory && inStore && inPrice {
+			hits = append(hits, id)
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if si, sj := scores[hits[i]], scores[hits[j]]; si != sj {
+			return si > sj
+		}
+		return hits[i] < hits[j]
+	})
+	return Result{
+		IDs:   page(hits, q.Offset, q.Limit),
+		Total: len(hits),
+		Facets: models.SearchFacets{
+			Categories: sortedFacets(categories),
+			Stores:     sortedFacets(stores),
+			Prices:     priceFacets(prices),
+		},
+	}
+}
+
+// score ranks the documents matching every word of text, or returns nil
+// when text is blank. Callers hold mu.
+func (ix *Index) score(text string) map[uint]float64 {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	terms := dedupe(words(text))
+	scores := make(map[uint]float64)
+	if len(terms) == 0 || len(ix.docs) == 0 {
+		return scores
+	}
+
+	n := float64(len(ix.docs))
+	avgLength := ix.totalLength / n
+	for i, term := range terms {
+		// A document counts the best expansion of every query word
+		best := make(map[uint]float64)
+		for _, e := range ix.expand(term, i == len(terms)-1) {
+			docs := ix.postings[e.term]
+			df := float64(len(docs))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			for id, freq := range docs {
+				norm := freq * (k1 + 1) / (freq + k1*(1-b+b*ix.docs[id].length/avgLength))
+				if s := e.weight * idf * norm; s > best[id] {
+					best[id] = s
+				}
+			}
+		}
+		if i == 0 {
+			scores = best
+			continue
+		}
+		for id, s := range scores {
+			if t, ok := best[id]; ok {
+				scores[id] = s + t
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+	return scores
+}
+
+func dedupe(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := terms[:0]
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	return unique
+}
+
+func count(facets map[uint]*models.FacetCount, id uint, name string) {
+	if f, ok := facets[id]; ok {
+		f.Count++
+		return
+	}
+	facets[id] = &models.FacetCount{ID: id, Name: name, Count: 1}
+}
+
+// sortedFacets orders facets by count, then name.
+func sortedFacets(facets map[uint]*models.FacetCount) []models.FacetCount {
+	sorted := make([]models.FacetCount, 0, len(facets))
+	for _, f := range facets {
+		sorted = append(sorted, *f)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
+
+// bucket returns the index of the price bucket of price.
+func bucket(price money.Amount) int {
+	return sort.Search(len(PriceBuckets), func(i int) bool { return price < PriceBuckets[i] })
+}
+
+// priceFacets lists every price bucket with its count, empty ones included,
+// so the buckets do not shift between searches.
+func priceFacets(counts []int) []models.PriceFacet {
+	facets := make([]models.PriceFacet, len(counts))
+	for i, n := range counts {
+		facets[i].Count = n
+		if i > 0 {
+			facets[i].Min = PriceBuckets[i-1]
+		}
+		if i < len(PriceBuckets) {
+			bound := PriceBuckets[i]
+			facets[i].Max = &bound
+		}
+	}
+	return facets
+}
+
+func page(ids []uint, offset, limit int) []uint {
+	if offset >= len(ids) {
+		return []uint{}
+	}
+	ids = ids[max(offset, 0):]
+	if limit > 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+	return ids
+}
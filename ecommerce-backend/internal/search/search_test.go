@@ -0,0 +1,157 @@
+package search_test
+
+import (
+	"ecommerce-backend/internal/search"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"slices"
+	"testing"
+)
+
+func product(id, categoryID, storeID uint, name, description string, price int64) models.Product {
+	return models.Product{
+		ID:            id,
+		Name:          name,
+		Description:   description,
+		ConsumerPrice: money.Rupiah(price),
+		CategoryID:    categoryID,
+		Category:      models.Category{ID: categoryID, Name: map[uint]string{1: "Pakaian", 2: "Sepatu"}[categoryID]},
+		StoreID:       storeID,
+		Store:         models.Store{ID: storeID, Name: map[uint]string{1: "Toko Andi", 2: "Toko Budi"}[storeID]},
+	}
+}
+
+func catalog() *search.Index {
+	ix := search.New()
+	for _, p := range []models.Product{
+		product(1, 1, 1, "Kaos Polos", "Bahan katun", 45_000),
+		product(2, 1, 2, "Kemeja Flanel", "Cocok dipadukan dengan kaos", 120_000),
+		product(3, 2, 1, "Sepatu Lari", "Sol karet untuk berlari", 350_000),
+		product(4, 1, 1, "Kaos Kaki Kaos", "Sepasang", 15_000),
+		product(5, 2, 2, "Sandal Jepit", "", 20_000),
+	} {
+		ix.Put(p)
+	}
+	return ix
+}
+
+func TestRanking(t *testing.T) {
+	ix := catalog()
+	tests := []struct {
+		text string
+		want []uint
+	}{
+		// a match in the name beats one in the description, more matches
+		// beat fewer
+		{"kaos", []uint{4, 1, 2}},
+		{"kaos polos", []uint{1}},
+		{"lari", []uint{3}},
+		// the name of the store is searched too, shorter products first
+		{"budi", []uint{5, 2}},
+		{"", []uint{1, 2, 3, 4, 5}},
+		{"payung", []uint{}},
+	}
+	for _, tt := range tests {
+		got := ix.Search(search.Query{Text: tt.text})
+		if !slices.Equal(got.IDs, tt.want) || got.Total != len(tt.want) {
+			t.Errorf("Search(%q) = %v of %d, want %v", tt.text, got.IDs, got.Total, tt.want)
+		}
+	}
+
+	page := ix.Search(search.Query{Text: "kaos", Offset: 1, Limit: 1})
+	if !slices.Equal(page.IDs, []uint{1}) || page.Total != 3 {
+		t.Errorf("second page = %v of %d, want [1] of 3", page.IDs, page.Total)
+	}
+}
+
+func TestTypoTolerance(t *testing.T) {
+	ix := catalog()
+	tests := []struct {
+		text string
+		want uint
+	}{
+		{"kemja", 2},         // a letter missing
+		{"sepatu lar", 3},    // the last word is a prefix
+		{"flanel kemeja", 2}, // words in any order
+		{"sendal", 5},        // a letter changed
+		{"kao", 4},           // the last word is completed
+		{"berlari", 3},       // stems
+	}
+	for _, tt := range tests {
+		got := ix.Search(search.Query{Text: tt.text})
+		if len(got.IDs) == 0 || got.IDs[0] != tt.want {
+			t.Errorf("Search(%q) = %v, want %d first", tt.text, got.IDs, tt.want)
+		}
+	}
+
+	// the exact word does not widen to its neighbours
+	if got := ix.Search(search.Query{Text: "kaos kaki"}); !slices.Equal(got.IDs, []uint{4}) {
+		t.Errorf("Search(%q) = %v, want [4]", "kaos kaki", got.IDs)
+	}
+}
+
+func TestFacets(t *testing.T) {
+	ix := catalog()
+	maxPrice := money.Rupiah(100_000)
+	got := ix.Search(search.Query{CategoryID: 1, MaxPrice: &maxPrice})
+
+	if !slices.Equal(got.IDs, []uint{1, 4}) {
+		t.Fatalf("IDs = %v, want [1 4]", got.IDs)
+	}
+	// categories ignore the category filter, stores and prices their own
+	wantCategories := []models.FacetCount{{ID: 1, Name: "Pakaian", Count: 2}, {ID: 2, Name: "Sepatu", Count: 1}}
+	if !slices.Equal(got.Facets.Categories, wantCategories) {
+		t.Errorf("categories = %+v, want %+v", got.Facets.Categories, wantCategories)
+	}
+	wantStores := []models.FacetCount{{ID: 1, Name: "Toko Andi", Count: 2}}
+	if !slices.Equal(got.Facets.Stores, wantStores) {
+		t.Errorf("stores = %+v, want %+v", got.Facets.Stores, wantStores)
+	}
+	var counts []int
+	for _, f := range got.Facets.Prices {
+		counts = append(counts, f.Count)
+	}
+	if want := []int{2, 0, 1, 0, 0, 0}; !slices.Equal(counts, want) {
+		t.Errorf("price counts = %v, want %v", counts, want)
+	}
+	if p := got.Facets.Prices[0]; p.Min != 0 || p.Max == nil || *p.Max != search.PriceBuckets[0] {
+		t.Errorf("first price bucket = %+v, want up to %v", p, search.PriceBuckets[0])
+	}
+	if p := got.Facets.Prices[len(got.Facets.Prices)-1]; p.Max != nil {
+		t.Errorf("last price bucket = %+v, want no upper bound", p)
+	}
+}
+
+func TestRebuildKeepsNewerChanges(t *testing.T) {
+	ix := catalog()
+	version := ix.Version()
+
+	// while a rebuild loads the products, this process renames one and
+	// deletes another
+	ix.Put(product(1, 1, 1, "Kaos Oblong", "", 45_000))
+	ix.Delete(3)
+	loaded := []models.Product{
+		product(1, 1, 1, "Kaos Polos", "", 45_000),
+		product(2, 1, 2, "Kemeja Flanel", "", 120_000),
+		product(3, 2, 1, "Sepatu Lari", "", 350_000),
+	}
+	ix.Rebuild(loaded, version)
+
+	if got := ix.Search(search.Query{}); !slices.Equal(got.IDs, []uint{1, 2}) {
+		t.Fatalf("after the rebuild = %v, want [1 2]", got.IDs)
+	}
+	if got := ix.Search(search.Query{Text: "oblong"}); !slices.Equal(got.IDs, []uint{1}) {
+		t.Fatalf("the rename made during the rebuild is lost: %v", got.IDs)
+	}
+
+	// a sync loaded before a change does not undo it either
+	version = ix.Version()
+	ix.Put(product(2, 1, 2, "Kemeja Batik", "", 120_000))
+	ix.Sync([]models.Product{product(2, 1, 2, "Kemeja Flanel", "", 120_000), product(6, 2, 1, "Topi", "", 30_000)}, []uint{1}, version)
+	if got := ix.Search(search.Query{}); !slices.Equal(got.IDs, []uint{2, 6}) {
+		t.Fatalf("after the sync = %v, want [2 6]", got.IDs)
+	}
+	if got := ix.Search(search.Query{Text: "batik"}); !slices.Equal(got.IDs, []uint{2}) {
+		t.Fatalf("the change made during the sync is lost: %v", got.IDs)
+	}
+}
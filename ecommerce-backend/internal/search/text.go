@@ -0,0 +1,185 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// minStem is the length of the shortest stem affixes are removed down to.
+const minStem = 4
+
+// stopwords are Indonesian function words too common to tell products apart.
+var stopwords = map[string]bool{
+	"yang": true, "dan": true, "di": true, "ke": true, "dari": true,
+	"untuk": true, "dengan": true, "ini": true, "itu": true, "atau": true,
+	"pada": true, "dalam": true, "adalah": true, "juga": true, "akan": true,
+	"bisa": true, "ada": true, "sudah": true, "oleh": true, "sebagai": true,
+	"karena": true, "agar": true, "para": true, "serta": true, "tersebut": true,
+}
+
+// words splits text into the words it is indexed and searched by:
+// lowercase letters and digits with accents stripped, in order, without
+// stopwords and without particles and possessives.
+func words(text string) []string {
+	var found []string
+	fields := strings.FieldsFunc(fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range fields {
+		if stopwords[word] {
+			continue
+		}
+		if isAlpha(word) {
+			word = trimSuffix(word, "lah", "kah", "tah", "pun")
+			word = trimSuffix(word, "nya", "ku", "mu")
+		}
+		found = append(found, word)
+	}
+	return found
+}
+
+// fold lowercases text and strips accents. Apostrophes are dropped so
+// "jum'at" stays one word.
+func fold(text string) string {
+	var b strings.Builder
+	for _, r := range norm.NFKD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r), r == '\'', r == '’':
+		default:
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// forms returns word followed by the stems it may have: without its
+// derivational suffix (-kan, -an, -i), without its prefix, and without
+// both, after Nazief and Adriani. Lacking their dictionary to tell which
+// stem is a word, all of them are indexed: "berbahan" is found by "bahan"
+// although "nyaman" also gives the non-word "nyam". Stems shorter than
+// minStem are not taken, so "bahan" and "meja" keep their affixes.
+func forms(word string) []string {
+	all := []string{word}
+	if len(word) <= minStem || !isAlpha(word) {
+		return all
+	}
+	suffixless := trimSuffix(word, "kan", "an", "i")
+	for _, f := range []string{
+		suffixless,
+		trimPrefix(word, false),
+		trimPrefix(suffixless, suffixless != word),
+	} {
+		if !slices.Contains(all, f) {
+			all = append(all, f)
+		}
+	}
+	return all
+}
+
+// trimSuffix removes the first of suffixes word ends with, unless that
+// leaves less than minStem letters.
+func trimSuffix(word string, suffixes ...string) string {
+	for _, s := range suffixes {
+		if strings.HasSuffix(word, s) && len(word)-len(s) >= minStem {
+			return word[:len(word)-len(s)]
+		}
+	}
+	return word
+}
+
+// trimPrefix removes one derivational prefix, restoring the first letter
+// nasal prefixes melt into: "menulis" gives "tulis", "memakai" "pakai".
+// ke- only goes with a suffix, as in "keamanan", since many nouns such as
+// "kemeja" start with ke.
+func trimPrefix(word string, suffixed bool) string {
+	for _, p := range []struct {
+		prefix, restore string
+	}{
+		{"meny", "s"}, {"peny", "s"},
+		{"meng", ""}, {"peng", ""},
+		{"mem", "p"}, {"pem", "p"},
+		{"men", "t"}, {"pen", "t"},
+		{"ber", ""}, {"ter", ""}, {"per", ""},
+		{"me", ""}, {"pe", ""}, {"di", ""},
+	} {
+		if !strings.HasPrefix(word, p.prefix) {
+			continue
+		}
+		rest := word[len(p.prefix):]
+		if p.restore != "" && rest != "" && isVowel(rest[0]) {
+			rest = p.restore + rest
+		}
+		if len(rest) >= minStem {
+			return rest
+		}
+		return word
+	}
+	if suffixed && strings.HasPrefix(word, "ke") && len(word)-2 >= minStem {
+		return word[2:]
+	}
+	return word
+}
+
+func isVowel(c byte) bool {
+	return strings.IndexByte("aiueo", c) >= 0
+}
+
+func isAlpha(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// maxTypos is how many edits a query term may be away from an indexed one:
+// none for short terms, where a typo is as likely to be another word.
+func maxTypos(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// distance is the optimal string alignment distance of a and b: the edits
+// (insertions, deletions, substitutions and swaps of neighbours) between
+// them. It gives up at limit+1.
+func distance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		best := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			best = min(best, cur[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+)
+
+type AddressService struct {
+	repos *repository.Repositories
+}
+
+func (s *AddressService) List(ctx context.Context, userID uint) ([]models.Address, error) {
+	return s.repos.Addresses.ListByUserID(ctx, userID)
+}
+
+// Get returns the address if it belongs to userID.
+func (s *AddressService) Get(ctx context.Context, userID, id uint) (models.Address, error) {
+	address, err := s.repos.Addresses.FindByID(ctx, id)
+	if err != nil {
+		return models.Address{}, wrap(err, "address")
+	}
+	if address.UserID != userID {
+		return models.Address{}, ErrForbidden
+	}
+	return address, nil
+}
+
+func (s *AddressService) Create(ctx context.Context, userID uint, address models.Address) (models.Address, error) {
+	address.ID = 0
+	address.UserID = userID
+	if err := s.repos.Addresses.Create(ctx, &address); err != nil {
+		return models.Address{}, err
+	}
+	return address, nil
+}
+
+// Update changes the receiver, phone and detail of the address.
+func (s *AddressService) Update(ctx context.Context, userID, id uint, input models.Address) (models.Address, error) {
+	address, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return models.Address{}, err
+	}
+
+	address.ReceiverName = input.ReceiverName
+	address.Phone = input.Phone
+	address.Detail = input.Detail
+	if err := s.repos.Addresses.Update(ctx, &address); err != nil {
+		return models.Address{}, err
+	}
+	return address, nil
+}
+
+func (s *AddressService) Delete(ctx context.Context, userID, id uint) error {
+	address, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	return s.repos.Addresses.Delete(ctx, address.ID)
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/utils"
+	"errors"
+)
+
+type AuthService struct {
+	repos *repository.Repositories
+}
+
+// Register creates the user together with their store.
+func (s *AuthService) Register(ctx context.Context, input models.RegisterRequest) (models.User, error) {
+	hashedPwd, err := utils.HashPassword(input.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user := models.User{
+		Name: input.Name, Phone: input.Phone, Email: input.Email,
+		Password: hashedPwd, DOB: input.DOB, Job: input.Job,
+		Gender: input.Gender, About: input.About,
+		ProvinceID: input.ProvinceID, CityID: input.CityID,
+	}
+	if err := s.repos.Users.Create(ctx, &user); err != nil {
+		return models.User{}, wrap(err, "user")
+	}
+
+	// Auto create store
+	store := models.Store{UserID: user.ID, Name: user.Name + "'s Store"}
+	if err := s.repos.Stores.Create(ctx, &store); err != nil {
+		return models.User{}, err
+	}
+	user.Store = store
+
+	return user, nil
+}
+
+// Login checks the credentials and returns the user with a signed token.
+func (s *AuthService) Login(ctx context.Context, input models.LoginRequest) (models.User, string, error) {
+	user, err := s.repos.Users.FindByPhone(ctx, input.Phone)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.User{}, "", ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, "", err
+	}
+	if !utils.CheckPassword(user.Password, input.Password) {
+		return models.User{}, "", ErrInvalidCredentials
+	}
+
+	token, err := utils.GenerateToken(user.ID, user.IsAdmin)
+	if err != nil {
+		return models.User{}, "", err
+	}
+	return user, token, nil
+}
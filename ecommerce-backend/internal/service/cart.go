@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"errors"
+	"fmt"
+)
+
+type CartService struct {
+	repos        *repository.Repositories
+	transactions *TransactionService
+}
+
+// Get returns the cart of userID priced with the current product data. A
+// user without a cart gets an empty one.
+func (s *CartService) Get(ctx context.Context, userID uint) (models.CartView, error) {
+	cart, err := s.find(ctx, userID)
+	if err != nil {
+		return models.CartView{}, err
+	}
+
+	view := models.CartView{Items: []models.CartLine{}, CanCheckout: len(cart.Items) > 0}
+	for _, item := range cart.Items {
+		p := item.Product
+		variant, variantErr := variantOf(p, item.VariantID)
+		line := models.CartLine{
+			ID:        item.ID,
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			StoreID:   p.StoreID,
+			Name:      p.Name,
+			Price:     p.ConsumerPrice,
+			Stock:     p.Stock,
+			Quantity:  item.Quantity,
+		}
+		if len(p.Photos) > 0 {
+			line.PhotoURL = p.Photos[0].URL
+		}
+		if variantErr != nil {
+			line.Stock = 0
+		}
+		if variant.ID != 0 {
+			line.SKU, line.VariantName = variant.SKU, variant.Name
+			line.Price, line.Stock = variant.ConsumerPrice, variant.Stock
+			if variant.Photo != nil {
+				line.PhotoURL = variant.Photo.URL
+			}
+		}
+		line.TotalPrice = line.Price.Mul(item.Quantity)
+		line.Available = p.ID != 0 && variantErr == nil && line.Stock >= item.Quantity
+		if !line.Available {
+			view.CanCheckout = false
+		}
+		view.Items = append(view.Items, line)
+		view.TotalItems += item.Quantity
+		view.TotalPrice += line.TotalPrice
+	}
+	return view, nil
+}
+
+// find returns the cart of userID, or an empty cart if there is none yet.
+func (s *CartService) find(ctx context.Context, userID uint) (models.Cart, error) {
+	cart, err := s.repos.Carts.FindByUserID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Cart{UserID: userID}, nil
+	}
+	return cart, err
+}
+
+// AddItem puts quantity more of the product into the cart. The line may not
+// exceed the current stock; checkout checks stock again.
+func (s *CartService) AddItem(ctx context.Context, userID uint, input models.CartItemRequest) error {
+	product, err := s.repos.Products.FindByID(ctx, input.ProductID)
+	if err != nil {
+		return wrap(err, "product")
+	}
+	variant, err := variantOf(product, input.VariantID)
+	if err != nil {
+		return err
+	}
+	cart, err := s.find(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	quantity := input.Kuantitas
+	for _, item := range cart.Items {
+		if item.ProductID == product.ID && item.VariantID == variant.ID {
+			quantity += item.Quantity
+		}
+	}
+	if err := checkStock(product, variant, quantity); err != nil {
+		return err
+	}
+	return wrap(s.repos.Carts.AddItem(ctx, userID, product.ID, variant.ID, input.Kuantitas), "cart item")
+}
+
+// UpdateItem sets the quantity of a line of the cart of userID.
+func (s *CartService) UpdateItem(ctx context.Context, userID, itemID uint, quantity int) error {
+	item, err := s.item(ctx, userID, itemID)
+	if err != nil {
+		return err
+	}
+	if item.Product.ID == 0 {
+		return fmt.Errorf("%w: product is no longer available", ErrInvalidInput)
+	}
+	variant, err := variantOf(item.Product, item.VariantID)
+	if err != nil {
+		return err
+	}
+	if err := checkStock(item.Product, variant, quantity); err != nil {
+		return err
+	}
+	return wrap(s.repos.Carts.UpdateItemQuantity(ctx, itemID, quantity), "cart item")
+}
+
+// RemoveItem drops a line from the cart of userID.
+func (s *CartService) RemoveItem(ctx context.Context, userID, itemID uint) error {
+	if _, err := s.item(ctx, userID, itemID); err != nil {
+		return err
+	}
+	return s.repos.Carts.DeleteItem(ctx, itemID)
+}
+
+func (s *CartService) Clear(ctx context.Context, userID uint) error {
+	return s.repos.Carts.Clear(ctx, userID)
+}
+
+// item returns the line itemID if it belongs to the cart of userID. Lines of
+// other carts are reported as missing.
+func (s *CartService) item(ctx context.Context, userID, itemID uint) (models.CartItem, error) {
+	cart, err := s.find(ctx, userID)
+	if err != nil {
+		return models.CartItem{}, err
+	}
+	for _, item := range cart.Items {
+		if item.ID == itemID {
+			return item, nil
+		}
+	}
+	return models.CartItem{}, fmt.Errorf("cart item %w", ErrNotFound)
+}
+
+// Checkout orders every line of the cart through the regular order flow,
+// one order per store. Placing the orders takes the ordered quantities off
+// the cart, so quantity added to a line meanwhile stays in it.
+func (s *CartService) Checkout(ctx context.Context, userID uint, input models.CheckoutRequest) ([]models.Transaction, error) {
+	cart, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, fmt.Errorf("%w: cart is empty", ErrInvalidInput)
+	}
+
+	order := models.TrxRequest{
+		MethodBayar:     input.MethodBayar,
+		AlamatKirim:     input.AlamatKirim,
+		JenisPesanan:    input.JenisPesanan,
+		NamaDropshipper: input.NamaDropshipper,
+		TelpDropshipper: input.TelpDropshipper,
+	}
+	for _, item := range cart.Items {
+		order.DetailTrx = append(order.DetailTrx, models.TrxItemRequest{
+			ProductID:  item.ProductID,
+			VariantID:  item.VariantID,
+			Kuantitas:  item.Quantity,
+			CartItemID: item.ID,
+		})
+	}
+	return s.transactions.Create(ctx, userID, order)
+}
+
+// checkStock reports an OUT_OF_STOCK error in the same shape as checkout.
+// The stock of variant counts unless it is the zero variant.
+func checkStock(product models.Product, variant models.ProductVariant, quantity int) error {
+	stock := product.Stock
+	if variant.ID != 0 {
+		stock = variant.Stock
+	}
+	if stock >= quantity {
+		return nil
+	}
+	return orderError(&repository.InsufficientStockError{Items: []repository.StockShortage{
+		repository.NewStockShortage(product, variant, quantity),
+	}})
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/search"
+	"ecommerce-backend/models"
+)
+
+type CategoryService struct {
+	repos *repository.Repositories
+	index *search.Index
+}
+
+func (s *CategoryService) List(ctx context.Context) ([]models.Category, error) {
+	return s.repos.Categories.List(ctx)
+}
+
+func (s *CategoryService) Get(ctx context.Context, id uint) (models.Category, error) {
+	category, err := s.repos.Categories.FindByID(ctx, id)
+	return category, wrap(err, "category")
+}
+
+func (s *CategoryService) Create(ctx context.Context, name string) (models.Category, error) {
+	category := models.Category{Name: name}
+	if err := s.repos.Categories.Create(ctx, &category); err != nil {
+		return models.Category{}, wrap(err, "category")
+	}
+	return category, nil
+}
+
+func (s *CategoryService) Update(ctx context.Context, id uint, name string) (models.Category, error) {
+	category, err := s.Get(ctx, id)
+	if err != nil {
+		return models.Category{}, err
+	}
+	category.Name = name
+	if err := s.repos.Categories.Update(ctx, &category); err != nil {
+		return models.Category{}, wrap(err, "category")
+	}
+	s.index.RenameCategory(category.ID, category.Name)
+	return category, nil
+}
+
+func (s *CategoryService) Delete(ctx context.Context, id uint) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repos.Categories.Delete(ctx, id)
+}
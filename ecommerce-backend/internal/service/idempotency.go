@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/apperror"
+	"errors"
+	"time"
+)
+
+// ErrIdempotencyKeyReused is returned when an Idempotency-Key is sent again
+// with a request different from the one it was first used for.
+var ErrIdempotencyKeyReused = apperror.New(apperror.CodeIdempotencyReused, "Idempotency-Key was already used for a different request")
+
+// IdempotencyService remembers the responses to requests sent with an
+// Idempotency-Key header, per user, so a retried request is answered with
+// the original response instead of running again.
+type IdempotencyService struct {
+	repos  *repository.Repositories
+	window time.Duration
+	lease  time.Duration
+}
+
+// Begin claims key for a request of userID whose content hashes to
+// fingerprint. When the key already answered the same request within the
+// window, its record is returned with replay set and the request must not
+// run again. A key still held by a request in flight is a conflict; one
+// claimed longer than the lease ago by a request that never finished is
+// abandoned and claimed again.
+func (s *IdempotencyService) Begin(ctx context.Context, userID uint, key, fingerprint string) (record models.IdempotencyKey, replay bool, err error) {
+	now := time.Now()
+	// Two attempts cover a record that expired or was released between
+	// our insert and our lookup
+	for attempt := 0; attempt < 2; attempt++ {
+		record = models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(s.window),
+		}
+		err := s.repos.Idempotency.Create(ctx, &record)
+		if err == nil {
+			return record, false, nil
+		}
+		if !errors.Is(err, repository.ErrDuplicate) {
+			return models.IdempotencyKey{}, false, err
+		}
+
+		existing, err := s.repos.Idempotency.Find(ctx, userID, key)
+		if errors.Is(err, repository.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return models.IdempotencyKey{}, false, err
+		}
+		abandoned := existing.StatusCode == 0 && existing.CreatedAt.Before(now.Add(-s.lease))
+		if existing.ExpiresAt.Before(now) || abandoned && existing.Fingerprint == fingerprint {
+			if err := s.repos.Idempotency.Delete(ctx, existing.ID); err != nil {
+				return models.IdempotencyKey{}, false, err
+			}
+			continue
+		}
+		switch {
+		case existing.Fingerprint != fingerprint:
+			return models.IdempotencyKey{}, false, ErrIdempotencyKeyReused
+		case existing.StatusCode == 0:
+			return models.IdempotencyKey{}, false, apperror.New(apperror.CodeConflict, "a request with this Idempotency-Key is still being processed")
+		}
+		return existing, true, nil
+	}
+	return models.IdempotencyKey{}, false, apperror.New(apperror.CodeConflict, "a request with this Idempotency-Key is still being processed")
+}
+
+// Finish stores the response of the request that claimed a key.
+func (s *IdempotencyService) Finish(ctx context.Context, record models.IdempotencyKey, statusCode int, contentType string, response []byte) error {
+	return s.repos.Idempotency.Complete(ctx, record.ID, statusCode, contentType, response)
+}
+
+// Release frees a key whose request failed, so it can be retried.
+func (s *IdempotencyService) Release(ctx context.Context, record models.IdempotencyKey) error {
+	return s.repos.Idempotency.Delete(ctx, record.ID)
+}
+
+// PurgeExpired deletes the keys whose window ended before now.
+func (s *IdempotencyService) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	return s.repos.Idempotency.DeleteExpired(ctx, now)
+}
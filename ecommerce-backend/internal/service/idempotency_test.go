@@ -0,0 +1,46 @@
+package service_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/service"
+	"ecommerce-backend/pkg/apperror"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBeginTakesOverAbandonedKey(t *testing.T) {
+	const lease = 50 * time.Millisecond
+	ctx := context.Background()
+	svc := service.New(repository.NewMemoryRepositories(), service.Options{IdempotencyWindow: time.Hour, IdempotencyLease: lease})
+
+	// the request holding the key crashes: it neither finishes nor releases
+	if _, _, err := svc.Idempotency.Begin(ctx, 1, "key", "POST /trx"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := svc.Idempotency.Begin(ctx, 1, "key", "POST /trx")
+	var appErr *apperror.Error
+	if !errors.As(err, &appErr) || appErr.Code != apperror.CodeConflict {
+		t.Fatalf("retry within the lease: got %v, want a conflict", err)
+	}
+
+	time.Sleep(lease)
+	if _, _, err := svc.Idempotency.Begin(ctx, 1, "key", "POST /cart/checkout"); !errors.Is(err, service.ErrIdempotencyKeyReused) {
+		t.Fatalf("other request after the lease: got %v, want ErrIdempotencyKeyReused", err)
+	}
+	record, replay, err := svc.Idempotency.Begin(ctx, 1, "key", "POST /trx")
+	if err != nil || replay {
+		t.Fatalf("retry after the lease: got replay %v, %v, want the key claimed", replay, err)
+	}
+
+	// a finished request is replayed however old its claim is
+	if err := svc.Idempotency.Finish(ctx, record, 200, "application/json", []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(lease)
+	if _, replay, err := svc.Idempotency.Begin(ctx, 1, "key", "POST /trx"); err != nil || !replay {
+		t.Fatalf("retry of a finished request: got replay %v, %v, want a replay", replay, err)
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/apperror"
+	"errors"
+	"fmt"
+)
+
+// LedgerService keeps the commission of resellers: what their completed
+// orders earned and what they withdrew.
+type LedgerService struct {
+	repos *repository.Repositories
+}
+
+// commissionEntries credits the reseller of a completed order the margin of
+// every line. The margin comes from the prices of the ProductLog snapshot,
+// so editing the product later does not change past earnings.
+func commissionEntries(trx models.Transaction) []models.LedgerEntry {
+	if !repository.IsResellerOrder(trx.OrderType) {
+		return nil
+	}
+	var entries []models.LedgerEntry
+	for _, d := range trx.Details {
+		_, margin := repository.ResellerPrice(d.ProductLog.ResellerPrice, d.ProductLog.ConsumerPrice)
+		if margin <= 0 {
+			continue
+		}
+		entries = append(entries, models.LedgerEntry{
+			UserID:              trx.UserID,
+			Type:                models.LedgerCredit,
+			Amount:              margin.Mul(d.Quantity),
+			Reference:           fmt.Sprintf("trx-detail:%d", d.ID),
+			TransactionID:       &trx.ID,
+			TransactionDetailID: &d.ID,
+			ProductLogID:        &d.ProductLogID,
+			Note:                fmt.Sprintf("commission %s x%d, %s", d.ProductLog.Name, d.Quantity, trx.InvoiceCode),
+		})
+	}
+	return entries
+}
+
+func (s *LedgerService) Balance(ctx context.Context, userID uint) (models.CommissionBalance, error) {
+	return s.repos.Ledger.Balance(ctx, userID)
+}
+
+// Entries returns the ledger of userID, newest first, and its size.
+func (s *LedgerService) Entries(ctx context.Context, userID uint, page, limit int) ([]models.LedgerEntry, int64, error) {
+	return s.repos.Ledger.ListEntries(ctx, userID, page, limit)
+}
+
+// RequestPayout debits input.Amount from the balance of userID and queues
+// the payout for an admin. Anyone with a balance may withdraw it, including
+// former resellers.
+func (s *LedgerService) RequestPayout(ctx context.Context, userID uint, input models.PayoutRequest) (models.Payout, error) {
+	payout := models.Payout{
+		UserID:        userID,
+		Amount:        input.Amount,
+		BankName:      input.BankName,
+		AccountNumber: input.AccountNumber,
+		AccountName:   input.AccountName,
+	}
+	err := s.repos.Ledger.RequestPayout(ctx, &payout)
+	if errors.Is(err, repository.ErrInsufficientBalance) {
+		balance, balanceErr := s.repos.Ledger.Balance(ctx, userID)
+		if balanceErr != nil {
+			return models.Payout{}, balanceErr
+		}
+		return models.Payout{}, &apperror.Error{
+			Code:    apperror.CodeInsufficientBalance,
+			Message: fmt.Sprintf("payout of %s exceeds the commission balance of %s", input.Amount, balance.Balance),
+			Details: balance,
+		}
+	}
+	if err != nil {
+		return models.Payout{}, wrap(err, "user")
+	}
+	return payout, nil
+}
+
+// Payouts lists the payouts of userID, or of everyone when userID is 0.
+func (s *LedgerService) Payouts(ctx context.Context, userID uint, query models.PayoutQuery) ([]models.Payout, int64, error) {
+	return s.repos.Ledger.ListPayouts(ctx, repository.PayoutFilter{
+		UserID: userID,
+		Status: query.Status,
+		Page:   query.Page,
+		Limit:  query.Limit,
+	})
+}
+
+// ApprovePayout records that adminID transferred a pending payout.
+func (s *LedgerService) ApprovePayout(ctx context.Context, adminID, id uint, note string) (models.Payout, error) {
+	return s.resolve(ctx, adminID, id, models.PayoutApproved, note)
+}
+
+// RejectPayout turns down a pending payout and credits its amount back.
+func (s *LedgerService) RejectPayout(ctx context.Context, adminID, id uint, note string) (models.Payout, error) {
+	return s.resolve(ctx, adminID, id, models.PayoutRejected, note)
+}
+
+func (s *LedgerService) resolve(ctx context.Context, adminID, id uint, status, note string) (models.Payout, error) {
+	err := s.repos.Ledger.ResolvePayout(ctx, id, status, adminID, note)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		payout, findErr := s.repos.Ledger.FindPayout(ctx, id)
+		if findErr != nil {
+			return models.Payout{}, wrap(findErr, "payout")
+		}
+		return models.Payout{}, apperror.New(apperror.CodeConflict, fmt.Sprintf("payout is already %s", payout.Status))
+	}
+	if err != nil {
+		return models.Payout{}, wrap(err, "payout")
+	}
+	payout, err := s.repos.Ledger.FindPayout(ctx, id)
+	return payout, wrap(err, "payout")
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/models"
+	"errors"
+	"log"
+	"time"
+)
+
+// expiryBatch is how many orders ExpireUnpaid loads at a time.
+const expiryBatch = 100
+
+// ExpireUnpaid cancels orders still awaiting payment once the payment
+// deadline has passed, putting their stock back, and returns how many it
+// cancelled.
+//
+// Each order is cancelled with a compare-and-set on its status in the same
+// database transaction that restores the stock. When replicas run this at
+// the same time, or a payment lands meanwhile, only one change wins and
+// the others skip the order, so stock is never restored twice. An order
+// that fails to cancel is logged and left for the next run; the run pages
+// past it by id, so such orders never hold up the ones placed after them.
+func (s *TransactionService) ExpireUnpaid(ctx context.Context, now time.Time) (int, error) {
+	placedBefore := now.Add(-s.payments.deadline)
+	var expired int
+	var after uint
+	for {
+		trxs, err := s.repos.Transactions.ListUnpaid(ctx, placedBefore, after, expiryBatch)
+		if err != nil {
+			return expired, err
+		}
+		for _, trx := range trxs {
+			if s.expire(ctx, trx) {
+				expired++
+			}
+		}
+		if len(trxs) < expiryBatch {
+			return expired, nil
+		}
+		after = trxs[len(trxs)-1].ID
+	}
+}
+
+// expire cancels one unpaid order and reports whether it did.
+func (s *TransactionService) expire(ctx context.Context, trx models.Transaction) bool {
+	// A charge paid while its webhook was lost must not be cancelled
+	if s.payments.paid(ctx, trx.ID) {
+		return false
+	}
+	_, err := s.transition(ctx, trx, models.TrxStatusCancelled, SystemActor, "payment deadline passed", "")
+	if errors.Is(err, ErrConflict) {
+		return false
+	}
+	if err != nil {
+		log.Printf("expire order %d: %v", trx.ID, err)
+		return false
+	}
+
+	if err := s.payments.expire(ctx, trx.ID); err != nil {
+		log.Printf("payment: expire charge of order %d: %v", trx.ID, err)
+	}
+	return true
+}
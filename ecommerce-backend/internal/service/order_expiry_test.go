@@ -0,0 +1,162 @@
+package service_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/migrations"
+	"ecommerce-backend/internal/payment"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/service"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/config"
+	"ecommerce-backend/pkg/database"
+	"ecommerce-backend/pkg/migrate"
+	"ecommerce-backend/pkg/money"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// implementations opens each storage the services run on: a migrated
+// SQLite database and the in-memory fakes.
+var implementations = map[string]func(t *testing.T) *repository.Repositories{
+	"gorm": func(t *testing.T) *repository.Repositories {
+		db, err := database.Open(config.DatabaseConfig{
+			Driver:       config.DriverSQLite,
+			DSN:          filepath.Join(t.TempDir(), "test.db"),
+			MaxOpenConns: 10,
+			MaxIdleConns: 10,
+		})
+		if err != nil {
+			t.Fatalf("open database: %v", err)
+		}
+		if _, err := migrate.New(db, migrations.All()).Up(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return repository.NewGormRepositories(db)
+	},
+	"memory": func(*testing.T) *repository.Repositories { return repository.NewMemoryRepositories() },
+}
+
+func newServices(repos *repository.Repositories) *service.Services {
+	return service.New(repos, service.Options{
+		Payments:          payment.NewRegistry(payment.NewMock("secret")),
+		PaymentDeadline:   time.Hour,
+		IdempotencyWindow: time.Hour,
+		IdempotencyLease:  time.Minute,
+	})
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConcurrentExpiryRestoresStockOnce(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			const stock, orders, quantity = 20, 5, 2
+			ctx := context.Background()
+			repos := open(t)
+
+			user := models.User{Name: "Buyer", Phone: "0811", Email: "buyer@example.com"}
+			must(t, repos.Users.Create(ctx, &user))
+			store := models.Store{UserID: user.ID, Name: "Toko"}
+			must(t, repos.Stores.Create(ctx, &store))
+			category := models.Category{Name: "Baju"}
+			must(t, repos.Categories.Create(ctx, &category))
+			product := models.Product{StoreID: store.ID, CategoryID: category.ID, Name: "Kaos", Slug: "kaos", ConsumerPrice: money.Rupiah(15000), Stock: stock}
+			must(t, repos.Products.Create(ctx, &product))
+			address := models.Address{UserID: user.ID, Title: "Rumah"}
+			must(t, repos.Addresses.Create(ctx, &address))
+			for i := 0; i < orders; i++ {
+				trx := models.Transaction{UserID: user.ID, AddressID: address.ID}
+				_, err := repos.Transactions.Create(ctx, trx, []models.TrxItemRequest{{ProductID: product.ID, Kuantitas: quantity}})
+				must(t, err)
+			}
+
+			// two replicas run the job at the same moment
+			var wg sync.WaitGroup
+			var expired [2]int
+			for i := range expired {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					n, err := newServices(repos).Transactions.ExpireUnpaid(ctx, time.Now().Add(2*time.Hour))
+					if err != nil {
+						t.Error(err)
+					}
+					expired[i] = n
+				}()
+			}
+			wg.Wait()
+
+			if expired[0]+expired[1] != orders {
+				t.Fatalf("cancelled %v orders, want %d in all", expired, orders)
+			}
+			got, err := repos.Products.FindByID(ctx, product.ID)
+			must(t, err)
+			if got.Stock != stock {
+				t.Fatalf("stock = %d, want %d restored once", got.Stock, stock)
+			}
+			trxs, err := repos.Transactions.ListByUserID(ctx, user.ID)
+			must(t, err)
+			for _, trx := range trxs {
+				if trx.Status != models.TrxStatusCancelled {
+					t.Fatalf("order %d is %s, want cancelled", trx.ID, trx.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestExpiryGoesPastFailingOrders(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			// more failing orders than one batch holds
+			const failing = 101
+			ctx := context.Background()
+			repos := open(t)
+			flaky := &flakyTransactions{TransactionRepository: repos.Transactions, fail: failing}
+			repos.Transactions = flaky
+
+			user := models.User{Name: "Buyer", Phone: "0811", Email: "buyer@example.com"}
+			must(t, repos.Users.Create(ctx, &user))
+			store := models.Store{UserID: user.ID, Name: "Toko"}
+			must(t, repos.Stores.Create(ctx, &store))
+			category := models.Category{Name: "Baju"}
+			must(t, repos.Categories.Create(ctx, &category))
+			product := models.Product{StoreID: store.ID, CategoryID: category.ID, Name: "Kaos", Slug: "kaos", ConsumerPrice: money.Rupiah(15000), Stock: failing + 1}
+			must(t, repos.Products.Create(ctx, &product))
+			address := models.Address{UserID: user.ID, Title: "Rumah"}
+			must(t, repos.Addresses.Create(ctx, &address))
+			var last models.Transaction
+			for i := 0; i <= failing; i++ {
+				trx := models.Transaction{UserID: user.ID, AddressID: address.ID}
+				orders, err := repos.Transactions.Create(ctx, trx, []models.TrxItemRequest{{ProductID: product.ID, Kuantitas: 1}})
+				must(t, err)
+				last = orders[0]
+			}
+
+			n, err := newServices(repos).Transactions.ExpireUnpaid(ctx, time.Now().Add(2*time.Hour))
+			must(t, err)
+			if n != 1 {
+				t.Fatalf("cancelled %d orders, want only the one that did not fail", n)
+			}
+			got, err := repos.Transactions.FindByID(ctx, last.ID)
+			must(t, err)
+			if got.Status != models.TrxStatusCancelled {
+				t.Fatalf("the order after the failing ones is %s, want cancelled", got.Status)
+			}
+
+			// the next run retries the failed ones
+			n, err = newServices(repos).Transactions.ExpireUnpaid(ctx, time.Now().Add(2*time.Hour))
+			must(t, err)
+			if n != failing {
+				t.Fatalf("the next run cancelled %d orders, want %d", n, failing)
+			}
+		})
+	}
+}
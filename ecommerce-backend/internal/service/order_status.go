@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/apperror"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrInvalidTransition is returned for status changes the order lifecycle
+// does not allow from the current status.
+var ErrInvalidTransition = apperror.New(apperror.CodeInvalidTransition, "invalid status transition")
+
+// Actor is whoever changes the status of an order. ID is 0 for the system.
+type Actor struct {
+	ID   uint
+	Role string
+}
+
+// SystemActor performs automatic transitions such as payment callbacks.
+var SystemActor = Actor{Role: models.ActorSystem}
+
+// transitions lists, per current status, the statuses an order may move to
+// and the roles allowed to make that move:
+//
+//	pending_payment → paid → processing → shipped → delivered → completed
+//
+// Orders can be cancelled until they are shipped, which puts the stock
+// back. Once shipped only an admin can refund them.
+var transitions = map[string]map[string][]string{
+	models.TrxStatusPendingPayment: {
+		models.TrxStatusPaid:      {models.ActorSystem, models.ActorAdmin},
+		models.TrxStatusCancelled: {models.ActorBuyer, models.ActorSeller, models.ActorAdmin, models.ActorSystem},
+	},
+	models.TrxStatusPaid: {
+		models.TrxStatusProcessing: {models.ActorSeller, models.ActorAdmin},
+		models.TrxStatusCancelled:  {models.ActorBuyer, models.ActorSeller, models.ActorAdmin},
+	},
+	models.TrxStatusProcessing: {
+		models.TrxStatusShipped:   {models.ActorSeller, models.ActorAdmin},
+		models.TrxStatusCancelled: {models.ActorSeller, models.ActorAdmin},
+	},
+	models.TrxStatusShipped: {
+		models.TrxStatusDelivered: {models.ActorSeller, models.ActorAdmin, models.ActorSystem},
+		models.TrxStatusRefunded:  {models.ActorAdmin},
+	},
+	models.TrxStatusDelivered: {
+		models.TrxStatusCompleted: {models.ActorBuyer, models.ActorAdmin, models.ActorSystem},
+		models.TrxStatusRefunded:  {models.ActorAdmin},
+	},
+}
+
+// IsTrxStatus reports whether status is one of the order statuses.
+func IsTrxStatus(status string) bool {
+	switch status {
+	case models.TrxStatusPendingPayment, models.TrxStatusPaid, models.TrxStatusProcessing,
+		models.TrxStatusShipped, models.TrxStatusDelivered, models.TrxStatusCompleted,
+		models.TrxStatusCancelled, models.TrxStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// checkTransition validates moving an order from one status to another.
+func checkTransition(from, to string, actor Actor) error {
+	roles, ok := transitions[from][to]
+	if !ok {
+		return &apperror.Error{
+			Code:    apperror.CodeInvalidTransition,
+			Message: fmt.Sprintf("cannot change order status from %s to %s", from, to),
+			Details: map[string]interface{}{"from": from, "to": to, "allowed": allowedFrom(from)},
+		}
+	}
+	for _, role := range roles {
+		if role == actor.Role {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s cannot change order status from %s to %s", ErrForbidden, actor.Role, from, to)
+}
+
+func allowedFrom(from string) []string {
+	allowed := []string{}
+	for _, to := range []string{
+		models.TrxStatusPaid, models.TrxStatusProcessing, models.TrxStatusShipped, models.TrxStatusDelivered,
+		models.TrxStatusCompleted, models.TrxStatusCancelled, models.TrxStatusRefunded,
+	} {
+		if _, ok := transitions[from][to]; ok {
+			allowed = append(allowed, to)
+		}
+	}
+	return allowed
+}
+
+// transition moves trx to status to on behalf of actor. trx must come with
+// its details, since completing a reseller order credits their commission.
+func (s *TransactionService) transition(ctx context.Context, trx models.Transaction, to string, actor Actor, note, trackingNumber string) (models.Transaction, error) {
+	if err := checkTransition(trx.Status, to, actor); err != nil {
+		return models.Transaction{}, err
+	}
+
+	change := repository.StatusChange{
+		From:           trx.Status,
+		To:             to,
+		ActorID:        actor.ID,
+		ActorRole:      actor.Role,
+		Note:           note,
+		TrackingNumber: trackingNumber,
+		Restock:        to == models.TrxStatusCancelled,
+	}
+	if to == models.TrxStatusCompleted {
+		change.Ledger = commissionEntries(trx)
+	}
+	err := s.repos.Transactions.UpdateStatus(ctx, trx.ID, change)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return models.Transaction{}, apperror.New(apperror.CodeConflict, "order status changed, reload and try again")
+	}
+	if err != nil {
+		return models.Transaction{}, wrap(err, "transaction")
+	}
+
+	// The order is cancelled either way; a failed refund is left for an
+	// admin to settle with the provider
+	if (to == models.TrxStatusCancelled || to == models.TrxStatusRefunded) && trx.Status != models.TrxStatusPendingPayment {
+		if err := s.payments.Refund(ctx, trx.ID); err != nil {
+			log.Printf("payment: refund order %d: %v", trx.ID, err)
+		}
+	}
+	return s.repos.Transactions.FindByID(ctx, trx.ID)
+}
+
+// Cancel cancels an order of userID that has not been processed yet.
+func (s *TransactionService) Cancel(ctx context.Context, userID, id uint, reason string) (models.Transaction, error) {
+	trx, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return s.transition(ctx, trx, models.TrxStatusCancelled, Actor{ID: userID, Role: models.ActorBuyer}, reason, "")
+}
+
+// Complete confirms that userID received a delivered order.
+func (s *TransactionService) Complete(ctx context.Context, userID, id uint) (models.Transaction, error) {
+	trx, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return s.transition(ctx, trx, models.TrxStatusCompleted, Actor{ID: userID, Role: models.ActorBuyer}, "", "")
+}
+
+// sellerOrder returns the order id if every line of it was sold by the store
+// of userID. Orders are placed per store; those spanning several stores,
+// placed before, can only be moved by admins.
+func (s *TransactionService) sellerOrder(ctx context.Context, userID, id uint) (models.Transaction, error) {
+	store, err := s.repos.Stores.FindByUserID(ctx, userID)
+	if err != nil {
+		return models.Transaction{}, wrap(err, "store")
+	}
+	trx, err := s.repos.Transactions.FindByID(ctx, id)
+	if err != nil {
+		return models.Transaction{}, wrap(err, "transaction")
+	}
+
+	var own int
+	for _, d := range trx.Details {
+		if d.StoreID == store.ID {
+			own++
+		}
+	}
+	switch {
+	case own == 0:
+		return models.Transaction{}, fmt.Errorf("transaction %w", ErrNotFound)
+	case own < len(trx.Details):
+		return models.Transaction{}, fmt.Errorf("%w: order contains products of other stores", ErrForbidden)
+	}
+	return trx, nil
+}
+
+// Process marks a paid order as being prepared by the seller.
+func (s *TransactionService) Process(ctx context.Context, userID, id uint) (models.Transaction, error) {
+	trx, err := s.sellerOrder(ctx, userID, id)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return s.transition(ctx, trx, models.TrxStatusProcessing, Actor{ID: userID, Role: models.ActorSeller}, "", "")
+}
+
+// Ship hands an order to the courier under trackingNumber.
+func (s *TransactionService) Ship(ctx context.Context, userID, id uint, trackingNumber string) (models.Transaction, error) {
+	trx, err := s.sellerOrder(ctx, userID, id)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return s.transition(ctx, trx, models.TrxStatusShipped, Actor{ID: userID, Role: models.ActorSeller}, "", trackingNumber)
+}
+
+// Deliver records that the courier delivered the order.
+func (s *TransactionService) Deliver(ctx context.Context, userID, id uint) (models.Transaction, error) {
+	trx, err := s.sellerOrder(ctx, userID, id)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return s.transition(ctx, trx, models.TrxStatusDelivered, Actor{ID: userID, Role: models.ActorSeller}, "", "")
+}
+
+// SellerCancel cancels an order of the store of userID before shipping.
+func (s *TransactionService) SellerCancel(ctx context.Context, userID, id uint, reason string) (models.Transaction, error) {
+	trx, err := s.sellerOrder(ctx, userID, id)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	return s.transition(ctx, trx, models.TrxStatusCancelled, Actor{ID: userID, Role: models.ActorSeller}, reason, "")
+}
+
+// SetStatus moves any order to status on behalf of actor, typically an
+// admin or the system. The state machine still applies.
+func (s *TransactionService) SetStatus(ctx context.Context, actor Actor, id uint, input models.UpdateTrxStatusRequest) (models.Transaction, error) {
+	if !IsTrxStatus(input.Status) {
+		return models.Transaction{}, fmt.Errorf("%w: unknown status %q", ErrInvalidInput, input.Status)
+	}
+	trx, err := s.repos.Transactions.FindByID(ctx, id)
+	if err != nil {
+		return models.Transaction{}, wrap(err, "transaction")
+	}
+	if input.Status == models.TrxStatusShipped && input.TrackingNumber == "" && trx.TrackingNumber == "" {
+		return models.Transaction{}, fmt.Errorf("%w: no_resi is required to ship an order", ErrInvalidInput)
+	}
+	return s.transition(ctx, trx, input.Status, actor, input.Note, input.TrackingNumber)
+}
@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/service"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestTwoStoreCheckoutIsFulfilledPerStore(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := open(t)
+			svc := newServices(repos)
+
+			buyer := models.User{Name: "Buyer", Phone: "0811", Email: "buyer@example.com"}
+			must(t, repos.Users.Create(ctx, &buyer))
+			address := models.Address{UserID: buyer.ID, Title: "Rumah"}
+			must(t, repos.Addresses.Create(ctx, &address))
+			category := models.Category{Name: "Baju"}
+			must(t, repos.Categories.Create(ctx, &category))
+
+			// two sellers, each with a store and a product
+			var sellers [2]models.User
+			var products [2]models.Product
+			for i := range sellers {
+				sellers[i] = models.User{Name: "Seller", Phone: fmt.Sprintf("082%d", i), Email: fmt.Sprintf("seller%d@example.com", i)}
+				must(t, repos.Users.Create(ctx, &sellers[i]))
+				store := models.Store{UserID: sellers[i].ID, Name: fmt.Sprintf("Toko %d", i)}
+				must(t, repos.Stores.Create(ctx, &store))
+				products[i] = models.Product{StoreID: store.ID, CategoryID: category.ID, Name: "Kaos", Slug: fmt.Sprintf("kaos-%d", i), ConsumerPrice: money.Rupiah(int64(10000 * (i + 1))), Stock: 5}
+				must(t, repos.Products.Create(ctx, &products[i]))
+			}
+
+			for _, p := range products {
+				must(t, svc.Carts.AddItem(ctx, buyer.ID, models.CartItemRequest{ProductID: p.ID, Kuantitas: 2}))
+			}
+			orders, err := svc.Carts.Checkout(ctx, buyer.ID, models.CheckoutRequest{MethodBayar: "mock", AlamatKirim: address.ID})
+			must(t, err)
+			if len(orders) != 2 {
+				t.Fatalf("checkout placed %d orders, want one per store", len(orders))
+			}
+			for i, order := range orders {
+				trx, err := repos.Transactions.FindByID(ctx, order.ID)
+				must(t, err)
+				if len(trx.Details) != 1 || trx.Details[0].StoreID != products[i].StoreID {
+					t.Fatalf("order %d has lines %+v, want the line of store %d only", trx.ID, trx.Details, products[i].StoreID)
+				}
+				if want := products[i].ConsumerPrice.Mul(2); trx.TotalPrice != want {
+					t.Fatalf("order %d total = %v, want %v", trx.ID, trx.TotalPrice, want)
+				}
+				_, err = svc.Transactions.SetStatus(ctx, service.SystemActor, trx.ID, models.UpdateTrxStatusRequest{Status: models.TrxStatusPaid})
+				must(t, err)
+			}
+
+			// each seller fulfils their own order, and cannot touch the other
+			for i, seller := range sellers {
+				own, other := orders[i].ID, orders[1-i].ID
+				if _, err := svc.Transactions.Process(ctx, seller.ID, other); !errors.Is(err, service.ErrNotFound) {
+					t.Fatalf("seller %d processing the order of another store: got %v, want ErrNotFound", i, err)
+				}
+				_, err := svc.Transactions.Process(ctx, seller.ID, own)
+				must(t, err)
+				trx, err := svc.Transactions.Ship(ctx, seller.ID, own, fmt.Sprintf("RESI-%d", i))
+				must(t, err)
+				if trx.Status != models.TrxStatusShipped || trx.TrackingNumber != fmt.Sprintf("RESI-%d", i) {
+					t.Fatalf("shipped order is %s with tracking %q", trx.Status, trx.TrackingNumber)
+				}
+			}
+			_, err = svc.Transactions.Deliver(ctx, sellers[0].ID, orders[0].ID)
+			must(t, err)
+			trx, err := svc.Transactions.Get(ctx, buyer.ID, orders[1].ID)
+			must(t, err)
+			if trx.Status != models.TrxStatusShipped {
+				t.Fatalf("order of the second store is %s after the first was delivered, want shipped", trx.Status)
+			}
+
+			cart, err := svc.Carts.Get(ctx, buyer.ID)
+			must(t, err)
+			if len(cart.Items) != 0 {
+				t.Fatalf("cart keeps %d ordered lines", len(cart.Items))
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/payment"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/apperror"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+type PaymentService struct {
+	repos        *repository.Repositories
+	providers    *payment.Registry
+	transactions *TransactionService
+	deadline     time.Duration
+}
+
+// Methods returns the accepted values of method_bayar.
+func (s *PaymentService) Methods() []string {
+	return s.providers.Names()
+}
+
+func (s *PaymentService) validateMethod(method string) error {
+	if _, ok := s.providers.Get(method); ok {
+		return nil
+	}
+	return &apperror.Error{
+		Code:    apperror.CodeValidation,
+		Message: fmt.Sprintf("method_bayar %q is not supported", method),
+		Fields: []apperror.FieldError{{
+			Field: "method_bayar", Rule: "oneof",
+			Message: fmt.Sprintf("method_bayar must be one of: %v", s.Methods()),
+		}},
+	}
+}
+
+// Deadline returns when an order placed at placedAt stops accepting payment.
+func (s *PaymentService) Deadline(placedAt time.Time) time.Time {
+	return placedAt.Add(s.deadline)
+}
+
+// Charge returns a charge the buyer can pay for trx, creating one at the
+// provider of trx.PaymentMethod unless a pending one already exists.
+func (s *PaymentService) Charge(ctx context.Context, trx models.Transaction) (models.Payment, error) {
+	provider, ok := s.providers.Get(trx.PaymentMethod)
+	if !ok {
+		return models.Payment{}, s.validateMethod(trx.PaymentMethod)
+	}
+	expiresAt := s.Deadline(trx.CreatedAt)
+	if !time.Now().Before(expiresAt) {
+		return models.Payment{}, apperror.New(apperror.CodeConflict, "the payment deadline of this order has passed")
+	}
+
+	latest, err := s.repos.Payments.LatestByTransactionID(ctx, trx.ID)
+	if err == nil && latest.Provider == provider.Name() && latest.Status == string(payment.StatusPending) {
+		return latest, nil
+	}
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return models.Payment{}, err
+	}
+
+	charge, err := provider.CreateCharge(ctx, payment.ChargeRequest{
+		TransactionID: trx.ID,
+		Reference:     trx.InvoiceCode,
+		Amount:        trx.TotalPrice,
+		ExpiresAt:     expiresAt,
+	})
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("create %s charge: %w", provider.Name(), err)
+	}
+	p := models.Payment{
+		TransactionID: trx.ID,
+		Provider:      provider.Name(),
+		ChargeID:      charge.ID,
+		Amount:        charge.Amount,
+		Status:        string(charge.Status),
+		PaymentURL:    charge.PaymentURL,
+		ExpiresAt:     charge.ExpiresAt,
+	}
+	if err := s.repos.Payments.Create(ctx, &p); err != nil {
+		return models.Payment{}, err
+	}
+	return p, nil
+}
+
+// Pay returns a payable charge for an order of userID that awaits payment.
+func (s *PaymentService) Pay(ctx context.Context, userID, trxID uint) (models.Payment, error) {
+	trx, err := s.transactions.Get(ctx, userID, trxID)
+	if err != nil {
+		return models.Payment{}, err
+	}
+	if trx.Status != models.TrxStatusPendingPayment {
+		return models.Payment{}, apperror.New(apperror.CodeConflict, fmt.Sprintf("order is %s, not awaiting payment", trx.Status))
+	}
+	return s.Charge(ctx, trx)
+}
+
+// Get returns the latest charge of an order of userID. Pending charges are
+// refreshed from the provider, in case a webhook was lost.
+func (s *PaymentService) Get(ctx context.Context, userID, trxID uint) (models.Payment, error) {
+	if _, err := s.transactions.Get(ctx, userID, trxID); err != nil {
+		return models.Payment{}, err
+	}
+	p, err := s.repos.Payments.LatestByTransactionID(ctx, trxID)
+	if err != nil {
+		return models.Payment{}, wrap(err, "payment")
+	}
+	return s.refresh(ctx, p)
+}
+
+// refresh asks the provider about a pending payment and applies the answer.
+// Provider errors are logged and leave the payment as it is.
+func (s *PaymentService) refresh(ctx context.Context, p models.Payment) (models.Payment, error) {
+	if p.Status != string(payment.StatusPending) {
+		return p, nil
+	}
+
+	provider, ok := s.providers.Get(p.Provider)
+	if !ok {
+		return p, nil
+	}
+	charge, err := provider.Status(ctx, p.ChargeID)
+	if err != nil {
+		log.Printf("payment: query %s charge %s: %v", p.Provider, p.ChargeID, err)
+		return p, nil
+	}
+	if charge.Status == payment.StatusPending {
+		return p, nil
+	}
+	event := payment.Event{ChargeID: charge.ID, Status: charge.Status, Amount: charge.Amount}
+	if err := s.apply(ctx, provider, p, event); err != nil {
+		return models.Payment{}, err
+	}
+	return s.repos.Payments.LatestByTransactionID(ctx, p.TransactionID)
+}
+
+// paid reports whether the latest charge of an order turns out to be paid
+// when refreshed from the provider. An order left awaiting a charge that
+// is paid, because moving it failed, is settled on the way.
+func (s *PaymentService) paid(ctx context.Context, trxID uint) bool {
+	p, err := s.repos.Payments.LatestByTransactionID(ctx, trxID)
+	if err != nil {
+		return false
+	}
+	p, err = s.refresh(ctx, p)
+	if err != nil {
+		log.Printf("payment: refresh charge of order %d: %v", trxID, err)
+		return false
+	}
+	if p.Status != string(payment.StatusPaid) {
+		return false
+	}
+	if provider, ok := s.providers.Get(p.Provider); ok {
+		if err := s.settle(ctx, provider, p); err != nil {
+			log.Printf("payment: settle order %d: %v", trxID, err)
+		}
+	}
+	return true
+}
+
+// expire marks the pending charge of an order expired once the order is
+// cancelled for not being paid in time.
+func (s *PaymentService) expire(ctx context.Context, trxID uint) error {
+	p, err := s.repos.Payments.LatestByTransactionID(ctx, trxID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil || p.Status != string(payment.StatusPending) {
+		return err
+	}
+	err = s.repos.Payments.UpdateStatus(ctx, p.ID, p.Status, string(payment.StatusExpired), nil)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return nil
+	}
+	return err
+}
+
+// HandleWebhook verifies and applies a notification sent by a provider.
+// Repeated notifications change nothing twice, so gateways may retry
+// freely; a retry finishes what a failed delivery left undone.
+func (s *PaymentService) HandleWebhook(ctx context.Context, providerName string, header http.Header, body []byte) error {
+	provider, ok := s.providers.Get(providerName)
+	if !ok {
+		return fmt.Errorf("payment provider %w", ErrNotFound)
+	}
+	event, err := provider.ParseWebhook(header, body)
+	if errors.Is(err, payment.ErrInvalidSignature) {
+		return apperror.Wrap(apperror.CodeUnauthorized, "invalid webhook signature", err)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+
+	p, err := s.repos.Payments.FindByCharge(ctx, provider.Name(), event.ChargeID)
+	if err != nil {
+		return wrap(err, "charge")
+	}
+	return s.apply(ctx, provider, p, event)
+}
+
+// apply moves a pending payment to the status reported by the provider and
+// marks the order paid. A payment arriving for an order that can no longer
+// be paid, for example one cancelled meanwhile, is refunded right away.
+func (s *PaymentService) apply(ctx context.Context, provider payment.Provider, p models.Payment, event payment.Event) error {
+	if event.Status == payment.StatusPending {
+		return nil
+	}
+	if p.Status == string(payment.StatusPaid) && event.Status == payment.StatusPaid {
+		// A redelivery. The order missed the payment if moving it failed
+		// the first time
+		return s.settle(ctx, provider, p)
+	}
+	if p.Status != string(payment.StatusPending) {
+		return nil
+	}
+	if event.Status == payment.StatusPaid && event.Amount != p.Amount {
+		return fmt.Errorf("%w: paid amount %s does not match the charge amount %s", ErrInvalidInput, event.Amount, p.Amount)
+	}
+
+	var paidAt *time.Time
+	if event.Status == payment.StatusPaid {
+		now := time.Now()
+		paidAt = &now
+	}
+	err := s.repos.Payments.UpdateStatus(ctx, p.ID, p.Status, string(event.Status), paidAt)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		// Another delivery of the same notification got here first
+		return nil
+	}
+	if err != nil || event.Status != payment.StatusPaid {
+		return err
+	}
+	return s.settle(ctx, provider, p)
+}
+
+// settle moves the order of a paid charge to paid. It is safe to repeat:
+// an order already paid is left as it is, and one cancelled meanwhile gets
+// its money back.
+func (s *PaymentService) settle(ctx context.Context, provider payment.Provider, p models.Payment) error {
+	trx, err := s.repos.Transactions.FindByID(ctx, p.TransactionID)
+	if err != nil {
+		return wrap(err, "transaction")
+	}
+	if trx.Status == models.TrxStatusPendingPayment {
+		note := fmt.Sprintf("paid via %s charge %s", p.Provider, p.ChargeID)
+		_, err = s.transactions.transition(ctx, trx, models.TrxStatusPaid, SystemActor, note, "")
+		if err == nil || !(errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrConflict)) {
+			return err
+		}
+		// The order changed meanwhile: cancelled, or paid by another delivery
+		if trx, err = s.repos.Transactions.FindByID(ctx, p.TransactionID); err != nil {
+			return wrap(err, "transaction")
+		}
+	}
+	if trx.Status != models.TrxStatusCancelled {
+		return nil
+	}
+
+	log.Printf("payment: %s charge %s paid for order %d in status %s, refunding", p.Provider, p.ChargeID, trx.ID, trx.Status)
+	return s.refundPayment(ctx, provider, p)
+}
+
+// Refund returns the money of the paid charge of an order, if any. It is
+// called when a paid order is cancelled or refunded.
+func (s *PaymentService) Refund(ctx context.Context, trxID uint) error {
+	p, err := s.repos.Payments.LatestByTransactionID(ctx, trxID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if p.Status != string(payment.StatusPaid) {
+		return nil
+	}
+	provider, ok := s.providers.Get(p.Provider)
+	if !ok {
+		return fmt.Errorf("payment provider %s is no longer enabled", p.Provider)
+	}
+	return s.refundPayment(ctx, provider, p)
+}
+
+func (s *PaymentService) refundPayment(ctx context.Context, provider payment.Provider, p models.Payment) error {
+	if err := provider.Refund(ctx, p.ChargeID, p.Amount); err != nil {
+		return fmt.Errorf("refund %s charge %s: %w", p.Provider, p.ChargeID, err)
+	}
+	err := s.repos.Payments.UpdateStatus(ctx, p.ID, string(payment.StatusPaid), string(payment.StatusRefunded), nil)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		return nil
+	}
+	return err
+}
+
+// Simulate settles a charge of the mock provider for an order of userID
+// and feeds the signed webhook through HandleWebhook, exactly like a
+// notification from a real gateway.
+func (s *PaymentService) Simulate(ctx context.Context, userID uint, chargeID string, outcome string) (models.Payment, error) {
+	provider, ok := s.providers.Get("mock")
+	mock, isMock := provider.(*payment.Mock)
+	if !ok || !isMock {
+		return models.Payment{}, fmt.Errorf("mock payment provider %w", ErrNotFound)
+	}
+	p, err := s.repos.Payments.FindByCharge(ctx, mock.Name(), chargeID)
+	if err != nil {
+		return models.Payment{}, wrap(err, "charge")
+	}
+	if _, err := s.transactions.Get(ctx, userID, p.TransactionID); err != nil {
+		return models.Payment{}, err
+	}
+
+	body, signature, err := mock.Simulate(chargeID, payment.Status(outcome))
+	if err != nil {
+		return models.Payment{}, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	header := http.Header{}
+	header.Set(payment.MockSignatureHeader, signature)
+	if err := s.HandleWebhook(ctx, mock.Name(), header, body); err != nil {
+		return models.Payment{}, err
+	}
+	return s.repos.Payments.LatestByTransactionID(ctx, p.TransactionID)
+}
@@ -0,0 +1,96 @@
+package service_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/payment"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/service"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// flakyTransactions fails the next fail status changes of orders, as a
+// dropped database connection would.
+type flakyTransactions struct {
+	repository.TransactionRepository
+	fail int
+}
+
+func (r *flakyTransactions) UpdateStatus(ctx context.Context, id uint, change repository.StatusChange) error {
+	if r.fail > 0 {
+		r.fail--
+		return errors.New("connection reset")
+	}
+	return r.TransactionRepository.UpdateStatus(ctx, id, change)
+}
+
+func TestRedeliveredWebhookPaysOrder(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := open(t)
+			flaky := &flakyTransactions{TransactionRepository: repos.Transactions}
+			repos.Transactions = flaky
+			mock := payment.NewMock("secret")
+			svc := service.New(repos, service.Options{
+				Payments:          payment.NewRegistry(mock),
+				PaymentDeadline:   time.Hour,
+				IdempotencyWindow: time.Hour,
+				IdempotencyLease:  time.Minute,
+			})
+
+			user := models.User{Name: "Buyer", Phone: "0811", Email: "buyer@example.com"}
+			must(t, repos.Users.Create(ctx, &user))
+			store := models.Store{UserID: user.ID, Name: "Toko"}
+			must(t, repos.Stores.Create(ctx, &store))
+			category := models.Category{Name: "Baju"}
+			must(t, repos.Categories.Create(ctx, &category))
+			product := models.Product{StoreID: store.ID, CategoryID: category.ID, Name: "Kaos", Slug: "kaos", ConsumerPrice: money.Rupiah(15000), Stock: 5}
+			must(t, repos.Products.Create(ctx, &product))
+			address := models.Address{UserID: user.ID, Title: "Rumah"}
+			must(t, repos.Addresses.Create(ctx, &address))
+			orders, err := svc.Transactions.Create(ctx, user.ID, models.TrxRequest{
+				MethodBayar: "mock",
+				AlamatKirim: address.ID,
+				DetailTrx:   []models.TrxItemRequest{{ProductID: product.ID, Kuantitas: 1}},
+			})
+			must(t, err)
+			charge, err := repos.Payments.LatestByTransactionID(ctx, orders[0].ID)
+			must(t, err)
+
+			body, signature, err := mock.Simulate(charge.ChargeID, payment.StatusPaid)
+			must(t, err)
+			header := http.Header{payment.MockSignatureHeader: {signature}}
+
+			// the payment is recorded, then moving the order fails
+			flaky.fail = 1
+			if err := svc.Payments.HandleWebhook(ctx, "mock", header, body); err == nil {
+				t.Fatal("first delivery: want the order update to fail")
+			}
+			trx, err := repos.Transactions.FindByID(ctx, orders[0].ID)
+			must(t, err)
+			if trx.Status != models.TrxStatusPendingPayment {
+				t.Fatalf("after the failed delivery the order is %s", trx.Status)
+			}
+
+			must(t, svc.Payments.HandleWebhook(ctx, "mock", header, body))
+			trx, err = repos.Transactions.FindByID(ctx, orders[0].ID)
+			must(t, err)
+			if trx.Status != models.TrxStatusPaid {
+				t.Fatalf("after the redelivery the order is %s, want paid", trx.Status)
+			}
+
+			// and again changes nothing
+			must(t, svc.Payments.HandleWebhook(ctx, "mock", header, body))
+			charge, err = repos.Payments.LatestByTransactionID(ctx, orders[0].ID)
+			must(t, err)
+			if charge.Status != string(payment.StatusPaid) {
+				t.Fatalf("after another delivery the charge is %s, want paid", charge.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/search"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"ecommerce-backend/pkg/slug"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ProductService struct {
+	repos *repository.Repositories
+	index *search.Index
+
+	// syncMu guards synced, the time the products last loaded into the
+	// index were read from the database.
+	syncMu sync.Mutex
+	synced time.Time
+}
+
+const (
+	// slugFallback is the slug of products whose name has nothing to spell
+	// in a URL, such as names in non-Latin scripts.
+	slugFallback = "produk"
+	// slugAttempts bounds how often a write is retried when another
+	// product takes the free slug first.
+	slugAttempts = 5
+)
+
+type CreateProductInput struct {
+	Name          string
+	CategoryID    uint
+	ResellerPrice money.Amount
+	ConsumerPrice money.Amount
+	Stock         int
+	Description   string
+	// PhotoURLs are already stored uploads to attach to the product.
+	PhotoURLs []string
+}
+
+// UpdateProductInput holds the fields to change; nil ones are kept.
+type UpdateProductInput struct {
+	Name          *string
+	CategoryID    *uint
+	ResellerPrice *money.Amount
+	ConsumerPrice *money.Amount
+	Stock         *int
+	Description   *string
+	// PhotoURLs are already stored uploads to add after the current photos.
+	PhotoURLs []string
+}
+
+func (s *ProductService) List(ctx context.Context, filter repository.ProductFilter) ([]models.Product, int64, error) {
+	return s.repos.Products.List(ctx, filter)
+}
+
+func (s *ProductService) Get(ctx context.Context, id uint) (models.Product, error) {
+	product, err := s.repos.Products.FindByID(ctx, id)
+	return product, wrap(err, "product")
+}
+
+// GetBySlug returns the product with slug. moved reports that slug is one
+// the product had before being renamed, whose URL should redirect to the
+// current slug.
+func (s *ProductService) GetBySlug(ctx context.Context, slug string) (product models.Product, moved bool, err error) {
+	product, err = s.repos.Products.FindBySlug(ctx, slug)
+	if !errors.Is(err, repository.ErrNotFound) {
+		return product, false, wrap(err, "product")
+	}
+	previous, err := s.repos.Products.FindPreviousSlug(ctx, slug)
+	if err != nil {
+		return models.Product{}, false, wrap(err, "product")
+	}
+	product, err = s.Get(ctx, previous.ProductID)
+	return product, err == nil, err
+}
+
+// uniqueSlug returns the slug of name, or the first of name-2, name-3 and
+// so on that no product other than id has or had.
+func (s *ProductService) uniqueSlug(ctx context.Context, name string, id uint) (string, error) {
+	base := slug.Make(name)
+	if base == "" {
+		base = slugFallback
+	}
+	for n := 1; ; n++ {
+		candidate := slug.WithSuffix(base, n)
+		taken, err := s.repos.Products.SlugTaken(ctx, candidate, id)
+		if err != nil || !taken {
+			return candidate, err
+		}
+	}
+}
+
+// withUniqueSlug calls write with a unique slug of name for product id, 0
+// for a new one, and again with the next free slug when another product
+// took it in the meantime.
+func (s *ProductService) withUniqueSlug(ctx context.Context, name string, id uint, write func(slug string) error) error {
+	for attempt := 1; ; attempt++ {
+		candidate, err := s.uniqueSlug(ctx, name, id)
+		if err != nil {
+			return err
+		}
+		err = write(candidate)
+		if !errors.Is(err, repository.ErrDuplicate) || attempt == slugAttempts {
+			return wrap(err, "product slug")
+		}
+	}
+}
+
+// Create adds a product with its photos to the store of userID and returns
+// it. Errors marked ErrCommitted come after the product was saved.
+func (s *ProductService) Create(ctx context.Context, userID uint, input CreateProductInput) (models.Product, error) {
+	store, err := s.repos.Stores.FindByUserID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Product{}, fmt.Errorf("%w: user must have a store", ErrInvalidInput)
+	}
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	// Validation: Ensure valid Category ID is provided
+	if err := s.checkCategory(ctx, input.CategoryID); err != nil {
+		return models.Product{}, err
+	}
+
+	product := models.Product{
+		Name:          input.Name,
+		CategoryID:    input.CategoryID,
+		StoreID:       store.ID,
+		ResellerPrice: input.ResellerPrice,
+		ConsumerPrice: input.ConsumerPrice,
+		Stock:         input.Stock,
+		Description:   input.Description,
+	}
+	for i, url := range input.PhotoURLs {
+		product.Photos = append(product.Photos, models.ProductPhoto{URL: url, Position: i + 1})
+	}
+	err = s.withUniqueSlug(ctx, product.Name, 0, func(slug string) error {
+		product.Slug = slug
+		return s.repos.Products.Create(ctx, &product)
+	})
+	if err != nil {
+		return models.Product{}, err
+	}
+	product, err = s.reindex(ctx, product.ID)
+	return product, committed(err)
+}
+
+// checkCategory makes sure id names an existing category.
+func (s *ProductService) checkCategory(ctx context.Context, id uint) error {
+	if id == 0 {
+		return fmt.Errorf("%w: category_id is required", ErrInvalidInput)
+	}
+	_, err := s.repos.Categories.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("%w: category_id %d does not exist", ErrInvalidInput, id)
+	}
+	return err
+}
+
+// Update changes the fields set in input of a product of the store of
+// userID and returns the updated product. Renaming makes a new slug; the
+// old one keeps leading to the product.
+// Products with variants take their prices and stock from the variants, so
+// those cannot be set directly. Errors marked ErrCommitted come after the
+// changes were saved.
+func (s *ProductService) Update(ctx context.Context, userID, id uint, input UpdateProductInput) (models.Product, error) {
+	product, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	changes := repository.ProductChanges{
+		CategoryID:    input.CategoryID,
+		ResellerPrice: input.ResellerPrice,
+		ConsumerPrice: input.ConsumerPrice,
+		Stock:         input.Stock,
+		Description:   input.Description,
+		PhotoURLs:     input.PhotoURLs,
+	}
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return models.Product{}, fmt.Errorf("%w: nama_produk cannot be empty", ErrInvalidInput)
+		}
+		changes.Name = &name
+	}
+	if input.CategoryID != nil {
+		if err := s.checkCategory(ctx, *input.CategoryID); err != nil {
+			return models.Product{}, err
+		}
+	}
+	if len(product.Variants) > 0 && (input.ResellerPrice != nil || input.ConsumerPrice != nil || input.Stock != nil) {
+		return models.Product{}, fmt.Errorf("%w: prices and stock of a product with variants are set per variant", ErrInvalidInput)
+	}
+
+	if changes.Name != nil {
+		err = s.withUniqueSlug(ctx, *changes.Name, product.ID, func(slug string) error {
+			changes.Slug = &slug
+			return s.repos.Products.Update(ctx, product.ID, changes)
+		})
+	} else {
+		err = s.repos.Products.Update(ctx, product.ID, changes)
+	}
+	if err != nil {
+		return models.Product{}, err
+	}
+	product, err = s.reindex(ctx, product.ID)
+	return product, committed(err)
+}
+
+// AddPhotos adds stored uploads after the photos of a product of the store
+// of userID and returns all its photos. Either all of urls are added or
+// none; errors marked ErrCommitted come after they were.
+func (s *ProductService) AddPhotos(ctx context.Context, userID, id uint, urls []string) ([]models.ProductPhoto, error) {
+	product, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("%w: photos is required", ErrInvalidInput)
+	}
+	if err := s.repos.Products.Update(ctx, product.ID, repository.ProductChanges{PhotoURLs: urls}); err != nil {
+		return nil, err
+	}
+	photos, err := s.photos(ctx, product.ID)
+	return photos, committed(err)
+}
+
+// DeletePhoto removes a photo of a product of the store of userID and
+// returns it, so the caller can delete the upload.
+func (s *ProductService) DeletePhoto(ctx context.Context, userID, id, photoID uint) (models.ProductPhoto, error) {
+	product, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return models.ProductPhoto{}, err
+	}
+	for _, photo := range product.Photos {
+		if photo.ID == photoID {
+			return photo, wrap(s.repos.Products.DeletePhoto(ctx, product.ID, photoID), "photo")
+		}
+	}
+	return models.ProductPhoto{}, fmt.Errorf("photo %w", ErrNotFound)
+}
+
+// DeletePhotos removes every photo of a product of the store of userID and
+// returns them, so the caller can delete the uploads.
+func (s *ProductService) DeletePhotos(ctx context.Context, userID, id uint) ([]models.ProductPhoto, error) {
+	product, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repos.Products.DeletePhotos(ctx, product.ID); err != nil {
+		return nil, err
+	}
+	return product.Photos, nil
+}
+
+// ReorderPhotos puts the photos of a product of the store of userID in the
+// order of ids, which must list each of them once. The first becomes the
+// primary image.
+func (s *ProductService) ReorderPhotos(ctx context.Context, userID, id uint, ids []uint) ([]models.ProductPhoto, error) {
+	product, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[uint]bool, len(ids))
+	for _, photoID := range ids {
+		listed[photoID] = true
+	}
+	complete := len(listed) == len(ids) && len(ids) == len(product.Photos)
+	for _, photo := range product.Photos {
+		complete = complete && listed[photo.ID]
+	}
+	if !complete {
+		return nil, fmt.Errorf("%w: photo_ids must list every photo of the product once", ErrInvalidInput)
+	}
+	if err := s.repos.Products.ReorderPhotos(ctx, product.ID, ids); err != nil {
+		return nil, wrap(err, "photo")
+	}
+	return s.photos(ctx, product.ID)
+}
+
+// SetPrimaryPhoto moves a photo of a product of the store of userID to the
+// front, keeping the order of the others.
+func (s *ProductService) SetPrimaryPhoto(ctx context.Context, userID, id, photoID uint) ([]models.ProductPhoto, error) {
+	product, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uint{photoID}
+	found := false
+	for _, photo := range product.Photos {
+		if photo.ID == photoID {
+			found = true
+		} else {
+			ids = append(ids, photo.ID)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("photo %w", ErrNotFound)
+	}
+	if err := s.repos.Products.ReorderPhotos(ctx, product.ID, ids); err != nil {
+		return nil, wrap(err, "photo")
+	}
+	return s.photos(ctx, product.ID)
+}
+
+// photos returns the photos of a product in their order.
+func (s *ProductService) photos(ctx context.Context, id uint) ([]models.ProductPhoto, error) {
+	product, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return product.Photos, nil
+}
+
+// SetVariants replaces the options and variants of a product of the store
+// of userID and returns the product with its new matrix. Every variant
+// picks one value of each option, and no two variants the same values.
+func (s *ProductService) SetVariants(ctx context.Context, userID, id uint, input models.ProductVariantsRequest) (models.Product, error) {
+	product, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if (len(input.Opsi) == 0) != (len(input.Varian) == 0) {
+		return models.Product{}, fmt.Errorf("%w: opsi and varian must both be given, or both be empty", ErrInvalidInput)
+	}
+
+	options := make([]models.ProductOption, 0, len(input.Opsi))
+	names := make(map[string]bool)
+	for i, o := range input.Opsi {
+		if names[strings.ToLower(o.Nama)] {
+			return models.Product{}, fmt.Errorf("%w: option %s is listed twice", ErrInvalidInput, o.Nama)
+		}
+		names[strings.ToLower(o.Nama)] = true
+		values := make(map[string]bool)
+		for _, value := range o.Nilai {
+			if values[value] {
+				return models.Product{}, fmt.Errorf("%w: option %s lists %s twice", ErrInvalidInput, o.Nama, value)
+			}
+			values[value] = true
+		}
+		options = append(options, models.ProductOption{Position: i + 1, Name: o.Nama, Values: o.Nilai})
+	}
+
+	photos := make(map[uint]bool, len(product.Photos))
+	for _, photo := range product.Photos {
+		photos[photo.ID] = true
+	}
+	variants := make([]models.ProductVariant, 0, len(input.Varian))
+	skus := make(map[string]bool)
+	combinations := make(map[string]string)
+	for _, v := range input.Varian {
+		if skus[v.SKU] {
+			return models.Product{}, fmt.Errorf("%w: sku %s is listed twice", ErrInvalidInput, v.SKU)
+		}
+		skus[v.SKU] = true
+		if len(v.Opsi) != len(options) {
+			return models.Product{}, fmt.Errorf("%w: variant %s needs one value for each of the %d options", ErrInvalidInput, v.SKU, len(options))
+		}
+		for i, value := range v.Opsi {
+			if !slices.Contains(options[i].Values, value) {
+				return models.Product{}, fmt.Errorf("%w: variant %s: %s is not a value of %s", ErrInvalidInput, v.SKU, value, options[i].Name)
+			}
+		}
+		name := strings.Join(v.Opsi, " / ")
+		if other, ok := combinations[name]; ok {
+			return models.Product{}, fmt.Errorf("%w: variants %s and %s are both %s", ErrInvalidInput, other, v.SKU, name)
+		}
+		combinations[name] = v.SKU
+
+		variant := models.ProductVariant{
+			SKU:           v.SKU,
+			Name:          name,
+			Values:        v.Opsi,
+			ResellerPrice: v.ResellerPrice,
+			ConsumerPrice: v.ConsumerPrice,
+			Stock:         v.Stok,
+		}
+		if v.PhotoID != 0 {
+			if !photos[v.PhotoID] {
+				return models.Product{}, fmt.Errorf("%w: photo_id %d is not a photo of this product", ErrInvalidInput, v.PhotoID)
+			}
+			variant.PhotoID = &v.PhotoID
+		}
+		variants = append(variants, variant)
+	}
+
+	if err := s.repos.Products.SetVariants(ctx, product.ID, options, variants); err != nil {
+		return models.Product{}, wrap(err, "variant sku")
+	}
+	return s.reindex(ctx, product.ID)
+}
+
+// variantOf returns the variant of p an order line or cart item with
+// variantID takes: none for products without variants, and one of theirs
+// for the others.
+func variantOf(p models.Product, variantID uint) (models.ProductVariant, error) {
+	if variantID == 0 {
+		if len(p.Variants) > 0 {
+			return models.ProductVariant{}, fmt.Errorf("%w: variant_id is required for %s", ErrInvalidInput, p.Name)
+		}
+		return models.ProductVariant{}, nil
+	}
+	for _, v := range p.Variants {
+		if v.ID == variantID {
+			return v, nil
+		}
+	}
+	return models.ProductVariant{}, fmt.Errorf("%w: variant %d of %s is not available", ErrInvalidInput, variantID, p.Name)
+}
+
+func (s *ProductService) Delete(ctx context.Context, userID, id uint) error {
+	product, err := s.owned(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repos.Products.Delete(ctx, product.ID); err != nil {
+		return err
+	}
+	s.index.Delete(product.ID)
+	return nil
+}
+
+// owned returns the product if it belongs to the store of userID.
+func (s *ProductService) owned(ctx context.Context, userID, id uint) (models.Product, error) {
+	product, err := s.Get(ctx, id)
+	if err != nil {
+		return models.Product{}, err
+	}
+
+	store, err := s.repos.Stores.FindByUserID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Product{}, ErrForbidden
+	}
+	if err != nil {
+		return models.Product{}, err
+	}
+	if product.StoreID != store.ID {
+		return models.Product{}, ErrForbidden
+	}
+	return product, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/search"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/pagination"
+	"time"
+)
+
+const (
+	// indexBatch is how many products RebuildIndex loads at a time.
+	indexBatch = 500
+	// syncOverlap is how far before the previous sync SyncIndex looks
+	// again, for writes committed late or stamped by a clock behind ours.
+	syncOverlap = time.Minute
+)
+
+// Search ranks the products matching query with the search index and
+// returns the requested page of them with the facets of all matches. Page
+// and Limit must be positive.
+func (s *ProductService) Search(ctx context.Context, query models.ProductSearchQuery) (models.ProductSearchResult, error) {
+	for {
+		result := s.index.Search(search.Query{
+			Text:       query.Q,
+			CategoryID: query.CategoryID,
+			StoreID:    query.StoreID,
+			MinPrice:   query.MinPrice,
+			MaxPrice:   query.MaxPrice,
+			Offset:     (query.Page - 1) * query.Limit,
+			Limit:      query.Limit,
+		})
+		products, err := s.repos.Products.FindByIDs(ctx, result.IDs)
+		if err != nil {
+			return models.ProductSearchResult{}, err
+		}
+
+		byID := make(map[uint]models.Product, len(products))
+		for _, p := range products {
+			byID[p.ID] = p
+		}
+		// Products another replica deleted before the index synced would
+		// still count in the total and the facets: drop them and search
+		// again. Every round removes one at least, so this ends.
+		stale := false
+		for _, id := range result.IDs {
+			if _, ok := byID[id]; !ok {
+				s.index.Delete(id)
+				stale = true
+			}
+		}
+		if stale {
+			continue
+		}
+
+		// Keep the ranking
+		data := make([]models.Product, 0, len(result.IDs))
+		for _, id := range result.IDs {
+			data = append(data, byID[id])
+		}
+		return models.ProductSearchResult{
+			Page:       query.Page,
+			Limit:      query.Limit,
+			Total:      result.Total,
+			TotalPages: pagination.TotalPages(int64(result.Total), query.Limit),
+			Data:       data,
+			Facets:     result.Facets,
+		}, nil
+	}
+}
+
+// RebuildIndex reloads the search index from the database and returns the
+// number of products indexed. Besides what SyncIndex picks up, it catches
+// stores and categories renamed on other replicas.
+func (s *ProductService) RebuildIndex(ctx context.Context) (int, error) {
+	version, started := s.index.Version(), time.Now()
+	var products []models.Product
+	page := pagination.Params{Limit: indexBatch}
+	for {
+		batch, _, err := s.repos.Products.List(ctx, repository.ProductFilter{Page: page})
+		if err != nil {
+			return 0, err
+		}
+		products = append(products, batch...)
+		if len(batch) < indexBatch {
+			break
+		}
+		page.After = pagination.Anchor(page, batch[len(batch)-1], repository.ProductKeys)
+	}
+	s.index.Rebuild(products, version)
+	s.markSynced(started)
+	return len(products), nil
+}
+
+// SyncIndex applies to the search index the products created, updated or
+// deleted in the database since the previous sync or rebuild, by this
+// replica or another, and returns how many it applied.
+func (s *ProductService) SyncIndex(ctx context.Context) (int, error) {
+	s.syncMu.Lock()
+	since := s.synced.Add(-syncOverlap)
+	s.syncMu.Unlock()
+
+	version, started := s.index.Version(), time.Now()
+	changed, deleted, err := s.repos.Products.ChangedSince(ctx, since)
+	if err != nil {
+		return 0, err
+	}
+	s.index.Sync(changed, deleted, version)
+	s.markSynced(started)
+	return len(changed) + len(deleted), nil
+}
+
+// markSynced records that the index holds every change made before at.
+func (s *ProductService) markSynced(at time.Time) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	if at.After(s.synced) {
+		s.synced = at
+	}
+}
+
+// reindex loads a product after a change, puts it into the search index
+// and returns it.
+func (s *ProductService) reindex(ctx context.Context, id uint) (models.Product, error) {
+	product, err := s.Get(ctx, id)
+	if err != nil {
+		return models.Product{}, err
+	}
+	s.index.Put(product)
+	return product, nil
+}
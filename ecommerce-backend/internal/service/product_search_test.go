@@ -0,0 +1,67 @@
+package service_test
+
+import (
+	"context"
+	"ecommerce-backend/internal/service"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/money"
+	"testing"
+)
+
+func TestSearchFollowsOtherReplicas(t *testing.T) {
+	for name, open := range implementations {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repos := open(t)
+			user := models.User{Name: "Seller", Phone: "0811", Email: "seller@example.com"}
+			must(t, repos.Users.Create(ctx, &user))
+			must(t, repos.Stores.Create(ctx, &models.Store{UserID: user.ID, Name: "Toko"}))
+			category := models.Category{Name: "Baju"}
+			must(t, repos.Categories.Create(ctx, &category))
+
+			// two replicas on one database
+			a, b := newServices(repos), newServices(repos)
+			for _, svc := range []*service.Services{a, b} {
+				_, err := svc.Products.RebuildIndex(ctx)
+				must(t, err)
+			}
+			search := func(q string) models.ProductSearchResult {
+				t.Helper()
+				result, err := b.Products.Search(ctx, models.ProductSearchQuery{Q: q, Page: 1, Limit: 10})
+				must(t, err)
+				return result
+			}
+
+			var ids []uint
+			for _, name := range []string{"Kaos Polos", "Kaos Garis"} {
+				product, err := a.Products.Create(ctx, user.ID, service.CreateProductInput{Name: name, CategoryID: category.ID, ConsumerPrice: money.Rupiah(50000), Stock: 1})
+				must(t, err)
+				ids = append(ids, product.ID)
+			}
+			if got := search("kaos"); got.Total != 0 {
+				t.Fatalf("found %d products before the sync", got.Total)
+			}
+			n, err := b.Products.SyncIndex(ctx)
+			must(t, err)
+			if got := search("kaos"); n != 2 || got.Total != 2 {
+				t.Fatalf("synced %d, found %d products, want the 2 created on the other replica", n, got.Total)
+			}
+
+			// deleted on the other replica, before this one syncs
+			must(t, a.Products.Delete(ctx, user.ID, ids[0]))
+			got := search("kaos")
+			if got.Total != 1 || got.TotalPages != 1 || len(got.Data) != 1 || got.Data[0].ID != ids[1] {
+				t.Fatalf("after a delete got %+v, want only product %d", got, ids[1])
+			}
+			if len(got.Facets.Categories) != 1 || got.Facets.Categories[0].Count != 1 {
+				t.Fatalf("category facets = %+v, want the deleted product left out", got.Facets.Categories)
+			}
+
+			_, err = b.Products.SyncIndex(ctx)
+			must(t, err)
+			if got := search("kaos"); got.Total != 1 {
+				t.Fatalf("found %d products after the sync, want 1", got.Total)
+			}
+		})
+	}
+}
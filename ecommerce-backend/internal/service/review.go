@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/apperror"
+	"ecommerce-backend/pkg/pagination"
+	"errors"
+	"fmt"
+)
+
+// maxReviewPhotos is how many photos a review may have.
+const maxReviewPhotos = 5
+
+// ReviewService keeps the reviews buyers write about what they bought.
+type ReviewService struct {
+	repos *repository.Repositories
+}
+
+type CreateReviewInput struct {
+	DetailID  uint
+	Rating    int
+	Text      string
+	PhotoURLs []string
+}
+
+// Create reviews an order line of userID. Only lines of completed orders
+// can be reviewed, each once. Errors marked ErrCommitted come after the
+// review was saved.
+func (s *ReviewService) Create(ctx context.Context, userID uint, input CreateReviewInput) (models.Review, error) {
+	if len(input.PhotoURLs) > maxReviewPhotos {
+		return models.Review{}, fmt.Errorf("%w: a review has at most %d photos", ErrInvalidInput, maxReviewPhotos)
+	}
+	detail, err := s.repos.Transactions.FindDetail(ctx, input.DetailID)
+	if err != nil {
+		return models.Review{}, wrap(err, "order line")
+	}
+	if detail.Transaction.UserID != userID {
+		return models.Review{}, ErrForbidden
+	}
+	if detail.Transaction.Status != models.TrxStatusCompleted {
+		return models.Review{}, fmt.Errorf("%w: only lines of completed orders can be reviewed", ErrInvalidInput)
+	}
+
+	review := models.Review{
+		TransactionDetailID: detail.ID,
+		ProductLogID:        detail.ProductLogID,
+		ProductID:           detail.ProductLog.ProductID,
+		StoreID:             detail.StoreID,
+		UserID:              userID,
+		Rating:              input.Rating,
+		Text:                input.Text,
+	}
+	for _, url := range input.PhotoURLs {
+		review.Photos = append(review.Photos, models.ReviewPhoto{URL: url})
+	}
+	if err := s.repos.Reviews.Create(ctx, &review); err != nil {
+		return models.Review{}, wrap(err, "review of this order line")
+	}
+	review, err = s.Get(ctx, review.ID)
+	return review, committed(err)
+}
+
+func (s *ReviewService) Get(ctx context.Context, id uint) (models.Review, error) {
+	review, err := s.repos.Reviews.FindByID(ctx, id)
+	return review, wrap(err, "review")
+}
+
+// ProductReviews returns a page of the visible reviews of a product, of
+// the given rating unless it is 0.
+func (s *ReviewService) ProductReviews(ctx context.Context, productID uint, rating int, page pagination.Params) ([]models.Review, int64, error) {
+	if _, err := s.repos.Products.FindByID(ctx, productID); err != nil {
+		return nil, 0, wrap(err, "product")
+	}
+	return s.repos.Reviews.List(ctx, repository.ReviewFilter{ProductID: productID, Rating: rating, Page: page})
+}
+
+// StoreReviews is ProductReviews for every product of a store.
+func (s *ReviewService) StoreReviews(ctx context.Context, storeID uint, rating int, page pagination.Params) ([]models.Review, int64, error) {
+	if _, err := s.repos.Stores.FindByID(ctx, storeID); err != nil {
+		return nil, 0, wrap(err, "store")
+	}
+	return s.repos.Reviews.List(ctx, repository.ReviewFilter{StoreID: storeID, Rating: rating, Page: page})
+}
+
+// List returns a page of the reviews matching filter, for the reviews of a
+// buyer and for admins.
+func (s *ReviewService) List(ctx context.Context, filter repository.ReviewFilter) ([]models.Review, int64, error) {
+	return s.repos.Reviews.List(ctx, filter)
+}
+
+// Reply answers a review of a product of the store of userID.
+func (s *ReviewService) Reply(ctx context.Context, userID, id uint, reply string) (models.Review, error) {
+	review, err := s.Get(ctx, id)
+	if err != nil {
+		return models.Review{}, err
+	}
+	store, err := s.repos.Stores.FindByUserID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Review{}, ErrForbidden
+	}
+	if err != nil {
+		return models.Review{}, err
+	}
+	if review.StoreID != store.ID {
+		return models.Review{}, ErrForbidden
+	}
+	if err := s.repos.Reviews.Reply(ctx, id, reply); err != nil {
+		return models.Review{}, wrap(err, "review")
+	}
+	return s.Get(ctx, id)
+}
+
+// Hide takes a review out of lists and ratings on behalf of adminID.
+func (s *ReviewService) Hide(ctx context.Context, adminID, id uint, reason string) (models.Review, error) {
+	return s.setHidden(ctx, adminID, id, true, reason)
+}
+
+// Show puts a hidden review back.
+func (s *ReviewService) Show(ctx context.Context, adminID, id uint) (models.Review, error) {
+	return s.setHidden(ctx, adminID, id, false, "")
+}
+
+func (s *ReviewService) setHidden(ctx context.Context, adminID, id uint, hidden bool, reason string) (models.Review, error) {
+	err := s.repos.Reviews.SetHidden(ctx, id, hidden, adminID, reason)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		state := "shown"
+		if hidden {
+			state = "hidden"
+		}
+		return models.Review{}, apperror.New(apperror.CodeConflict, fmt.Sprintf("review is already %s", state))
+	}
+	if err != nil {
+		return models.Review{}, wrap(err, "review")
+	}
+	return s.Get(ctx, id)
+}
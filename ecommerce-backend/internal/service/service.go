@@ -0,0 +1,104 @@
+// Package service holds the business rules of the shop. Services are
+// independent of HTTP so the same rules serve the REST API, CLI commands and
+// background jobs.
+package service
+
+import (
+	"ecommerce-backend/internal/payment"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/search"
+	"ecommerce-backend/pkg/apperror"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Domain errors. Wrap them with fmt.Errorf("...: %w") to add context; the
+// HTTP layer maps their codes to statuses.
+var (
+	ErrNotFound            = apperror.New(apperror.CodeNotFound, "not found")
+	ErrForbidden           = apperror.New(apperror.CodeForbidden, "forbidden")
+	ErrOutOfStock          = apperror.New(apperror.CodeOutOfStock, "out of stock")
+	ErrInsufficientBalance = apperror.New(apperror.CodeInsufficientBalance, "insufficient commission balance")
+	ErrConflict            = apperror.New(apperror.CodeConflict, "already exists")
+	ErrInvalidInput        = apperror.New(apperror.CodeBadRequest, "invalid input")
+	ErrInvalidCredentials  = apperror.New(apperror.CodeInvalidCredentials, "No Telp atau kata sandi salah")
+)
+
+// ErrCommitted marks an error that happened after the write of a call was
+// committed, such as reloading what it wrote. What the write stored stays,
+// so callers must keep anything it refers to, like uploaded files.
+var ErrCommitted = errors.New("write committed")
+
+// committed marks err, if any, with ErrCommitted.
+func committed(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w, then: %w", ErrCommitted, err)
+}
+
+// Services bundles every service of the application.
+type Services struct {
+	Auth         *AuthService
+	Users        *UserService
+	Addresses    *AddressService
+	Stores       *StoreService
+	Categories   *CategoryService
+	Products     *ProductService
+	Transactions *TransactionService
+	Carts        *CartService
+	Payments     *PaymentService
+	Idempotency  *IdempotencyService
+	Ledger       *LedgerService
+	Reviews      *ReviewService
+	Wishlist     *WishlistService
+}
+
+// Options holds the dependencies of the services besides storage.
+type Options struct {
+	Payments *payment.Registry
+	// PaymentDeadline is how long buyers have to pay an order.
+	PaymentDeadline time.Duration
+	// IdempotencyWindow is how long idempotency keys are remembered.
+	IdempotencyWindow time.Duration
+	// IdempotencyLease is how long a request holds its idempotency key.
+	IdempotencyLease time.Duration
+}
+
+func New(repos *repository.Repositories, opts Options) *Services {
+	payments := &PaymentService{repos: repos, providers: opts.Payments, deadline: opts.PaymentDeadline}
+	transactions := &TransactionService{repos: repos, payments: payments}
+	payments.transactions = transactions
+	// Empty until Products.RebuildIndex loads it
+	index := search.New()
+	return &Services{
+		Auth:         &AuthService{repos: repos},
+		Users:        &UserService{repos: repos},
+		Addresses:    &AddressService{repos: repos},
+		Stores:       &StoreService{repos: repos, index: index},
+		Categories:   &CategoryService{repos: repos, index: index},
+		Products:     &ProductService{repos: repos, index: index},
+		Transactions: transactions,
+		Carts:        &CartService{repos: repos, transactions: transactions},
+		Payments:     payments,
+		Idempotency:  &IdempotencyService{repos: repos, window: opts.IdempotencyWindow, lease: opts.IdempotencyLease},
+		Ledger:       &LedgerService{repos: repos},
+		Reviews:      &ReviewService{repos: repos},
+		Wishlist:     &WishlistService{repos: repos},
+	}
+}
+
+// wrap converts repository errors into domain errors, keeping what as
+// context for the message.
+func wrap(err error, what string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		return fmt.Errorf("%s %w", what, ErrNotFound)
+	case errors.Is(err, repository.ErrDuplicate):
+		return fmt.Errorf("%s %w", what, ErrConflict)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/search"
+	"ecommerce-backend/models"
+)
+
+type StoreService struct {
+	repos *repository.Repositories
+	index *search.Index
+}
+
+type UpdateStoreInput struct {
+	Name string
+	// PhotoURL replaces the store photo when not empty.
+	PhotoURL string
+}
+
+func (s *StoreService) Get(ctx context.Context, id uint) (models.Store, error) {
+	store, err := s.repos.Stores.FindByID(ctx, id)
+	return store, wrap(err, "store")
+}
+
+func (s *StoreService) GetByUserID(ctx context.Context, userID uint) (models.Store, error) {
+	store, err := s.repos.Stores.FindByUserID(ctx, userID)
+	return store, wrap(err, "store")
+}
+
+func (s *StoreService) List(ctx context.Context, filter repository.StoreFilter) ([]models.Store, int64, error) {
+	return s.repos.Stores.List(ctx, filter)
+}
+
+// GetOwned returns the store if it belongs to userID.
+func (s *StoreService) GetOwned(ctx context.Context, userID, id uint) (models.Store, error) {
+	store, err := s.Get(ctx, id)
+	if err != nil {
+		return models.Store{}, err
+	}
+	if store.UserID != userID {
+		return models.Store{}, ErrForbidden
+	}
+	return store, nil
+}
+
+// Update changes a store owned by userID.
+func (s *StoreService) Update(ctx context.Context, userID, id uint, input UpdateStoreInput) (models.Store, error) {
+	store, err := s.GetOwned(ctx, userID, id)
+	if err != nil {
+		return models.Store{}, err
+	}
+
+	store.Name = input.Name
+	if input.PhotoURL != "" {
+		store.PhotoURL = input.PhotoURL
+	}
+	if err := s.repos.Stores.Update(ctx, &store); err != nil {
+		return models.Store{}, err
+	}
+	s.index.RenameStore(store.ID, store.Name)
+	return store, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"fmt"
+	"time"
+)
+
+// ListStoreOrders returns a page of the order lines sold by the store of
+// userID. Page and Limit must be positive.
+func (s *TransactionService) ListStoreOrders(ctx context.Context, userID uint, query models.StoreOrderQuery) ([]models.StoreOrderLine, int64, error) {
+	store, err := s.repos.Stores.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, 0, wrap(err, "store")
+	}
+	if query.Status != "" && !IsTrxStatus(query.Status) {
+		return nil, 0, fmt.Errorf("%w: unknown status %q", ErrInvalidInput, query.Status)
+	}
+
+	filter := repository.StoreOrderFilter{
+		StoreID:     store.ID,
+		Page:        query.Page,
+		Limit:       query.Limit,
+		Status:      query.Status,
+		InvoiceCode: query.InvoiceCode,
+	}
+	// The binding already validated the date format
+	if query.StartDate != "" {
+		filter.From, _ = time.ParseInLocation(time.DateOnly, query.StartDate, time.Local)
+	}
+	if query.EndDate != "" {
+		end, _ := time.ParseInLocation(time.DateOnly, query.EndDate, time.Local)
+		filter.To = end.AddDate(0, 0, 1)
+	}
+
+	details, total, err := s.repos.Transactions.ListStoreLines(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	lines := make([]models.StoreOrderLine, 0, len(details))
+	for _, d := range details {
+		line := models.StoreOrderLine{
+			ID:            d.ID,
+			TransactionID: d.TransactionID,
+			Quantity:      d.Quantity,
+			TotalPrice:    d.TotalPrice,
+			Product:       d.ProductLog,
+		}
+		if trx := d.Transaction; trx != nil {
+			line.InvoiceCode = trx.InvoiceCode
+			line.Status = trx.Status
+			line.TrackingNumber = trx.TrackingNumber
+			line.PaymentMethod = trx.PaymentMethod
+			line.OrderType = trx.OrderType
+			line.DropshipperName = trx.DropshipperName
+			line.DropshipperPhone = trx.DropshipperPhone
+			line.Address = trx.Address
+			line.OrderedAt = trx.CreatedAt
+		}
+		lines = append(lines, line)
+	}
+	return lines, total, nil
+}
+
+// GetStoreOrder returns an order containing products of the store of
+// userID, limited to that store's lines and their total.
+func (s *TransactionService) GetStoreOrder(ctx context.Context, userID, id uint) (models.Transaction, error) {
+	store, err := s.repos.Stores.FindByUserID(ctx, userID)
+	if err != nil {
+		return models.Transaction{}, wrap(err, "store")
+	}
+	trx, err := s.repos.Transactions.FindByID(ctx, id)
+	if err != nil {
+		return models.Transaction{}, wrap(err, "transaction")
+	}
+
+	own := []models.TransactionDetail{}
+	trx.TotalPrice = 0
+	for _, d := range trx.Details {
+		if d.StoreID == store.ID {
+			own = append(own, d)
+			trx.TotalPrice += d.TotalPrice
+		}
+	}
+	if len(own) == 0 {
+		return models.Transaction{}, fmt.Errorf("transaction %w", ErrNotFound)
+	}
+	trx.Details = own
+	return trx, nil
+}
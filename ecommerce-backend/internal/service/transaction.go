@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/apperror"
+	"ecommerce-backend/pkg/pagination"
+	"errors"
+	"fmt"
+	"log"
+)
+
+type TransactionService struct {
+	repos    *repository.Repositories
+	payments *PaymentService
+}
+
+// Create places the orders of userID for input, one per store selling its
+// products. Stock is checked and decremented, and the totals computed, by
+// the repository while it holds the product rows.
+// Reseller and dropship orders, priced at harga_reseller, are reserved to
+// users with the reseller role; the role is read from the database rather
+// than the token so revoking it takes effect at once.
+// Each order then waits for payment through the provider named by
+// method_bayar; failing to create a charge does not fail the order, since
+// the buyer can ask for a charge again.
+func (s *TransactionService) Create(ctx context.Context, userID uint, input models.TrxRequest) ([]models.Transaction, error) {
+	if err := s.payments.validateMethod(input.MethodBayar); err != nil {
+		return nil, err
+	}
+	addr, err := s.repos.Addresses.FindByID(ctx, input.AlamatKirim)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil || addr.UserID != userID {
+		return nil, fmt.Errorf("%w: invalid address %d", ErrInvalidInput, input.AlamatKirim)
+	}
+
+	if err := s.checkVariants(ctx, input.DetailTrx); err != nil {
+		return nil, err
+	}
+
+	orderType := input.JenisPesanan
+	if orderType == "" {
+		orderType = models.OrderTypeRegular
+	}
+	if orderType != models.OrderTypeRegular {
+		user, err := s.repos.Users.FindByID(ctx, userID)
+		if err != nil {
+			return nil, wrap(err, "user")
+		}
+		if !user.IsReseller {
+			return nil, fmt.Errorf("%w: only resellers can place %s orders", ErrForbidden, orderType)
+		}
+	}
+
+	trx := models.Transaction{
+		UserID:        userID,
+		AddressID:     input.AlamatKirim,
+		PaymentMethod: input.MethodBayar,
+		OrderType:     orderType,
+		Status:        models.TrxStatusPendingPayment,
+	}
+	if orderType == models.OrderTypeDropship {
+		trx.DropshipperName = input.NamaDropshipper
+		trx.DropshipperPhone = input.TelpDropshipper
+	}
+	orders, err := s.repos.Transactions.Create(ctx, trx, input.DetailTrx)
+	if err != nil {
+		return nil, orderError(err)
+	}
+	for _, order := range orders {
+		if _, err := s.payments.Charge(ctx, order); err != nil {
+			log.Printf("payment: charge order %d: %v", order.ID, err)
+		}
+	}
+	return orders, nil
+}
+
+// checkVariants tells the buyer which line names the wrong variant, where
+// the repository only reports a product as unavailable. Missing products
+// are left to the repository.
+func (s *TransactionService) checkVariants(ctx context.Context, items []models.TrxItemRequest) error {
+	products := make(map[uint]models.Product)
+	for _, item := range items {
+		p, ok := products[item.ProductID]
+		if !ok {
+			var err error
+			p, err = s.repos.Products.FindByID(ctx, item.ProductID)
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			products[p.ID] = p
+		}
+		if _, err := variantOf(p, item.VariantID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderError maps the repository errors of placing an order. Shortages keep
+// the per-product details so clients can show what is still available.
+func orderError(err error) error {
+	var stockErr *repository.InsufficientStockError
+	switch {
+	case errors.As(err, &stockErr):
+		return &apperror.Error{Code: apperror.CodeOutOfStock, Message: stockErr.Error(), Details: stockErr.Items}
+	case errors.Is(err, repository.ErrNotFound):
+		return fmt.Errorf("%w: one or more products are unavailable", ErrInvalidInput)
+	}
+	return err
+}
+
+// List returns a page of the orders placed by userID and their number.
+func (s *TransactionService) List(ctx context.Context, userID uint, page pagination.Params) ([]models.Transaction, int64, error) {
+	return s.repos.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Page: page})
+}
+
+// Get returns the transaction if it was placed by userID.
+func (s *TransactionService) Get(ctx context.Context, userID, id uint) (models.Transaction, error) {
+	trx, err := s.repos.Transactions.FindByID(ctx, id)
+	if err != nil {
+		return models.Transaction{}, wrap(err, "transaction")
+	}
+	if trx.UserID != userID {
+		return models.Transaction{}, ErrForbidden
+	}
+	return trx, nil
+}
+
+// GetByInvoice returns the order of userID with invoice number code.
+func (s *TransactionService) GetByInvoice(ctx context.Context, userID uint, code string) (models.Transaction, error) {
+	trx, err := s.repos.Transactions.FindByInvoiceCode(ctx, code)
+	if err != nil {
+		return models.Transaction{}, wrap(err, "transaction")
+	}
+	if trx.UserID != userID {
+		return models.Transaction{}, ErrForbidden
+	}
+	return trx, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/utils"
+)
+
+type UserService struct {
+	repos *repository.Repositories
+}
+
+type UpdateProfileInput struct {
+	Name     string
+	Job      string
+	About    string
+	Gender   string
+	Password string
+}
+
+func (s *UserService) Get(ctx context.Context, userID uint) (models.User, error) {
+	user, err := s.repos.Users.FindByID(ctx, userID)
+	return user, wrap(err, "user")
+}
+
+func (s *UserService) Update(ctx context.Context, userID uint, input UpdateProfileInput) (models.User, error) {
+	user, err := s.repos.Users.FindByID(ctx, userID)
+	if err != nil {
+		return models.User{}, wrap(err, "user")
+	}
+
+	user.Name = input.Name
+	user.Job = input.Job
+	user.About = input.About
+	user.Gender = input.Gender
+	if input.Password != "" {
+		hash, err := utils.HashPassword(input.Password)
+		if err != nil {
+			return models.User{}, err
+		}
+		user.Password = hash
+	}
+
+	if err := s.repos.Users.Update(ctx, &user); err != nil {
+		return models.User{}, wrap(err, "user")
+	}
+	return user, nil
+}
+
+// SetReseller grants or revokes the reseller role of a user. Admins only.
+func (s *UserService) SetReseller(ctx context.Context, userID uint, isReseller bool) (models.User, error) {
+	user, err := s.repos.Users.FindByID(ctx, userID)
+	if err != nil {
+		return models.User{}, wrap(err, "user")
+	}
+	user.IsReseller = isReseller
+	if err := s.repos.Users.Update(ctx, &user); err != nil {
+		return models.User{}, wrap(err, "user")
+	}
+	return user, nil
+}
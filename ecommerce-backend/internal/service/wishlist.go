@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/models"
+	"ecommerce-backend/pkg/pagination"
+)
+
+// WishlistService keeps the products buyers save for later.
+type WishlistService struct {
+	repos *repository.Repositories
+}
+
+// List returns a page of the wishlist of userID with the current product
+// data, flagging products that dropped below the price they were saved at
+// or ran out of stock.
+func (s *WishlistService) List(ctx context.Context, userID uint, page pagination.Params) ([]models.WishlistLine, int64, error) {
+	items, total, err := s.repos.Wishlist.List(ctx, userID, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	lines := make([]models.WishlistLine, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, wishlistLine(item))
+	}
+	return lines, total, nil
+}
+
+func wishlistLine(item models.WishlistItem) models.WishlistLine {
+	line := models.WishlistLine{
+		ID:         item.ID,
+		ProductID:  item.ProductID,
+		SavedPrice: item.SavedPrice,
+		CreatedAt:  item.CreatedAt,
+	}
+	if p := item.Product; p.ID != 0 {
+		p.IsWishlisted = true
+		line.Product = &p
+		line.Price = p.ConsumerPrice
+		line.PriceDropped = p.ConsumerPrice < item.SavedPrice
+		line.OutOfStock = p.Stock == 0
+		line.Available = !line.OutOfStock
+	}
+	return line
+}
+
+// Add saves a product to the wishlist of userID at its current price.
+func (s *WishlistService) Add(ctx context.Context, userID, productID uint) (models.WishlistLine, error) {
+	product, err := s.repos.Products.FindByID(ctx, productID)
+	if err != nil {
+		return models.WishlistLine{}, wrap(err, "product")
+	}
+	item := models.WishlistItem{
+		UserID:     userID,
+		ProductID:  product.ID,
+		SavedPrice: product.ConsumerPrice,
+	}
+	if err := s.repos.Wishlist.Add(ctx, &item); err != nil {
+		return models.WishlistLine{}, wrap(err, "wishlist item")
+	}
+	item.Product = product
+	return wishlistLine(item), nil
+}
+
+func (s *WishlistService) Remove(ctx context.Context, userID, productID uint) error {
+	return wrap(s.repos.Wishlist.Remove(ctx, userID, productID), "wishlist item")
+}
+
+// Mark sets IsWishlisted on the products userID saved.
+func (s *WishlistService) Mark(ctx context.Context, userID uint, products []models.Product) error {
+	ids := make([]uint, 0, len(products))
+	for _, p := range products {
+		ids = append(ids, p.ID)
+	}
+	saved, err := s.repos.Wishlist.Saved(ctx, userID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range products {
+		products[i].IsWishlisted = saved[products[i].ID]
+	}
+	return nil
+}
@@ -1,81 +1,252 @@
 package main
 
 import (
+	"context"
 	"ecommerce-backend/internal/handler"
+	"ecommerce-backend/internal/jobs"
+	"ecommerce-backend/internal/migrations"
+	"ecommerce-backend/internal/payment"
+	"ecommerce-backend/internal/repository"
+	"ecommerce-backend/internal/service"
+	"ecommerce-backend/pkg/apperror"
+	"ecommerce-backend/pkg/config"
 	"ecommerce-backend/pkg/database"
 	"ecommerce-backend/pkg/middleware"
+	"ecommerce-backend/pkg/migrate"
+	"ecommerce-backend/pkg/utils"
+	"flag"
+	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
 func main() {
-	database.Connect()
+	configPath := flag.String("config", "", "path to a YAML or TOML config file (defaults to $CONFIG_FILE)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	utils.SecretKey = []byte(cfg.JWT.Secret)
+	utils.TokenTTL = cfg.JWT.TTL.Duration
+	utils.UploadPath = cfg.Upload.Path
+
+	if cfg.IsProduction() {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	log.Printf("Database connected successfully (%s)", cfg.Database.Driver)
+
+	migrator := migrate.New(db, migrations.All())
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		runMigrate(migrator, args[1:])
+		return
+	}
+
+	if cfg.Database.MigrateOnBoot {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
+		for _, m := range applied {
+			log.Printf("Applied migration %05d_%s", m.Version, m.Name)
+		}
+	} else {
+		pending, err := migrator.Pending(context.Background())
+		if err != nil {
+			log.Fatal("Failed to check migrations:", err)
+		}
+		if len(pending) > 0 {
+			log.Fatalf("Database has %d pending migrations, run \"migrate up\" first", len(pending))
+		}
+	}
+
+	var providers []payment.Provider
+	if cfg.Payment.MockEnabled {
+		providers = append(providers, payment.NewMock(cfg.Payment.MockWebhookSecret))
+	}
+	svc := service.New(repository.NewGormRepositories(db), service.Options{
+		Payments:          payment.NewRegistry(providers...),
+		PaymentDeadline:   cfg.Payment.Deadline.Duration,
+		IdempotencyWindow: cfg.Idempotency.Window.Duration,
+		IdempotencyLease:  cfg.Idempotency.Lease.Duration,
+	})
+	h := handler.New(svc)
+
+	indexed, err := svc.Products.RebuildIndex(context.Background())
+	if err != nil {
+		log.Fatal("Failed to build the search index:", err)
+	}
+	log.Printf("Search index built with %d products", indexed)
+
+	if interval := cfg.Payment.ExpiryInterval.Duration; interval > 0 {
+		go jobs.Every(context.Background(), "expire unpaid orders", interval, func(ctx context.Context) error {
+			n, err := svc.Transactions.ExpireUnpaid(ctx, time.Now())
+			if n > 0 {
+				log.Printf("Cancelled %d unpaid orders past the payment deadline", n)
+			}
+			return err
+		})
+	}
+	go jobs.Every(context.Background(), "purge idempotency keys", time.Hour, func(ctx context.Context) error {
+		_, err := svc.Idempotency.PurgeExpired(ctx, time.Now())
+		return err
+	})
+	if interval := cfg.Search.SyncInterval.Duration; interval > 0 {
+		go jobs.Every(context.Background(), "sync search index", interval, func(ctx context.Context) error {
+			_, err := svc.Products.SyncIndex(ctx)
+			return err
+		})
+	}
+	if interval := cfg.Search.RefreshInterval.Duration; interval > 0 {
+		go jobs.Every(context.Background(), "rebuild search index", interval, func(ctx context.Context) error {
+			_, err := svc.Products.RebuildIndex(ctx)
+			return err
+		})
+	}
+
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(apperror.JSONFieldName)
+	}
 
 	r := gin.Default()
-	r.Static("/public", "./public")
+	r.Use(middleware.ErrorHandler())
+	r.NoRoute(middleware.NotFound)
+	r.Static("/public/uploads", cfg.Upload.Path)
 
 	api := r.Group("/api/v1")
 	{
 		// Auth
-		api.POST("/auth/register", handler.Register)
-		api.POST("/auth/login", handler.Login)
+		api.POST("/auth/register", h.Register)
+		api.POST("/auth/login", h.Login)
 
 		// Public Product
-		api.GET("/product", handler.GetAllProducts)
-		api.GET("/product/:id", handler.GetProductByID)
+		api.GET("/product", middleware.OptionalAuth(), h.GetAllProducts)
+		api.GET("/product/search", middleware.OptionalAuth(), h.SearchProducts)
+		api.GET("/product/:id", middleware.OptionalAuth(), h.GetProductByID)
+		api.GET("/product/slug/:slug", middleware.OptionalAuth(), h.GetProductBySlug)
+		api.GET("/product/:id/ulasan", h.GetProductReviews)
 
 		// Public Category
-		api.GET("/category", handler.GetAllCategory)
-		api.GET("/category/:id", handler.GetCategoryByID)
+		api.GET("/category", h.GetAllCategory)
+		api.GET("/category/:id", h.GetCategoryByID)
 
 		// Public Store
-		api.GET("/toko", handler.GetAllStores)
-		api.GET("/toko/:id_toko", handler.GetStoreByID)
+		api.GET("/toko", h.GetAllStores)
+		api.GET("/toko/:id_toko", h.GetStoreByID)
+		api.GET("/toko/:id_toko/ulasan", h.GetStoreReviews)
+
+		// Payment provider notifications, authenticated by their signature
+		api.POST("/payment/webhook/:provider", h.PaymentWebhook)
 
 		// Protected Routes
 		authorized := api.Group("/")
 		authorized.Use(middleware.AuthMiddleware())
 		{
 			// User
-			authorized.GET("/user", handler.GetProfile)
-			authorized.PUT("/user", handler.UpdateProfile)
-			
+			authorized.GET("/user", h.GetProfile)
+			authorized.PUT("/user", h.UpdateProfile)
+
 			// Alamat
-			authorized.GET("/user/alamat", handler.GetMyAddress)
-			authorized.GET("/user/alamat/:id", handler.GetAddressByID)
-			authorized.POST("/user/alamat", handler.CreateAddress)
-			authorized.PUT("/user/alamat/:id", handler.UpdateAddress)
-			authorized.DELETE("/user/alamat/:id", handler.DeleteAddress)
+			authorized.GET("/user/alamat", h.GetMyAddress)
+			authorized.GET("/user/alamat/:id", h.GetAddressByID)
+			authorized.POST("/user/alamat", h.Idempotent(), h.CreateAddress)
+			authorized.PUT("/user/alamat/:id", h.UpdateAddress)
+			authorized.DELETE("/user/alamat/:id", h.DeleteAddress)
 
 			// Store Management (My Store)
-			authorized.GET("/toko/my", handler.GetMyStore)
-			authorized.PUT("/toko/:id_toko", handler.UpdateStore)
+			authorized.GET("/toko/my", h.GetMyStore)
+			authorized.PUT("/toko/:id_toko", h.UpdateStore)
 
 			// Product Management
-			authorized.POST("/product", handler.CreateProduct)
-			authorized.PUT("/product/:id", handler.UpdateProduct)
-			authorized.DELETE("/product/:id", handler.DeleteProduct)
+			authorized.POST("/product", h.Idempotent(), h.CreateProduct)
+			authorized.PUT("/product/:id", h.UpdateProduct)
+			authorized.PATCH("/product/:id", h.UpdateProduct)
+			authorized.POST("/product/:id/photos", h.AddProductPhotos)
+			authorized.PUT("/product/:id/photos/order", h.ReorderProductPhotos)
+			authorized.PUT("/product/:id/photos/:photo_id/primary", h.SetPrimaryProductPhoto)
+			authorized.DELETE("/product/:id/photos/:photo_id", h.DeleteProductPhoto)
+			authorized.DELETE("/product/:id/photos", h.DeleteProductPhotos)
+			authorized.PUT("/product/:id/varian", h.SetProductVariants)
+			authorized.DELETE("/product/:id", h.DeleteProduct)
 
 			// Transaction
-			authorized.GET("/trx", handler.GetAllTrx)
-			authorized.GET("/trx/:id", handler.GetTrxByID)
-			authorized.POST("/trx", handler.CreateTrx)
+			authorized.GET("/trx", h.GetAllTrx)
+			authorized.GET("/trx/:id", h.GetTrxByID)
+			authorized.GET("/trx/invoice/*code", h.GetTrxByInvoice)
+			authorized.POST("/trx", h.Idempotent(), h.CreateTrx)
+			authorized.POST("/trx/:id/cancel", h.CancelTrx)
+			authorized.POST("/trx/:id/complete", h.CompleteTrx)
+			authorized.GET("/trx/:id/payment", h.GetTrxPayment)
+			authorized.POST("/trx/:id/payment", h.PayTrx)
+			if cfg.Payment.MockEnabled {
+				authorized.POST("/payment/mock/:charge_id/simulate", h.SimulatePayment)
+			}
+
+			// Seller Orders (My Store)
+			authorized.GET("/toko/my/orders", h.GetStoreOrders)
+			authorized.GET("/toko/my/orders/:id", h.GetStoreOrderByID)
+			authorized.POST("/toko/my/orders/:id/process", h.ProcessOrder)
+			authorized.POST("/toko/my/orders/:id/ship", h.ShipOrder)
+			authorized.POST("/toko/my/orders/:id/deliver", h.DeliverOrder)
+			authorized.POST("/toko/my/orders/:id/cancel", h.CancelOrder)
+
+			// Cart
+			authorized.GET("/cart", h.GetCart)
+			authorized.DELETE("/cart", h.ClearCart)
+			authorized.POST("/cart/items", h.AddCartItem)
+			authorized.PUT("/cart/items/:id", h.UpdateCartItem)
+			authorized.DELETE("/cart/items/:id", h.DeleteCartItem)
+			authorized.POST("/cart/checkout", h.Idempotent(), h.CheckoutCart)
+
+			// Reseller Commission
+			authorized.GET("/user/komisi", h.GetCommission)
+			authorized.GET("/user/komisi/mutasi", h.GetCommissionEntries)
+			authorized.GET("/user/komisi/payout", h.GetMyPayouts)
+			authorized.POST("/user/komisi/payout", h.Idempotent(), h.RequestPayout)
+
+			// Reviews
+			authorized.GET("/user/ulasan", h.GetMyReviews)
+			authorized.POST("/ulasan", h.Idempotent(), h.CreateReview)
+			authorized.PUT("/ulasan/:id/balasan", h.ReplyReview)
+
+			// Wishlist
+			authorized.GET("/user/wishlist", h.GetWishlist)
+			authorized.POST("/user/wishlist", h.AddWishlistItem)
+			authorized.DELETE("/user/wishlist/:product_id", h.DeleteWishlistItem)
 
 			// Admin Only
 			admin := authorized.Group("/")
 			admin.Use(middleware.AdminOnly())
 			{
-				admin.POST("/category", handler.CreateCategory)
-				admin.PUT("/category/:id", handler.UpdateCategory)
-				admin.DELETE("/category/:id", handler.DeleteCategory)
+				admin.POST("/category", h.CreateCategory)
+				admin.PUT("/category/:id", h.UpdateCategory)
+				admin.DELETE("/category/:id", h.DeleteCategory)
+				admin.PUT("/trx/:id/status", h.UpdateTrxStatus)
+				admin.PUT("/user/:id/reseller", h.SetUserReseller)
+				admin.GET("/payout", h.GetAllPayouts)
+				admin.POST("/payout/:id/approve", h.ApprovePayout)
+				admin.POST("/payout/:id/reject", h.RejectPayout)
+				admin.GET("/ulasan", h.GetAllReviews)
+				admin.POST("/ulasan/:id/hide", h.HideReview)
+				admin.POST("/ulasan/:id/unhide", h.ShowReview)
 			}
 		}
-		
+
 		// ProvCity (Placeholder for regional data)
 		api.GET("/provcity/listprovincies", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": true, "data": []string{"Data fetched from external API"}})
 		})
 	}
 
-	r.Run(":8000")
-}
\ No newline at end of file
+	r.Run(cfg.Server.Addr())
+}
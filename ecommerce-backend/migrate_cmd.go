@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"ecommerce-backend/pkg/migrate"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const migrateUsage = `usage: ecommerce-backend [-config file] migrate <command>
+
+commands:
+  up         apply all pending migrations
+  down [n]   roll back the last n migrations (default 1)
+  status     list migrations and whether they are applied`
+
+func runMigrate(migrator *migrate.Migrator, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied %05d_%s\n", m.Version, m.Name)
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				log.Fatalf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations")
+		}
+		for _, m := range reverted {
+			fmt.Printf("Reverted %05d_%s\n", m.Version, m.Name)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Missing {
+				state += " (unknown to this binary)"
+			}
+			fmt.Printf("%05d_%-30s %s\n", s.Version, s.Name, state)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"ecommerce-backend/pkg/money"
 	"time"
 
 	"gorm.io/gorm"
@@ -20,6 +21,7 @@ type User struct {
 	ProvinceID   string         `gorm:"column:id_provinsi" json:"id_provinsi"`
 	CityID       string         `gorm:"column:id_kota" json:"id_kota"`
 	IsAdmin      bool           `gorm:"default:false;column:isAdmin" json:"-"`
+	IsReseller   bool           `gorm:"default:false;column:is_reseller" json:"is_reseller"`
 	Store        Store          `gorm:"foreignKey:UserID;references:ID" json:"toko,omitempty"`
 	CreatedAt    time.Time      `gorm:"column:created_at" json:"created_at"`
 	UpdatedAt    time.Time      `gorm:"column:updated_at" json:"updated_at"`
@@ -40,12 +42,15 @@ type Address struct {
 
 // Store Entity
 type Store struct {
-	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
-	UserID    uint      `gorm:"column:id_user" json:"id_user"`
-	Name      string    `gorm:"column:nama_toko" json:"nama_toko"`
-	PhotoURL  string    `gorm:"column:url_foto" json:"url_foto"`
-	CreatedAt time.Time `gorm:"column:created_at" json:"-"`
-	UpdatedAt time.Time `gorm:"column:updated_at" json:"-"`
+	ID          uint      `gorm:"primaryKey;column:id" json:"id"`
+	UserID      uint      `gorm:"column:id_user" json:"id_user"`
+	Name        string    `gorm:"column:nama_toko" json:"nama_toko"`
+	PhotoURL    string    `gorm:"column:url_foto" json:"url_foto"`
+	Rating      float64   `gorm:"column:rating" json:"rating"`
+	RatingCount int       `gorm:"column:jumlah_ulasan" json:"jumlah_ulasan"`
+	RatingTotal int64     `gorm:"column:total_rating" json:"-"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"-"`
+	UpdatedAt   time.Time `gorm:"column:updated_at" json:"-"`
 }
 
 // Category Entity
@@ -56,74 +61,335 @@ type Category struct {
 	UpdatedAt time.Time `gorm:"column:updated_at" json:"-"`
 }
 
-// Product Entity
+// Product Entity. While a product has variants, stok is the sum of their
+// stock and the prices are those of the cheapest variant. Slugs are unique
+// among all products, deleted ones included. Rating averages its visible
+// reviews, RatingTotal sums them. IsWishlisted is not stored; product
+// endpoints set it for the signed-in caller.
 type Product struct {
-	ID            uint           `gorm:"primaryKey;column:id" json:"id"`
-	StoreID       uint           `gorm:"column:id_toko" json:"toko_id"`
-	CategoryID    uint           `gorm:"column:id_category" json:"category_id"`
-	Name          string         `gorm:"column:nama_produk" json:"nama_produk"`
-	Slug          string         `gorm:"column:slug" json:"slug"`
-	ResellerPrice float64        `gorm:"column:harga_reseller" json:"harga_reseller"`
-	ConsumerPrice float64        `gorm:"column:harga_konsumen" json:"harga_konsumen"`
-	Stock         int            `gorm:"column:stok" json:"stok"`
-	Description   string         `gorm:"column:deskripsi" json:"deskripsi"`
-	Store         Store          `gorm:"foreignKey:StoreID" json:"toko"`
-	Category      Category       `gorm:"foreignKey:CategoryID" json:"category"`
-	Photos        []ProductPhoto `gorm:"foreignKey:ProductID" json:"photos"`
-	CreatedAt     time.Time      `gorm:"column:created_at" json:"-"`
-	UpdatedAt     time.Time      `gorm:"column:updated_at" json:"-"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint             `gorm:"primaryKey;column:id" json:"id"`
+	StoreID       uint             `gorm:"column:id_toko" json:"toko_id"`
+	CategoryID    uint             `gorm:"column:id_category" json:"category_id"`
+	Name          string           `gorm:"column:nama_produk" json:"nama_produk"`
+	Slug          string           `gorm:"column:slug;uniqueIndex" json:"slug"`
+	ResellerPrice money.Amount     `gorm:"column:harga_reseller" json:"harga_reseller"`
+	ConsumerPrice money.Amount     `gorm:"column:harga_konsumen" json:"harga_konsumen"`
+	Stock         int              `gorm:"column:stok" json:"stok"`
+	Description   string           `gorm:"column:deskripsi" json:"deskripsi"`
+	Rating        float64          `gorm:"column:rating" json:"rating"`
+	RatingCount   int              `gorm:"column:jumlah_ulasan" json:"jumlah_ulasan"`
+	RatingTotal   int64            `gorm:"column:total_rating" json:"-"`
+	IsWishlisted  bool             `gorm:"-" json:"is_wishlisted"`
+	Store         Store            `gorm:"foreignKey:StoreID" json:"toko"`
+	Category      Category         `gorm:"foreignKey:CategoryID" json:"category"`
+	Photos        []ProductPhoto   `gorm:"foreignKey:ProductID" json:"photos"`
+	Options       []ProductOption  `gorm:"foreignKey:ProductID" json:"opsi"`
+	Variants      []ProductVariant `gorm:"foreignKey:ProductID" json:"varian"`
+	CreatedAt     time.Time        `gorm:"column:created_at" json:"-"`
+	UpdatedAt     time.Time        `gorm:"column:updated_at" json:"-"`
+	DeletedAt     gorm.DeletedAt   `gorm:"index" json:"-"`
 }
 
-// Product Photo Entity
+// Product Slug Entity, a slug a product had before it was renamed. Its old
+// storefront URL redirects to the current slug, so no other product may
+// take it.
+type ProductSlug struct {
+	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
+	ProductID uint      `gorm:"column:id_produk;index" json:"product_id"`
+	Slug      string    `gorm:"column:slug;uniqueIndex" json:"slug"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// Product Photo Entity. Photos are shown by urutan; the first one is the
+// primary image of the product.
 type ProductPhoto struct {
 	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
 	ProductID uint      `gorm:"column:id_produk" json:"product_id"`
 	URL       string    `gorm:"column:url" json:"url"`
+	Position  int       `gorm:"column:urutan" json:"urutan"`
 	CreatedAt time.Time `gorm:"column:created_at" json:"-"`
 	UpdatedAt time.Time `gorm:"column:updated_at" json:"-"`
 }
 
+// Product Option Entity, such as size or color, with the values variants
+// choose from. A product has at most three.
+type ProductOption struct {
+	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
+	ProductID uint      `gorm:"column:id_produk;index" json:"product_id"`
+	Position  int       `gorm:"column:urutan" json:"urutan"`
+	Name      string    `gorm:"column:nama" json:"nama"`
+	Values    []string  `gorm:"column:nilai;serializer:json" json:"nilai"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"-"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"-"`
+}
+
+// Product Variant Entity, one combination of option values with its own
+// SKU, prices and stock. Values follow the order of the options; Name joins
+// them for display, e.g. "M / Merah".
+type ProductVariant struct {
+	ID            uint           `gorm:"primaryKey;column:id" json:"id"`
+	ProductID     uint           `gorm:"column:id_produk;index" json:"product_id"`
+	SKU           string         `gorm:"column:sku;uniqueIndex" json:"sku"`
+	Name          string         `gorm:"column:nama_varian" json:"nama_varian"`
+	Values        []string       `gorm:"column:nilai_opsi;serializer:json" json:"opsi"`
+	ResellerPrice money.Amount   `gorm:"column:harga_reseller" json:"harga_reseller"`
+	ConsumerPrice money.Amount   `gorm:"column:harga_konsumen" json:"harga_konsumen"`
+	Stock         int            `gorm:"column:stok" json:"stok"`
+	PhotoID       *uint          `gorm:"column:id_foto" json:"photo_id"`
+	Photo         *ProductPhoto  `gorm:"foreignKey:PhotoID" json:"foto,omitempty"`
+	CreatedAt     time.Time      `gorm:"column:created_at" json:"-"`
+	UpdatedAt     time.Time      `gorm:"column:updated_at" json:"-"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// Order statuses of a Transaction. The allowed transitions between them
+// live in the service package.
+const (
+	TrxStatusPendingPayment = "pending_payment"
+	TrxStatusPaid           = "paid"
+	TrxStatusProcessing     = "processing"
+	TrxStatusShipped        = "shipped"
+	TrxStatusDelivered      = "delivered"
+	TrxStatusCompleted      = "completed"
+	TrxStatusCancelled      = "cancelled"
+	TrxStatusRefunded       = "refunded"
+)
+
+// Actor roles recorded in the status history
+const (
+	ActorBuyer  = "buyer"
+	ActorSeller = "seller"
+	ActorAdmin  = "admin"
+	ActorSystem = "system"
+)
+
+// Order types of a Transaction. Reseller and dropship orders are placed by
+// resellers at harga_reseller; dropship orders go straight to the
+// reseller's customer, sent in the name of the reseller.
+const (
+	OrderTypeRegular  = "reguler"
+	OrderTypeReseller = "reseller"
+	OrderTypeDropship = "dropship"
+)
+
 // Transaction Entity
 type Transaction struct {
-	ID            uint                `gorm:"primaryKey;column:id" json:"id"`
-	UserID        uint                `gorm:"column:id_user" json:"user_id"`
-	AddressID     uint                `gorm:"column:alamat_pengiriman" json:"alamat_kirim"`
-	TotalPrice    float64             `gorm:"column:harga_total" json:"harga_total"`
-	InvoiceCode   string              `gorm:"column:kode_invoice" json:"kode_invoice"`
-	PaymentMethod string              `gorm:"column:method_bayar" json:"method_bayar"`
-	Address       Address             `gorm:"foreignKey:AddressID" json:"detail_alamat"`
-	Details       []TransactionDetail `gorm:"foreignKey:TransactionID" json:"detail_trx"`
-	CreatedAt     time.Time           `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt     time.Time           `gorm:"column:updated_at" json:"updated_at"`
-}
-
-// Transaction Detail Entity
-type TransactionDetail struct {
-	ID            uint       `gorm:"primaryKey;column:id" json:"id"`
-	TransactionID uint       `gorm:"column:id_trx" json:"trx_id"`
-	ProductLogID  uint       `gorm:"column:id_log_produk" json:"log_product_id"`
-	StoreID       uint       `gorm:"column:id_toko" json:"store_id"`
-	Quantity      int        `gorm:"column:kuantitas" json:"kuantitas"`
-	TotalPrice    float64    `gorm:"column:harga_total" json:"harga_total"`
-	ProductLog    ProductLog `gorm:"foreignKey:ProductLogID" json:"product"`
-	CreatedAt     time.Time  `gorm:"column:created_at" json:"-"`
-	UpdatedAt     time.Time  `gorm:"column:updated_at" json:"-"`
-}
-
-// Product Log Entity (Snapshot)
-type ProductLog struct {
+	ID               uint                       `gorm:"primaryKey;column:id" json:"id"`
+	UserID           uint                       `gorm:"column:id_user" json:"user_id"`
+	AddressID        uint                       `gorm:"column:alamat_pengiriman" json:"alamat_kirim"`
+	TotalPrice       money.Amount               `gorm:"column:harga_total" json:"harga_total"`
+	InvoiceCode      string                     `gorm:"column:kode_invoice;uniqueIndex:idx_transactions_invoice" json:"kode_invoice"`
+	PaymentMethod    string                     `gorm:"column:method_bayar" json:"method_bayar"`
+	OrderType        string                     `gorm:"column:jenis_pesanan;default:reguler" json:"jenis_pesanan"`
+	DropshipperName  string                     `gorm:"column:nama_dropshipper" json:"nama_dropshipper,omitempty"`
+	DropshipperPhone string                     `gorm:"column:telp_dropshipper" json:"telp_dropshipper,omitempty"`
+	Status           string                     `gorm:"column:status;default:pending_payment;index" json:"status"`
+	TrackingNumber   string                     `gorm:"column:no_resi" json:"no_resi"`
+	Address          Address                    `gorm:"foreignKey:AddressID" json:"detail_alamat"`
+	Details          []TransactionDetail        `gorm:"foreignKey:TransactionID" json:"detail_trx"`
+	StatusHistory    []TransactionStatusHistory `gorm:"foreignKey:TransactionID" json:"riwayat_status,omitempty"`
+	Payments         []Payment                  `gorm:"foreignKey:TransactionID" json:"pembayaran,omitempty"`
+	CreatedAt        time.Time                  `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt        time.Time                  `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// Transaction Status History Entity, one row per status change
+type TransactionStatusHistory struct {
 	ID            uint      `gorm:"primaryKey;column:id" json:"id"`
-	ProductID     uint      `gorm:"column:id_produk" json:"product_id"`
-	StoreID       uint      `gorm:"column:id_toko" json:"store_id"`
-	CategoryID    uint      `gorm:"column:id_category" json:"category_id"`
-	Name          string    `gorm:"column:nama_produk" json:"nama_produk"`
-	Slug          string    `gorm:"column:slug" json:"slug"`
-	ResellerPrice float64   `gorm:"column:harga_reseller" json:"harga_reseller"`
-	ConsumerPrice float64   `gorm:"column:harga_konsumen" json:"harga_konsumen"`
-	Description   string    `gorm:"column:deskripsi" json:"deskripsi"`
+	TransactionID uint      `gorm:"column:id_trx;index" json:"trx_id"`
+	FromStatus    string    `gorm:"column:status_awal" json:"status_awal"`
+	ToStatus      string    `gorm:"column:status" json:"status"`
+	ActorID       uint      `gorm:"column:id_actor" json:"id_actor"`
+	ActorRole     string    `gorm:"column:role_actor" json:"role_actor"`
+	Note          string    `gorm:"column:catatan" json:"catatan"`
 	CreatedAt     time.Time `gorm:"column:created_at" json:"created_at"`
-	UpdatedAt     time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// Transaction Detail Entity. Margin is what a reseller earns by selling the
+// line at harga_konsumen.
+type TransactionDetail struct {
+	ID            uint         `gorm:"primaryKey;column:id" json:"id"`
+	TransactionID uint         `gorm:"column:id_trx" json:"trx_id"`
+	ProductLogID  uint         `gorm:"column:id_log_produk" json:"log_product_id"`
+	StoreID       uint         `gorm:"column:id_toko" json:"store_id"`
+	Quantity      int          `gorm:"column:kuantitas" json:"kuantitas"`
+	TotalPrice    money.Amount `gorm:"column:harga_total" json:"harga_total"`
+	Margin        money.Amount `gorm:"column:margin" json:"margin"`
+	ProductLog    ProductLog   `gorm:"foreignKey:ProductLogID" json:"product"`
+	Transaction   *Transaction `gorm:"foreignKey:TransactionID" json:"-"`
+	CreatedAt     time.Time    `gorm:"column:created_at" json:"-"`
+	UpdatedAt     time.Time    `gorm:"column:updated_at" json:"-"`
+}
+
+// Product Log Entity (Snapshot). Lines of a variant snapshot it too, with
+// the prices of the variant.
+type ProductLog struct {
+	ID            uint         `gorm:"primaryKey;column:id" json:"id"`
+	ProductID     uint         `gorm:"column:id_produk" json:"product_id"`
+	StoreID       uint         `gorm:"column:id_toko" json:"store_id"`
+	CategoryID    uint         `gorm:"column:id_category" json:"category_id"`
+	Name          string       `gorm:"column:nama_produk" json:"nama_produk"`
+	Slug          string       `gorm:"column:slug" json:"slug"`
+	ResellerPrice money.Amount `gorm:"column:harga_reseller" json:"harga_reseller"`
+	ConsumerPrice money.Amount `gorm:"column:harga_konsumen" json:"harga_konsumen"`
+	Description   string       `gorm:"column:deskripsi" json:"deskripsi"`
+	VariantID     *uint        `gorm:"column:id_varian" json:"variant_id,omitempty"`
+	SKU           string       `gorm:"column:sku" json:"sku,omitempty"`
+	VariantName   string       `gorm:"column:nama_varian" json:"nama_varian,omitempty"`
+	CreatedAt     time.Time    `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time    `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// Review Entity, what a buyer thinks of a product bought in a completed
+// order, one per order line. It refers to the ProductLog snapshot of the
+// line, the version of the product that was bought. Hidden reviews are
+// left out of lists and ratings.
+type Review struct {
+	ID                  uint          `gorm:"primaryKey;column:id" json:"id"`
+	TransactionDetailID uint          `gorm:"column:id_detail_trx;uniqueIndex" json:"detail_trx_id"`
+	ProductLogID        uint          `gorm:"column:id_log_produk" json:"log_product_id"`
+	ProductID           uint          `gorm:"column:id_produk;index" json:"product_id"`
+	StoreID             uint          `gorm:"column:id_toko;index" json:"toko_id"`
+	UserID              uint          `gorm:"column:id_user;index" json:"id_user"`
+	Rating              int           `gorm:"column:rating" json:"rating"`
+	Text                string        `gorm:"column:ulasan" json:"ulasan"`
+	Reply               string        `gorm:"column:balasan" json:"balasan"`
+	RepliedAt           *time.Time    `gorm:"column:replied_at" json:"replied_at"`
+	Hidden              bool          `gorm:"column:disembunyikan" json:"disembunyikan"`
+	HiddenReason        string        `gorm:"column:alasan_disembunyikan" json:"alasan_disembunyikan,omitempty"`
+	HiddenBy            *uint         `gorm:"column:id_admin" json:"-"`
+	Photos              []ReviewPhoto `gorm:"foreignKey:ReviewID" json:"photos"`
+	ProductLog          ProductLog    `gorm:"foreignKey:ProductLogID" json:"product"`
+	CreatedAt           time.Time     `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt           time.Time     `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// Review Photo Entity
+type ReviewPhoto struct {
+	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
+	ReviewID  uint      `gorm:"column:id_ulasan;index" json:"review_id"`
+	URL       string    `gorm:"column:url" json:"url"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"-"`
+}
+
+// Payment Entity, one row per charge created at a payment provider
+type Payment struct {
+	ID            uint         `gorm:"primaryKey;column:id" json:"id"`
+	TransactionID uint         `gorm:"column:id_trx;index" json:"trx_id"`
+	Provider      string       `gorm:"column:provider;uniqueIndex:idx_payments_charge" json:"provider"`
+	ChargeID      string       `gorm:"column:charge_id;uniqueIndex:idx_payments_charge" json:"charge_id"`
+	Amount        money.Amount `gorm:"column:jumlah" json:"jumlah"`
+	Status        string       `gorm:"column:status" json:"status"`
+	PaymentURL    string       `gorm:"column:url_pembayaran" json:"url_pembayaran"`
+	ExpiresAt     time.Time    `gorm:"column:expired_at" json:"expired_at"`
+	PaidAt        *time.Time   `gorm:"column:paid_at" json:"paid_at"`
+	CreatedAt     time.Time    `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time    `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// Ledger entry types. Credits add to the commission balance of a reseller,
+// debits take from it.
+const (
+	LedgerCredit = "credit"
+	LedgerDebit  = "debit"
+)
+
+// LedgerEntry is one movement of the commission balance of a reseller.
+// Commission is credited per order line when the order completes, from the
+// prices in its ProductLog snapshot; payouts are debited when requested.
+// Reference is unique, so no movement can be recorded twice.
+type LedgerEntry struct {
+	ID                  uint         `gorm:"primaryKey;column:id" json:"id"`
+	UserID              uint         `gorm:"column:id_user;index" json:"id_user"`
+	Type                string       `gorm:"column:jenis" json:"jenis"`
+	Amount              money.Amount `gorm:"column:jumlah" json:"jumlah"`
+	Reference           string       `gorm:"column:referensi;uniqueIndex" json:"referensi"`
+	TransactionID       *uint        `gorm:"column:id_trx" json:"trx_id,omitempty"`
+	TransactionDetailID *uint        `gorm:"column:id_detail_trx" json:"detail_trx_id,omitempty"`
+	ProductLogID        *uint        `gorm:"column:id_log_produk" json:"log_product_id,omitempty"`
+	PayoutID            *uint        `gorm:"column:id_payout" json:"payout_id,omitempty"`
+	Note                string       `gorm:"column:keterangan" json:"keterangan"`
+	CreatedAt           time.Time    `gorm:"column:created_at" json:"created_at"`
+}
+
+// Payout statuses
+const (
+	PayoutPending  = "pending"
+	PayoutApproved = "approved"
+	PayoutRejected = "rejected"
+)
+
+// Payout is a request of a reseller to withdraw commission to a bank
+// account. Its amount is debited on request and credited back if an admin
+// rejects it.
+type Payout struct {
+	ID            uint         `gorm:"primaryKey;column:id" json:"id"`
+	UserID        uint         `gorm:"column:id_user;index" json:"id_user"`
+	Amount        money.Amount `gorm:"column:jumlah" json:"jumlah"`
+	BankName      string       `gorm:"column:nama_bank" json:"nama_bank"`
+	AccountNumber string       `gorm:"column:no_rekening" json:"no_rekening"`
+	AccountName   string       `gorm:"column:nama_rekening" json:"nama_rekening"`
+	Status        string       `gorm:"column:status;default:pending;index" json:"status"`
+	Note          string       `gorm:"column:catatan" json:"catatan"`
+	ProcessedBy   *uint        `gorm:"column:id_admin" json:"id_admin"`
+	ProcessedAt   *time.Time   `gorm:"column:processed_at" json:"processed_at"`
+	CreatedAt     time.Time    `gorm:"column:created_at" json:"created_at"`
+	UpdatedAt     time.Time    `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// InvoiceSequence holds the last invoice number issued in a scope, one
+// store on one day (see pkg/invoice)
+type InvoiceSequence struct {
+	Scope     string `gorm:"primaryKey;column:scope"`
+	LastValue int64  `gorm:"column:last_value"`
+}
+
+// IdempotencyKey remembers the response to a request sent with an
+// Idempotency-Key header, so retries of it are answered without running it
+// again. StatusCode is 0 while the first request is still being handled.
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primaryKey;column:id" json:"id"`
+	UserID      uint      `gorm:"column:id_user;uniqueIndex:idx_idempotency_keys_user_key" json:"id_user"`
+	Key         string    `gorm:"column:idempotency_key;uniqueIndex:idx_idempotency_keys_user_key" json:"key"`
+	Fingerprint string    `gorm:"column:fingerprint" json:"fingerprint"`
+	StatusCode  int       `gorm:"column:status_code" json:"status_code"`
+	ContentType string    `gorm:"column:content_type" json:"content_type"`
+	Response    []byte    `gorm:"column:response" json:"-"`
+	ExpiresAt   time.Time `gorm:"column:expired_at;index" json:"expired_at"`
+	CreatedAt   time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// Cart Entity, one per user
+type Cart struct {
+	ID        uint       `gorm:"primaryKey;column:id" json:"id"`
+	UserID    uint       `gorm:"uniqueIndex;column:id_user" json:"id_user"`
+	Items     []CartItem `gorm:"foreignKey:CartID" json:"items"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"-"`
+	UpdatedAt time.Time  `gorm:"column:updated_at" json:"-"`
+}
+
+// Cart Item Entity. VariantID is 0 for products without variants rather
+// than NULL, so the unique index holds for them too.
+type CartItem struct {
+	ID        uint      `gorm:"primaryKey;column:id" json:"id"`
+	CartID    uint      `gorm:"uniqueIndex:idx_cart_items_product;column:id_cart" json:"cart_id"`
+	ProductID uint      `gorm:"uniqueIndex:idx_cart_items_product;column:id_produk" json:"product_id"`
+	VariantID uint      `gorm:"uniqueIndex:idx_cart_items_product;column:id_varian" json:"variant_id"`
+	Quantity  int       `gorm:"column:kuantitas" json:"kuantitas"`
+	Product   Product   `gorm:"foreignKey:ProductID" json:"product"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"-"`
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"-"`
+}
+
+// Wishlist Item Entity, a product a user saved for later without ordering
+// it. SavedPrice is the harga_konsumen of the product when it was saved.
+type WishlistItem struct {
+	ID         uint         `gorm:"primaryKey;column:id" json:"id"`
+	UserID     uint         `gorm:"uniqueIndex:idx_wishlist_items_product;column:id_user" json:"id_user"`
+	ProductID  uint         `gorm:"uniqueIndex:idx_wishlist_items_product;column:id_produk" json:"product_id"`
+	SavedPrice money.Amount `gorm:"column:harga_disimpan" json:"harga_disimpan"`
+	Product    Product      `gorm:"foreignKey:ProductID" json:"product"`
+	CreatedAt  time.Time    `gorm:"column:created_at" json:"created_at"`
 }
 
 // API Response Wrappers
@@ -134,10 +400,130 @@ type Response struct {
 	Data    interface{} `json:"data"`
 }
 
+// Pagination is one page of a list. Total counts the rows of all pages.
+// NextCursor, when set, continues after this page in the same order; Page
+// is left out of pages reached by cursor.
 type Pagination struct {
-	Page  int         `json:"page"`
-	Limit int         `json:"limit"`
-	Data  interface{} `json:"data"`
+	Page       int         `json:"page,omitempty"`
+	Limit      int         `json:"limit"`
+	Total      int64       `json:"total"`
+	TotalPages int         `json:"total_pages"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Data       interface{} `json:"data"`
+}
+
+// ProductSearchQuery binds the query string of the product search. Zero
+// filters are ignored.
+type ProductSearchQuery struct {
+	Q          string        `form:"q"`
+	CategoryID uint          `form:"category_id"`
+	StoreID    uint          `form:"toko_id"`
+	MinPrice   *money.Amount `form:"min_harga"`
+	MaxPrice   *money.Amount `form:"max_harga"`
+	Page       int           `form:"page"`
+	Limit      int           `form:"limit"`
+}
+
+// ProductSearchResult is one page of search results, best match first,
+// with the facets of all of them
+type ProductSearchResult struct {
+	Page       int          `json:"page"`
+	Limit      int          `json:"limit"`
+	Total      int          `json:"total"`
+	TotalPages int          `json:"total_pages"`
+	Data       []Product    `json:"data"`
+	Facets     SearchFacets `json:"facets"`
+}
+
+// SearchFacets counts search results by category, store and price bucket
+type SearchFacets struct {
+	Categories []FacetCount `json:"category"`
+	Stores     []FacetCount `json:"toko"`
+	Prices     []PriceFacet `json:"harga"`
+}
+
+// FacetCount is the number of search results in one category or store
+type FacetCount struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"nama"`
+	Count int    `json:"count"`
+}
+
+// PriceFacet is the number of search results priced from Min up to, but
+// not including, Max. The last bucket has no Max.
+type PriceFacet struct {
+	Min   money.Amount  `json:"min_harga"`
+	Max   *money.Amount `json:"max_harga"`
+	Count int           `json:"count"`
+}
+
+// CartView is the cart priced with the current product data
+type CartView struct {
+	Items      []CartLine   `json:"items"`
+	TotalItems int          `json:"total_item"`
+	TotalPrice money.Amount `json:"harga_total"`
+	// CanCheckout is false while any line is unavailable or short of stock
+	CanCheckout bool `json:"bisa_checkout"`
+}
+
+type CartLine struct {
+	ID          uint         `json:"id"`
+	ProductID   uint         `json:"product_id"`
+	VariantID   uint         `json:"variant_id,omitempty"`
+	StoreID     uint         `json:"toko_id"`
+	Name        string       `json:"nama_produk"`
+	SKU         string       `json:"sku,omitempty"`
+	VariantName string       `json:"nama_varian,omitempty"`
+	PhotoURL    string       `json:"url_foto"`
+	Price       money.Amount `json:"harga_konsumen"`
+	Stock       int          `json:"stok"`
+	Quantity    int          `json:"kuantitas"`
+	TotalPrice  money.Amount `json:"harga_total"`
+	// Available is false once the product or variant is deleted or has too
+	// little stock
+	Available bool `json:"tersedia"`
+}
+
+// WishlistLine is a saved product with its current data and what changed
+// since it was saved. Product is nil once the product is deleted.
+type WishlistLine struct {
+	ID           uint         `json:"id"`
+	ProductID    uint         `json:"product_id"`
+	Product      *Product     `json:"product"`
+	SavedPrice   money.Amount `json:"harga_disimpan"`
+	Price        money.Amount `json:"harga_konsumen"`
+	PriceDropped bool         `json:"harga_turun"`
+	OutOfStock   bool         `json:"stok_habis"`
+	Available    bool         `json:"tersedia"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// StoreOrderLine is one line sold by a store, with what the seller needs
+// to fulfil it. Status actions take the TransactionID.
+type StoreOrderLine struct {
+	ID               uint         `json:"id"`
+	TransactionID    uint         `json:"trx_id"`
+	InvoiceCode      string       `json:"kode_invoice"`
+	Status           string       `json:"status"`
+	TrackingNumber   string       `json:"no_resi"`
+	PaymentMethod    string       `json:"method_bayar"`
+	OrderType        string       `json:"jenis_pesanan"`
+	DropshipperName  string       `json:"nama_dropshipper,omitempty"`
+	DropshipperPhone string       `json:"telp_dropshipper,omitempty"`
+	Quantity         int          `json:"kuantitas"`
+	TotalPrice       money.Amount `json:"harga_total"`
+	Product          ProductLog   `json:"product"`
+	Address          Address      `json:"alamat_kirim"`
+	OrderedAt        time.Time    `json:"created_at"`
+}
+
+// CommissionBalance sums the ledger of a reseller. Balance is what can still
+// be withdrawn; pending payouts are already debited from it.
+type CommissionBalance struct {
+	Balance       money.Amount `json:"saldo"`
+	Credit        money.Amount `json:"total_kredit"`
+	Debit         money.Amount `json:"total_debit"`
+	PendingPayout money.Amount `json:"payout_diproses"`
 }
 
 // Request Binding Structs
@@ -159,14 +545,196 @@ type LoginRequest struct {
 	Password string `json:"kata_sandi" binding:"required"`
 }
 
-// TrxItemRequest is a strict struct for transaction items
+type CategoryRequest struct {
+	Name string `json:"nama_category" binding:"required"`
+}
+
+// CreateProductRequest binds the multipart form of POST /product
+type CreateProductRequest struct {
+	Name          string       `form:"nama_produk" binding:"required"`
+	CategoryID    uint         `form:"category_id" binding:"required"`
+	ResellerPrice money.Amount `form:"harga_reseller" binding:"gte=0"`
+	ConsumerPrice money.Amount `form:"harga_konsumen" binding:"gte=0"`
+	Stock         int          `form:"stok" binding:"gte=0"`
+	Description   string       `form:"deskripsi"`
+}
+
+// UpdateProductRequest binds PATCH /product/:id, as JSON or as a form. Only
+// the fields sent are changed.
+type UpdateProductRequest struct {
+	Name          *string       `json:"nama_produk" form:"nama_produk" binding:"omitempty,min=1,max=255"`
+	CategoryID    *uint         `json:"category_id" form:"category_id" binding:"omitempty,gt=0"`
+	ResellerPrice *money.Amount `json:"harga_reseller" form:"harga_reseller" binding:"omitempty,gte=0"`
+	ConsumerPrice *money.Amount `json:"harga_konsumen" form:"harga_konsumen" binding:"omitempty,gte=0"`
+	Stock         *int          `json:"stok" form:"stok" binding:"omitempty,gte=0"`
+	Description   *string       `json:"deskripsi" form:"deskripsi"`
+}
+
+// ReorderPhotosRequest lists every photo of a product in the new order.
+type ReorderPhotosRequest struct {
+	PhotoIDs []uint `json:"photo_ids" binding:"required,min=1,dive,required"`
+}
+
+// ProductVariantsRequest replaces the options and variants of a product.
+// Every variant picks one value of each option, in the order of Opsi.
+// Sending both empty removes the variants.
+type ProductVariantsRequest struct {
+	Opsi   []ProductOptionRequest  `json:"opsi" binding:"max=3,dive"`
+	Varian []ProductVariantRequest `json:"varian" binding:"max=100,dive"`
+}
+
+type ProductOptionRequest struct {
+	Nama  string   `json:"nama" binding:"required,max=32"`
+	Nilai []string `json:"nilai" binding:"required,min=1,max=50,dive,required,max=32"`
+}
+
+// ProductVariantRequest is one variant. Variants keep their id across
+// replacements as long as their SKU stays the same.
+type ProductVariantRequest struct {
+	SKU           string       `json:"sku" binding:"required,max=64"`
+	Opsi          []string     `json:"opsi" binding:"required,min=1,max=3"`
+	ResellerPrice money.Amount `json:"harga_reseller" binding:"gte=0"`
+	ConsumerPrice money.Amount `json:"harga_konsumen" binding:"gte=0"`
+	Stok          int          `json:"stok" binding:"gte=0"`
+	PhotoID       uint         `json:"photo_id"`
+}
+
+// TrxItemRequest is a strict struct for transaction items. VariantID is
+// required for products with variants and must be left out for others.
+// CartItemID is set by checkout for the cart line the item is ordered from.
 type TrxItemRequest struct {
+	ProductID  uint `json:"product_id" binding:"required"`
+	VariantID  uint `json:"variant_id"`
+	Kuantitas  int  `json:"kuantitas" binding:"required,gt=0"`
+	CartItemID uint `json:"-"`
+}
+
+// TrxRequest places an order. JenisPesanan defaults to reguler; dropship
+// orders need the name and phone the parcel is sent in the name of.
+type TrxRequest struct {
+	MethodBayar     string           `json:"method_bayar" binding:"required"`
+	AlamatKirim     uint             `json:"alamat_kirim" binding:"required"`
+	JenisPesanan    string           `json:"jenis_pesanan" binding:"omitempty,oneof=reguler reseller dropship"`
+	NamaDropshipper string           `json:"nama_dropshipper" binding:"required_if=JenisPesanan dropship,max=100"`
+	TelpDropshipper string           `json:"telp_dropshipper" binding:"required_if=JenisPesanan dropship,max=32"`
+	DetailTrx       []TrxItemRequest `json:"detail_trx" binding:"required,min=1,dive"`
+}
+
+type CartItemRequest struct {
 	ProductID uint `json:"product_id" binding:"required"`
+	VariantID uint `json:"variant_id"`
 	Kuantitas int  `json:"kuantitas" binding:"required,gt=0"`
 }
 
-type TrxRequest struct {
-	MethodBayar string           `json:"method_bayar" binding:"required"`
-	AlamatKirim uint             `json:"alamat_kirim" binding:"required"`
-	DetailTrx   []TrxItemRequest `json:"detail_trx" binding:"required,dive"`
-}
\ No newline at end of file
+type WishlistItemRequest struct {
+	ProductID uint `json:"product_id" binding:"required"`
+}
+
+type UpdateCartItemRequest struct {
+	Kuantitas int `json:"kuantitas" binding:"required,gt=0"`
+}
+
+// CheckoutRequest turns the whole cart into one transaction per store
+type CheckoutRequest struct {
+	MethodBayar     string `json:"method_bayar" binding:"required"`
+	AlamatKirim     uint   `json:"alamat_kirim" binding:"required"`
+	JenisPesanan    string `json:"jenis_pesanan" binding:"omitempty,oneof=reguler reseller dropship"`
+	NamaDropshipper string `json:"nama_dropshipper" binding:"required_if=JenisPesanan dropship,max=100"`
+	TelpDropshipper string `json:"telp_dropshipper" binding:"required_if=JenisPesanan dropship,max=32"`
+}
+
+// PlacedOrder is one order created by POST /trx or a cart checkout, which
+// place one per store.
+type PlacedOrder struct {
+	ID          uint   `json:"id"`
+	InvoiceCode string `json:"kode_invoice"`
+}
+
+type CancelTrxRequest struct {
+	Reason string `json:"alasan"`
+}
+
+type ShipTrxRequest struct {
+	TrackingNumber string `json:"no_resi" binding:"required"`
+}
+
+// UpdateTrxStatusRequest lets admins move an order to any status the state
+// machine allows
+type UpdateTrxStatusRequest struct {
+	Status         string `json:"status" binding:"required"`
+	Note           string `json:"catatan"`
+	TrackingNumber string `json:"no_resi"`
+}
+
+// StoreOrderQuery binds the filters of GET /toko/my/orders. Dates are
+// inclusive and compared with the order time.
+type StoreOrderQuery struct {
+	Page        int    `form:"page"`
+	Limit       int    `form:"limit"`
+	Status      string `form:"status"`
+	InvoiceCode string `form:"kode_invoice"`
+	StartDate   string `form:"start_date" binding:"omitempty,datetime=2006-01-02"`
+	EndDate     string `form:"end_date" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// SetResellerRequest grants or revokes the reseller role of a user
+type SetResellerRequest struct {
+	IsReseller *bool `json:"is_reseller" binding:"required"`
+}
+
+// PayoutRequest asks to withdraw commission to a bank account
+type PayoutRequest struct {
+	Amount        money.Amount `json:"jumlah" binding:"gt=0"`
+	BankName      string       `json:"nama_bank" binding:"required,max=64"`
+	AccountNumber string       `json:"no_rekening" binding:"required,max=32"`
+	AccountName   string       `json:"nama_rekening" binding:"required,max=100"`
+}
+
+// ResolvePayoutRequest carries the note of an admin approving or rejecting
+// a payout
+type ResolvePayoutRequest struct {
+	Note string `json:"catatan" binding:"max=255"`
+}
+
+// LedgerQuery binds the pagination of the commission ledger
+type LedgerQuery struct {
+	Page  int `form:"page"`
+	Limit int `form:"limit"`
+}
+
+// PayoutQuery binds the filters of the payout lists
+type PayoutQuery struct {
+	Page   int    `form:"page"`
+	Limit  int    `form:"limit"`
+	Status string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+}
+
+// CreateReviewRequest reviews an order line, as JSON or as a multipart
+// form with photos
+type CreateReviewRequest struct {
+	DetailID uint   `json:"detail_trx_id" form:"detail_trx_id" binding:"required"`
+	Rating   int    `json:"rating" form:"rating" binding:"required,min=1,max=5"`
+	Text     string `json:"ulasan" form:"ulasan" binding:"max=2000"`
+}
+
+type ReplyReviewRequest struct {
+	Reply string `json:"balasan" binding:"required,max=1000"`
+}
+
+// HideReviewRequest carries the reason of an admin hiding a review
+type HideReviewRequest struct {
+	Reason string `json:"alasan" binding:"max=255"`
+}
+
+// ReviewQuery binds the filters of the review lists. Hidden is only
+// honoured on the lists of buyers and admins; public lists show visible
+// reviews.
+type ReviewQuery struct {
+	Rating int   `form:"rating" binding:"omitempty,min=1,max=5"`
+	Hidden *bool `form:"disembunyikan"`
+}
+
+// SimulatePaymentRequest settles a charge of the mock payment provider
+type SimulatePaymentRequest struct {
+	Status string `json:"status" binding:"required,oneof=paid failed expired"`
+}
@@ -0,0 +1,136 @@
+// Package apperror defines the error taxonomy of the API. Every error that
+// reaches a client carries a stable, machine-readable Code that frontends
+// can branch on; the HTTP status is derived from the code.
+package apperror
+
+import (
+	"errors"
+	"net/http"
+)
+
+type Code string
+
+const (
+	CodeBadRequest          Code = "BAD_REQUEST"
+	CodeValidation          Code = "VALIDATION_FAILED"
+	CodeUnauthorized        Code = "UNAUTHORIZED"
+	CodeInvalidCredentials  Code = "INVALID_CREDENTIALS"
+	CodeForbidden           Code = "FORBIDDEN"
+	CodeNotFound            Code = "NOT_FOUND"
+	CodeConflict            Code = "CONFLICT"
+	CodeOutOfStock          Code = "OUT_OF_STOCK"
+	CodeInvalidTransition   Code = "INVALID_STATUS_TRANSITION"
+	CodeIdempotencyReused   Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeInsufficientBalance Code = "INSUFFICIENT_BALANCE"
+	CodeInternal            Code = "INTERNAL_ERROR"
+)
+
+var statusByCode = map[Code]int{
+	CodeBadRequest:          http.StatusBadRequest,
+	CodeValidation:          http.StatusBadRequest,
+	CodeUnauthorized:        http.StatusUnauthorized,
+	CodeInvalidCredentials:  http.StatusUnauthorized,
+	CodeForbidden:           http.StatusForbidden,
+	CodeNotFound:            http.StatusNotFound,
+	CodeConflict:            http.StatusConflict,
+	CodeOutOfStock:          http.StatusConflict,
+	CodeInvalidTransition:   http.StatusConflict,
+	CodeIdempotencyReused:   http.StatusUnprocessableEntity,
+	CodeInsufficientBalance: http.StatusConflict,
+	CodeInternal:            http.StatusInternalServerError,
+}
+
+// Error is an error with a stable code. Fields lists per-field problems for
+// validation errors; Details carries any extra machine-readable payload.
+type Error struct {
+	Code    Code
+	Message string
+	Fields  []FieldError
+	Details interface{}
+	Err     error
+}
+
+// FieldError describes one invalid request field. Rule is the validation
+// rule that failed, for example "required" or "email".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap attaches a code to err while keeping it available to errors.Is/As.
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil && e.Message == "" {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is an *Error with the same code, so errors built
+// with extra details still match the package level sentinels.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// HTTPStatus maps a code to its HTTP status.
+func HTTPStatus(code Code) int {
+	if status, ok := statusByCode[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// From returns the *Error in err's chain. The message is taken from the
+// outermost error so context added with fmt.Errorf("...: %w") is kept.
+// Errors without a code become CodeInternal and hide the cause.
+func From(err error) *Error {
+	var appErr *Error
+	if !errors.As(err, &appErr) {
+		return &Error{Code: CodeInternal, Message: "internal server error", Err: err}
+	}
+	if appErr.Code == CodeInternal {
+		return appErr
+	}
+	return &Error{
+		Code:    appErr.Code,
+		Message: err.Error(),
+		Fields:  appErr.Fields,
+		Details: appErr.Details,
+		Err:     err,
+	}
+}
+
+// Item is the JSON shape of one entry in the response "errors" list.
+type Item struct {
+	Code    Code        `json:"code"`
+	Message string      `json:"message"`
+	Field   string      `json:"field,omitempty"`
+	Rule    string      `json:"rule,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Items renders the error for the response envelope. Validation errors
+// produce one item per field.
+func (e *Error) Items() []Item {
+	if len(e.Fields) == 0 {
+		return []Item{{Code: e.Code, Message: e.Message, Details: e.Details}}
+	}
+	items := make([]Item, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		items = append(items, Item{Code: e.Code, Message: f.Message, Field: f.Field, Rule: f.Rule})
+	}
+	return items
+}
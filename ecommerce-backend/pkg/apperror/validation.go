@@ -0,0 +1,113 @@
+package apperror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation builds a CodeValidation error from field errors.
+func Validation(fields ...FieldError) *Error {
+	return &Error{Code: CodeValidation, Message: "validation failed", Fields: fields}
+}
+
+// FromBinding translates an error returned by gin's ShouldBind* into an
+// *Error, with one FieldError per failed validator tag.
+func FromBinding(err error) *Error {
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		fields := make([]FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			fields = append(fields, FieldError{
+				Field:   fieldPath(fe),
+				Rule:    fe.Tag(),
+				Message: ruleMessage(fe),
+			})
+		}
+		return Validation(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return Validation(FieldError{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type.String()),
+		})
+	}
+
+	if errors.Is(err, io.EOF) {
+		return Wrap(CodeBadRequest, "request body is empty", err)
+	}
+	return Wrap(CodeBadRequest, "malformed request body: "+err.Error(), err)
+}
+
+// JSONFieldName makes the validator report fields by their json (or form)
+// tag. Register it with validator.Validate.RegisterTagNameFunc.
+func JSONFieldName(fld reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name := strings.SplitN(fld.Tag.Get(tag), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return fld.Name
+}
+
+// fieldPath drops the top-level struct name from the namespace, so nested
+// fields read like "detail_trx[0].kuantitas".
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.Index(ns, "."); i >= 0 {
+		return ns[i+1:]
+	}
+	return fe.Field()
+}
+
+func ruleMessage(fe validator.FieldError) string {
+	field := fieldPath(fe)
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "required_if":
+		// The param is "OtherField value"; the value alone reads well enough
+		params := strings.Fields(fe.Param())
+		return fmt.Sprintf("%s is required for %s", field, params[len(params)-1])
+	case "email":
+		return field + " must be a valid email address"
+	case "min":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		}
+		if fe.Kind() == reflect.Slice {
+			return fmt.Sprintf("%s must contain at least %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if fe.Kind() == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, fe.Param())
+	case "gte":
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, fe.Param())
+	case "lte":
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, fe.Param())
+	case "datetime":
+		return fmt.Sprintf("%s must be a date in the format %s", field, fe.Param())
+	}
+	return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+}
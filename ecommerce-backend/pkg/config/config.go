@@ -0,0 +1,368 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// DefaultJWTSecret is the development secret. The server refuses to start
+// with it when running in production mode.
+const DefaultJWTSecret = "supersecretkey"
+
+// DefaultMockWebhookSecret signs the webhooks of the mock payment provider
+// in development. Production refuses it as well.
+const DefaultMockWebhookSecret = "mock-webhook-secret"
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+type Config struct {
+	Env         string            `yaml:"env" toml:"env"`
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	JWT         JWTConfig         `yaml:"jwt" toml:"jwt"`
+	Upload      UploadConfig      `yaml:"upload" toml:"upload"`
+	Payment     PaymentConfig     `yaml:"payment" toml:"payment"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+	Search      SearchConfig      `yaml:"search" toml:"search"`
+}
+
+type ServerConfig struct {
+	Host string `yaml:"host" toml:"host"`
+	Port int    `yaml:"port" toml:"port"`
+}
+
+type DatabaseConfig struct {
+	Driver          string   `yaml:"driver" toml:"driver"`
+	DSN             string   `yaml:"dsn" toml:"dsn"`
+	MaxOpenConns    int      `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int      `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+	// MigrateOnBoot applies pending migrations when the server starts.
+	// When disabled the server refuses to start with pending migrations.
+	MigrateOnBoot bool `yaml:"migrate_on_boot" toml:"migrate_on_boot"`
+}
+
+type JWTConfig struct {
+	Secret string   `yaml:"secret" toml:"secret"`
+	TTL    Duration `yaml:"ttl" toml:"ttl"`
+}
+
+type UploadConfig struct {
+	Path string `yaml:"path" toml:"path"`
+}
+
+type PaymentConfig struct {
+	// MockEnabled registers the local mock provider, which never moves
+	// money and lets developers simulate payment outcomes.
+	MockEnabled       bool   `yaml:"mock_enabled" toml:"mock_enabled"`
+	MockWebhookSecret string `yaml:"mock_webhook_secret" toml:"mock_webhook_secret"`
+	// Deadline is how long a buyer has to pay an order after placing it.
+	Deadline Duration `yaml:"deadline" toml:"deadline"`
+	// ExpiryInterval is how often unpaid orders past the deadline are
+	// cancelled. Zero disables the job on this instance.
+	ExpiryInterval Duration `yaml:"expiry_interval" toml:"expiry_interval"`
+}
+
+type IdempotencyConfig struct {
+	// Window is how long a key is remembered after its first request.
+	Window Duration `yaml:"window" toml:"window"`
+	// Lease is how long a request holds its key. A key still unanswered
+	// after it, left by a request that crashed, is given to the next retry.
+	Lease Duration `yaml:"lease" toml:"lease"`
+}
+
+type SearchConfig struct {
+	// SyncInterval is how often the product search index applies the
+	// products other replicas created, updated or deleted. Zero disables
+	// it; a single instance keeps its index current anyway.
+	SyncInterval Duration `yaml:"sync_interval" toml:"sync_interval"`
+	// RefreshInterval is how often the index is rebuilt from the database
+	// whole, picking up stores and categories renamed by other replicas.
+	// Zero rebuilds it at startup only.
+	RefreshInterval Duration `yaml:"refresh_interval" toml:"refresh_interval"`
+}
+
+// Duration accepts Go duration strings such as "24h" or "90s" in config files.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the configuration used when nothing is overridden.
+func Default() *Config {
+	return &Config{
+		Env: EnvDevelopment,
+		Server: ServerConfig{
+			Port: 8000,
+		},
+		Database: DatabaseConfig{
+			Driver:          DriverMySQL,
+			DSN:             "root:mysql@tcp(127.0.0.1:3306)/evermos_db?charset=utf8mb4&parseTime=True&loc=Local",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: Duration{time.Hour},
+			MigrateOnBoot:   true,
+		},
+		JWT: JWTConfig{
+			Secret: DefaultJWTSecret,
+			TTL:    Duration{24 * time.Hour},
+		},
+		Upload: UploadConfig{
+			Path: "public/uploads",
+		},
+		Payment: PaymentConfig{
+			MockEnabled:       true,
+			MockWebhookSecret: DefaultMockWebhookSecret,
+			Deadline:          Duration{24 * time.Hour},
+			ExpiryInterval:    Duration{time.Minute},
+		},
+		Idempotency: IdempotencyConfig{
+			Window: Duration{24 * time.Hour},
+			Lease:  Duration{time.Minute},
+		},
+		Search: SearchConfig{
+			SyncInterval:    Duration{5 * time.Second},
+			RefreshInterval: Duration{5 * time.Minute},
+		},
+	}
+}
+
+// Load builds the configuration from defaults, then the optional file at path
+// (YAML or TOML, chosen by extension), then environment variables.
+// An empty path falls back to the CONFIG_FILE environment variable.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	var errs []error
+
+	envString("APP_ENV", &c.Env)
+	envString("SERVER_HOST", &c.Server.Host)
+	errs = append(errs, envInt("SERVER_PORT", &c.Server.Port))
+
+	envString("DB_DRIVER", &c.Database.Driver)
+	envString("DB_DSN", &c.Database.DSN)
+	errs = append(errs,
+		envInt("DB_MAX_OPEN_CONNS", &c.Database.MaxOpenConns),
+		envInt("DB_MAX_IDLE_CONNS", &c.Database.MaxIdleConns),
+		envDuration("DB_CONN_MAX_LIFETIME", &c.Database.ConnMaxLifetime),
+		envBool("DB_MIGRATE_ON_BOOT", &c.Database.MigrateOnBoot),
+	)
+
+	envString("JWT_SECRET", &c.JWT.Secret)
+	errs = append(errs, envDuration("JWT_TTL", &c.JWT.TTL))
+
+	envString("UPLOAD_PATH", &c.Upload.Path)
+
+	envString("PAYMENT_MOCK_WEBHOOK_SECRET", &c.Payment.MockWebhookSecret)
+	errs = append(errs,
+		envBool("PAYMENT_MOCK_ENABLED", &c.Payment.MockEnabled),
+		envDuration("PAYMENT_DEADLINE", &c.Payment.Deadline),
+		envDuration("PAYMENT_EXPIRY_INTERVAL", &c.Payment.ExpiryInterval),
+	)
+
+	errs = append(errs,
+		envDuration("IDEMPOTENCY_WINDOW", &c.Idempotency.Window),
+		envDuration("IDEMPOTENCY_LEASE", &c.Idempotency.Lease),
+	)
+
+	errs = append(errs,
+		envDuration("SEARCH_SYNC_INTERVAL", &c.Search.SyncInterval),
+		envDuration("SEARCH_REFRESH_INTERVAL", &c.Search.RefreshInterval),
+	)
+
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		errs = append(errs, fmt.Errorf("env must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env))
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	switch c.Database.Driver {
+	case DriverMySQL, DriverPostgres, DriverSQLite:
+	default:
+		errs = append(errs, fmt.Errorf("database.driver must be one of %q, %q or %q, got %q",
+			DriverMySQL, DriverPostgres, DriverSQLite, c.Database.Driver))
+	}
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn is required"))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database connection pool limits must not be negative"))
+	}
+	if c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		errs = append(errs, errors.New("database.max_idle_conns must not exceed database.max_open_conns"))
+	}
+	if c.Database.ConnMaxLifetime.Duration < 0 {
+		errs = append(errs, errors.New("database.conn_max_lifetime must not be negative"))
+	}
+	if c.JWT.Secret == "" {
+		errs = append(errs, errors.New("jwt.secret is required"))
+	}
+	if c.IsProduction() && c.JWT.Secret == DefaultJWTSecret {
+		errs = append(errs, errors.New("jwt.secret must be changed from the default in production"))
+	}
+	if c.JWT.TTL.Duration <= 0 {
+		errs = append(errs, errors.New("jwt.ttl must be positive"))
+	}
+	if c.Upload.Path == "" {
+		errs = append(errs, errors.New("upload.path is required"))
+	}
+	if c.Payment.MockEnabled && c.Payment.MockWebhookSecret == "" {
+		errs = append(errs, errors.New("payment.mock_webhook_secret is required when the mock provider is enabled"))
+	}
+	if c.IsProduction() && c.Payment.MockEnabled && c.Payment.MockWebhookSecret == DefaultMockWebhookSecret {
+		errs = append(errs, errors.New("payment.mock_webhook_secret must be changed from the default in production"))
+	}
+	if c.Payment.Deadline.Duration <= 0 {
+		errs = append(errs, errors.New("payment.deadline must be positive"))
+	}
+	if c.Payment.ExpiryInterval.Duration < 0 {
+		errs = append(errs, errors.New("payment.expiry_interval must not be negative"))
+	}
+	if c.Idempotency.Window.Duration <= 0 {
+		errs = append(errs, errors.New("idempotency.window must be positive"))
+	}
+	if c.Idempotency.Lease.Duration <= 0 {
+		errs = append(errs, errors.New("idempotency.lease must be positive"))
+	}
+	if c.Search.SyncInterval.Duration < 0 {
+		errs = append(errs, errors.New("search.sync_interval must not be negative"))
+	}
+	if c.Search.RefreshInterval.Duration < 0 {
+		errs = append(errs, errors.New("search.refresh_interval must not be negative"))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// Addr is the listen address passed to gin.
+func (s ServerConfig) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+func envString(key string, dst *string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = v
+	}
+}
+
+func envInt(key string, dst *int) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func envBool(key string, dst *bool) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+func envDuration(key string, dst *Duration) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	dst.Duration = d
+	return nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"ecommerce-backend/pkg/config"
+	"fmt"
+	"strings"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// Open connects to the database selected by cfg.Driver and applies the
+// connection pool limits.
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	dialector, err := newDialector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime.Duration)
+
+	if cfg.Driver == config.DriverSQLite {
+		// SQLite allows a single writer, and every connection to ":memory:"
+		// opens a separate database, so share one connection and never
+		// close it: that would throw the in-memory database away.
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
+	return db, nil
+}
+
+func newDialector(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case config.DriverMySQL:
+		return mysql.Open(cfg.DSN), nil
+	case config.DriverPostgres:
+		return postgres.Open(cfg.DSN), nil
+	case config.DriverSQLite:
+		return sqlite.Open(sqliteDSN(cfg.DSN)), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+}
+
+// sqliteDSN enables foreign keys and a busy timeout so SQLite enforces the
+// same constraints as MySQL and Postgres.
+func sqliteDSN(dsn string) string {
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
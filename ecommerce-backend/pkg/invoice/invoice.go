@@ -0,0 +1,20 @@
+// Package invoice formats invoice numbers such as INV/20261017/TOKO12/000123:
+// the order date, the store that sold it and a sequence that restarts every
+// day for every store. The sequence itself is kept by the database.
+package invoice
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scope is the part of an invoice number shared by every invoice of a
+// store on a day, and the key of its sequence.
+func Scope(date time.Time, storeID uint) string {
+	return fmt.Sprintf("%s/TOKO%d", date.Format("20060102"), storeID)
+}
+
+// Number formats the seq-th invoice number of scope.
+func Number(scope string, seq int64) string {
+	return fmt.Sprintf("INV/%s/%06d", scope, seq)
+}
@@ -1,8 +1,8 @@
 package middleware
 
 import (
+	"ecommerce-backend/pkg/apperror"
 	"ecommerce-backend/pkg/utils"
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -11,51 +11,80 @@ import (
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			// Try to get from custom header "token" as per postman collection sometimes
-			authHeader = c.GetHeader("token")
-		}
-
-		if authHeader == "" {
-			utils.APIResponse(c, http.StatusUnauthorized, false, "Unauthorized", nil, []string{"No token found"})
+		tokenString := bearerToken(c)
+		if tokenString == "" {
+			c.Error(apperror.New(apperror.CodeUnauthorized, "No token found"))
 			c.Abort()
 			return
 		}
 
-		// Handle Bearer prefix if present
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return utils.SecretKey, nil
-		})
-
-		if err != nil || !token.Valid {
-			utils.APIResponse(c, http.StatusUnauthorized, false, "Unauthorized", nil, []string{"Invalid token"})
+		claims, err := parseToken(tokenString)
+		if err != nil {
+			c.Error(err)
 			c.Abort()
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			utils.APIResponse(c, http.StatusUnauthorized, false, "Unauthorized", nil, []string{"Invalid token claims"})
-			c.Abort()
-			return
+		setUser(c, claims)
+		c.Next()
+	}
+}
+
+// OptionalAuth is AuthMiddleware for public routes that personalize their
+// response: a valid token sets user_id and is_admin, anything else lets the
+// request through anonymously.
+func OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if tokenString := bearerToken(c); tokenString != "" {
+			if claims, err := parseToken(tokenString); err == nil {
+				setUser(c, claims)
+			}
 		}
+		c.Next()
+	}
+}
+
+// bearerToken returns the token of the request, or "" if it has none.
+func bearerToken(c *gin.Context) string {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		// Try to get from custom header "token" as per postman collection sometimes
+		authHeader = c.GetHeader("token")
+	}
 
-		// Set context
-		c.Set("user_id", uint(claims["user_id"].(float64)))
-		c.Set("is_admin", claims["is_admin"].(bool))
+	// Handle Bearer prefix if present
+	return strings.Replace(authHeader, "Bearer ", "", 1)
+}
 
-		c.Next()
+// parseToken verifies a token signed by GenerateToken. Claims of the
+// wrong type fail to decode, and tokens without an expiry or a user are
+// refused rather than read as user 0.
+func parseToken(tokenString string) (*utils.Claims, error) {
+	claims := &utils.Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return utils.SecretKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+
+	if err != nil || !token.Valid {
+		return nil, apperror.New(apperror.CodeUnauthorized, "Invalid token")
+	}
+
+	if claims.UserID == 0 {
+		return nil, apperror.New(apperror.CodeUnauthorized, "Invalid token claims")
 	}
+	return claims, nil
+}
+
+// setUser puts the user of claims in the context for handlers.
+func setUser(c *gin.Context, claims *utils.Claims) {
+	c.Set("user_id", claims.UserID)
+	c.Set("is_admin", claims.IsAdmin)
 }
 
 func AdminOnly() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		isAdmin, exists := c.Get("is_admin")
-		if !exists || !isAdmin.(bool) {
-			utils.APIResponse(c, http.StatusForbidden, false, "Forbidden", nil, []string{"Admin access required"})
+		if !c.GetBool("is_admin") {
+			c.Error(apperror.New(apperror.CodeForbidden, "Admin access required"))
 			c.Abort()
 			return
 		}
@@ -0,0 +1,128 @@
+package middleware_test
+
+import (
+	"ecommerce-backend/pkg/middleware"
+	"ecommerce-backend/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func sign(t *testing.T, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	t.Helper()
+	key := interface{}(utils.SecretKey)
+	if method == jwt.SigningMethodNone {
+		key = jwt.UnsafeAllowNoneSignatureType
+	}
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// router serves the user the middleware found, "anonymous" without one.
+func router(auth gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.ErrorHandler())
+	r.GET("/", auth, func(c *gin.Context) {
+		userID, ok := c.Get("user_id")
+		if !ok {
+			c.String(http.StatusOK, "anonymous")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"user_id": userID, "is_admin": c.GetBool("is_admin")})
+	})
+	r.GET("/admin", auth, middleware.AdminOnly(), func(c *gin.Context) {
+		c.String(http.StatusOK, "admin")
+	})
+	return r
+}
+
+func get(r *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTokens(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	valid, err := utils.GenerateToken(7, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		// body is what AuthMiddleware lets through; "" means 401
+		body string
+	}{
+		{"valid", valid, `{"is_admin":true,"user_id":7}`},
+		{"missing is_admin", sign(t, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7, "exp": exp}), `{"is_admin":false,"user_id":7}`},
+		{"missing user_id", sign(t, jwt.SigningMethodHS256, jwt.MapClaims{"is_admin": true, "exp": exp}), ""},
+		{"zero user_id", sign(t, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 0, "exp": exp}), ""},
+		{"string user_id", sign(t, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": "7", "exp": exp}), ""},
+		{"string is_admin", sign(t, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7, "is_admin": "true", "exp": exp}), ""},
+		{"missing exp", sign(t, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7}), ""},
+		{"expired", sign(t, jwt.SigningMethodHS256, jwt.MapClaims{"user_id": 7, "exp": time.Now().Add(-time.Minute).Unix()}), ""},
+		{"HS384", sign(t, jwt.SigningMethodHS384, jwt.MapClaims{"user_id": 7, "exp": exp}), ""},
+		{"alg none", sign(t, jwt.SigningMethodNone, jwt.MapClaims{"user_id": 7, "exp": exp}), ""},
+		{"garbage", "not-a-token", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(router(middleware.AuthMiddleware()), "/", tt.token)
+			if tt.body == "" {
+				if rec.Code != http.StatusUnauthorized {
+					t.Fatalf("AuthMiddleware: got %d %s, want 401", rec.Code, rec.Body)
+				}
+			} else if rec.Code != http.StatusOK || rec.Body.String() != tt.body {
+				t.Fatalf("AuthMiddleware: got %d %s, want %s", rec.Code, rec.Body, tt.body)
+			}
+
+			want := tt.body
+			if want == "" {
+				want = "anonymous"
+			}
+			rec = get(router(middleware.OptionalAuth()), "/", tt.token)
+			if rec.Code != http.StatusOK || rec.Body.String() != want {
+				t.Fatalf("OptionalAuth: got %d %s, want %s", rec.Code, rec.Body, want)
+			}
+		})
+	}
+}
+
+func TestNoToken(t *testing.T) {
+	if rec := get(router(middleware.AuthMiddleware()), "/", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("AuthMiddleware: got %d, want 401", rec.Code)
+	}
+	if rec := get(router(middleware.OptionalAuth()), "/", ""); rec.Body.String() != "anonymous" {
+		t.Fatalf("OptionalAuth: got %s, want anonymous", rec.Body)
+	}
+}
+
+func TestAdminOnly(t *testing.T) {
+	admin, _ := utils.GenerateToken(1, true)
+	buyer, _ := utils.GenerateToken(2, false)
+	for _, auth := range []gin.HandlerFunc{middleware.AuthMiddleware(), middleware.OptionalAuth()} {
+		if rec := get(router(auth), "/admin", admin); rec.Code != http.StatusOK {
+			t.Fatalf("admin: got %d", rec.Code)
+		}
+		if rec := get(router(auth), "/admin", buyer); rec.Code != http.StatusForbidden {
+			t.Fatalf("buyer: got %d, want 403", rec.Code)
+		}
+	}
+	if rec := get(router(middleware.OptionalAuth()), "/admin", ""); rec.Code != http.StatusForbidden {
+		t.Fatalf("anonymous: got %d, want 403", rec.Code)
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"ecommerce-backend/pkg/apperror"
+	"ecommerce-backend/pkg/utils"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorHandler turns the last error attached with c.Error into the standard
+// response envelope. Handlers and middleware only need to call c.Error and
+// return (or Abort).
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		appErr := apperror.From(c.Errors.Last().Err)
+		if appErr.Code == apperror.CodeInternal {
+			log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, c.Errors.Last().Err)
+		}
+		utils.APIResponse(c, apperror.HTTPStatus(appErr.Code), false, failMessage(c, appErr.Code), nil, appErr.Items())
+	}
+}
+
+// NotFound answers unknown routes with the standard envelope.
+func NotFound(c *gin.Context) {
+	c.Error(apperror.New(apperror.CodeNotFound, "route not found"))
+}
+
+func failMessage(c *gin.Context, code apperror.Code) string {
+	switch code {
+	case apperror.CodeUnauthorized:
+		return "Unauthorized"
+	case apperror.CodeForbidden:
+		return "Forbidden"
+	}
+
+	switch c.Request.Method {
+	case http.MethodPost:
+		return "Failed to POST data"
+	case http.MethodPut, http.MethodPatch:
+		return "Failed to UPDATE data"
+	case http.MethodDelete:
+		return "Failed to DELETE data"
+	}
+	return "Failed to GET data"
+}
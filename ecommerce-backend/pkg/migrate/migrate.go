@@ -0,0 +1,278 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Migration is one numbered schema change. Up and Down run inside a database
+// transaction together with the bookkeeping row in schema_migrations.
+// Note that MySQL commits DDL implicitly, so a failed MySQL migration may
+// leave partial changes behind.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// Status describes one migration as seen by the database.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+	// Missing is set for versions recorded in the database that this binary
+	// does not know about.
+	Missing bool `json:"missing"`
+}
+
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false;column:version"`
+	Name      string    `gorm:"size:255;column:name"`
+	AppliedAt time.Time `gorm:"column:applied_at"`
+}
+
+func (schemaMigration) TableName() string { return "schema_migrations" }
+
+type migrationLock struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false;column:id"`
+	Owner    string    `gorm:"size:255;column:owner"`
+	LockedAt time.Time `gorm:"column:locked_at"`
+}
+
+func (migrationLock) TableName() string { return "schema_migration_lock" }
+
+var ErrLockTimeout = errors.New("migrate: timed out waiting for migration lock")
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+
+	// LockWait bounds how long Up and Down wait for another process that
+	// holds the migration lock.
+	LockWait time.Duration
+	// StaleLock is the age after which a lock left by a crashed process is
+	// taken over.
+	StaleLock time.Duration
+}
+
+func New(db *gorm.DB, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	return &Migrator{
+		db:         db,
+		migrations: sorted,
+		LockWait:   2 * time.Minute,
+		StaleLock:  15 * time.Minute,
+	}
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		done, err := m.appliedVersions(db)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			if err := m.apply(db, mig); err != nil {
+				return err
+			}
+			applied = append(applied, mig)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the most recent steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := m.withLock(ctx, func(db *gorm.DB) error {
+		done, err := m.appliedVersions(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if err := m.revert(db, mig); err != nil {
+				return err
+			}
+			reverted = append(reverted, mig)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every known migration and any unknown versions found in the
+// database, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.db.WithContext(ctx)
+	if err := m.ensureTables(db); err != nil {
+		return nil, err
+	}
+	done, err := m.appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, mig := range m.migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if row, ok := done[mig.Version]; ok {
+			appliedAt := row.AppliedAt
+			s.AppliedAt = &appliedAt
+			delete(done, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, row := range done {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending returns the known migrations that have not been applied yet.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	db := m.db.WithContext(ctx)
+	if err := m.ensureTables(db); err != nil {
+		return nil, err
+	}
+	done, err := m.appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, mig := range m.migrations {
+		if _, ok := done[mig.Version]; !ok {
+			pending = append(pending, mig)
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) apply(db *gorm.DB, mig Migration) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := mig.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Version: mig.Version, Name: mig.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: up %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(db *gorm.DB, mig Migration) error {
+	if mig.Down == nil {
+		return fmt.Errorf("migrate: %d_%s is irreversible", mig.Version, mig.Name)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := mig.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&schemaMigration{}, mig.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: down %d_%s: %w", mig.Version, mig.Name, err)
+	}
+	return nil
+}
+
+func (m *Migrator) appliedVersions(db *gorm.DB) (map[int64]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+func (m *Migrator) ensureTables(db *gorm.DB) error {
+	var err error
+	// Replicas booting together race to create these tables; a retry sees
+	// the table the other process created.
+	quiet := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)})
+	for attempt := 0; attempt < 3; attempt++ {
+		if err = quiet.AutoMigrate(&schemaMigration{}, &migrationLock{}); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// withLock runs fn while holding the row lock in schema_migration_lock, so
+// replicas booting at the same time apply migrations only once.
+func (m *Migrator) withLock(ctx context.Context, fn func(db *gorm.DB) error) error {
+	db := m.db.WithContext(ctx)
+	if err := m.ensureTables(db); err != nil {
+		return err
+	}
+
+	owner := lockOwner()
+	deadline := time.Now().Add(m.LockWait)
+	// Failed inserts are expected while another process holds the lock.
+	quiet := db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Silent)})
+	for {
+		err := quiet.Create(&migrationLock{ID: 1, Owner: owner, LockedAt: time.Now()}).Error
+		if err == nil {
+			break
+		}
+
+		var held migrationLock
+		if findErr := db.First(&held, 1).Error; findErr != nil {
+			if errors.Is(findErr, gorm.ErrRecordNotFound) {
+				// Released between our insert and read; try again.
+				continue
+			}
+			return findErr
+		}
+		if time.Since(held.LockedAt) > m.StaleLock {
+			db.Where("id = ? AND owner = ?", 1, held.Owner).Delete(&migrationLock{})
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w (held by %s since %s)", ErrLockTimeout, held.Owner, held.LockedAt.Format(time.RFC3339))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	defer db.Where("id = ? AND owner = ?", 1, owner).Delete(&migrationLock{})
+
+	return fn(db)
+}
+
+func lockOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+}
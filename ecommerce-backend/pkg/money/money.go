@@ -0,0 +1,149 @@
+// Package money represents rupiah amounts exactly, as an integer number of
+// sen (1/100 rupiah, the ISO 4217 minor unit of IDR), so prices and totals
+// never pick up floating point rounding errors.
+//
+// Amounts are stored in BIGINT columns as sen but appear in JSON, forms and
+// query strings as decimal rupiah, for example 15000 or 15000.5, so clients
+// keep reading and sending rupiah.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Amount is a number of sen.
+type Amount int64
+
+const (
+	scale = 100
+	// maxDigits keeps the integer part of parsed amounts within int64 sen.
+	maxDigits = 15
+)
+
+var ErrInvalid = errors.New("invalid amount: use a rupiah number with at most 2 decimals")
+
+// Rupiah returns the Amount of r whole rupiah.
+func Rupiah(r int64) Amount {
+	return Amount(r * scale)
+}
+
+// Parse reads a decimal rupiah amount such as "15000", "-2500" or
+// "15000.50". More than two decimals, exponents and other notations are
+// rejected rather than rounded.
+func Parse(s string) (Amount, error) {
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || len(whole) > maxDigits || !digits(whole) ||
+		(hasFrac && (frac == "" || len(frac) > 2 || !digits(frac))) {
+		return 0, ErrInvalid
+	}
+
+	w, _ := strconv.ParseInt(whole, 10, 64)
+	frac += strings.Repeat("0", 2-len(frac))
+	f, _ := strconv.ParseInt(frac, 10, 64)
+	a := Amount(w*scale + f)
+	if neg {
+		a = -a
+	}
+	return a, nil
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Mul returns the amount of n units priced a.
+func (a Amount) Mul(n int) Amount {
+	return a * Amount(n)
+}
+
+// String formats a as decimal rupiah, without trailing zero decimals.
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign, a = "-", -a
+	}
+	whole, frac := int64(a)/scale, int64(a)%scale
+	switch {
+	case frac == 0:
+		return fmt.Sprintf("%s%d", sign, whole)
+	case frac%10 == 0:
+		return fmt.Sprintf("%s%d.%d", sign, whole, frac/10)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, whole, frac)
+}
+
+// MarshalJSON writes a as a JSON number in rupiah.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a rupiah number, or a string holding one.
+func (a *Amount) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// UnmarshalParam lets gin bind amounts from forms and query strings.
+func (a *Amount) UnmarshalParam(param string) error {
+	v, err := Parse(param)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Value stores a as an integer number of sen.
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// Scan reads a number of sen from the database.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*a = Amount(v)
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case nil:
+		*a = 0
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	return nil
+}
+
+func (a *Amount) scanString(s string) error {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("money: %w", err)
+	}
+	*a = Amount(v)
+	return nil
+}
@@ -0,0 +1,347 @@
+// Package pagination reads the paging parameters shared by list endpoints:
+// a page number and limit, a sort order such as
+// "harga_konsumen:asc,created_at:desc" over the fields a list allows, and
+// opaque cursors that continue after the last row of a page without the
+// cost of skipping over all rows before it.
+package pagination
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+// Sortable is what a list can be sorted by: the fields clients name
+// mapped to their columns, and the order used when they name none. Ties
+// are always broken by id.
+type Sortable struct {
+	Fields  map[string]string
+	Default string
+}
+
+// Key is one key of a sort order.
+type Key struct {
+	Column string
+	Desc   bool
+}
+
+// Query binds the paging parameters from a query string.
+type Query struct {
+	Page   int    `form:"page"`
+	Limit  int    `form:"limit"`
+	Sort   string `form:"sort"`
+	Cursor string `form:"cursor"`
+}
+
+// Keys reads the value of each column a list of T can be sorted by, id
+// included, as it is stored.
+type Keys[T any] map[string]func(row T) interface{}
+
+// Params is the page of a list to return. After holds the values of the
+// columns of Order of the last row of the previous page when paging by
+// cursor, which makes Page unused.
+type Params struct {
+	Page  int
+	Limit int
+	Sort  []Key
+	After []interface{}
+	// sort is Sort as clients name it, carried in the next cursor
+	sort string
+}
+
+// Error is an invalid sort or cursor parameter.
+type Error struct {
+	Param   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Param + ": " + e.Message
+}
+
+// cursor is what an opaque cursor encodes: the sort order and the values
+// of the last row of a page, each as its kind and its text. Carrying the
+// values rather than the id of the row keeps the cursor usable after the
+// row is deleted.
+type cursor struct {
+	Sort   string      `json:"s,omitempty"`
+	Values [][2]string `json:"v"`
+}
+
+// Clamp returns page and limit within bounds: page 1 and DefaultLimit when
+// missing, and at most MaxLimit rows.
+func Clamp(page, limit int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	switch {
+	case limit < 1:
+		limit = DefaultLimit
+	case limit > MaxLimit:
+		limit = MaxLimit
+	}
+	return page, limit
+}
+
+// Parse validates query against sortable. A cursor keeps the sort order of
+// the page it came from; sending a different one with it is an error.
+func Parse(query Query, sortable Sortable) (Params, error) {
+	var p Params
+	p.Page, p.Limit = Clamp(query.Page, query.Limit)
+
+	sort := query.Sort
+	if query.Cursor != "" {
+		c, err := decode(query.Cursor)
+		if err != nil {
+			return Params{}, err
+		}
+		if sort != "" && canonical(sort) != c.Sort {
+			return Params{}, &Error{Param: "cursor", Message: "cursor was issued for another sort order"}
+		}
+		p.After, sort = c.values(), c.Sort
+	}
+	if sort == "" {
+		sort = sortable.Default
+	}
+
+	var err error
+	if p.Sort, err = parseSort(sort, sortable.Fields); err != nil {
+		return Params{}, err
+	}
+	if p.After != nil && len(p.After) != len(p.Order()) {
+		return Params{}, errInvalidCursor
+	}
+	p.sort = canonical(sort)
+	return p, nil
+}
+
+// parseSort reads a comma-separated list of field:direction pairs, the
+// direction being asc, the default, or desc.
+func parseSort(sort string, fields map[string]string) ([]Key, error) {
+	if sort == "" {
+		return nil, nil
+	}
+	var keys []Key
+	var seen []string
+	for _, part := range strings.Split(sort, ",") {
+		name, dir, _ := strings.Cut(strings.TrimSpace(part), ":")
+		column, ok := fields[name]
+		if !ok {
+			return nil, &Error{Param: "sort", Message: fmt.Sprintf("cannot sort by %q, use one of %s", name, names(fields))}
+		}
+		if slices.Contains(seen, name) {
+			return nil, &Error{Param: "sort", Message: fmt.Sprintf("%s is sorted by twice", name)}
+		}
+		seen = append(seen, name)
+
+		key := Key{Column: column}
+		switch strings.ToLower(dir) {
+		case "", "asc":
+		case "desc":
+			key.Desc = true
+		default:
+			return nil, &Error{Param: "sort", Message: fmt.Sprintf("unknown direction %q, use asc or desc", dir)}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// canonical spells a sort order the way cursors record it, so
+// "harga_konsumen" and "harga_konsumen:asc" are the same order.
+func canonical(sort string) string {
+	if sort == "" {
+		return ""
+	}
+	parts := strings.Split(sort, ",")
+	for i, part := range parts {
+		name, dir, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if dir = strings.ToLower(dir); dir == "" {
+			dir = "asc"
+		}
+		parts[i] = name + ":" + dir
+	}
+	return strings.Join(parts, ",")
+}
+
+func names(fields map[string]string) string {
+	list := make([]string, 0, len(fields))
+	for name := range fields {
+		list = append(list, name)
+	}
+	slices.Sort(list)
+	return strings.Join(list, ", ")
+}
+
+var errInvalidCursor = &Error{Param: "cursor", Message: "invalid cursor"}
+
+func decode(s string) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err != nil || len(c.Values) == 0 || c.values() == nil {
+		return cursor{}, errInvalidCursor
+	}
+	return c, nil
+}
+
+// values decodes the values of c, or returns nil if one is invalid.
+func (c cursor) values() []interface{} {
+	values := make([]interface{}, 0, len(c.Values))
+	for _, v := range c.Values {
+		var value interface{}
+		var err error
+		switch kind, text := v[0], v[1]; kind {
+		case "i":
+			value, err = strconv.ParseInt(text, 10, 64)
+		case "u":
+			value, err = strconv.ParseUint(text, 10, 64)
+		case "f":
+			value, err = strconv.ParseFloat(text, 64)
+		case "b":
+			value, err = strconv.ParseBool(text)
+		case "t":
+			value, err = time.Parse(time.RFC3339Nano, text)
+		case "s":
+			value = text
+		default:
+			return nil
+		}
+		if err != nil {
+			return nil
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// normalize returns v as the one type of its kind cursors decode to, so
+// values read from rows and from cursors compare alike: money.Amount
+// becomes its int64 and uint its uint64.
+func normalize(v interface{}) interface{} {
+	if t, ok := v.(time.Time); ok {
+		return t
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	}
+	panic(fmt.Sprintf("pagination: cannot sort by %T", v))
+}
+
+func encodeValue(v interface{}) [2]string {
+	switch v := normalize(v).(type) {
+	case int64:
+		return [2]string{"i", strconv.FormatInt(v, 10)}
+	case uint64:
+		return [2]string{"u", strconv.FormatUint(v, 10)}
+	case float64:
+		return [2]string{"f", strconv.FormatFloat(v, 'g', -1, 64)}
+	case bool:
+		return [2]string{"b", strconv.FormatBool(v)}
+	case time.Time:
+		return [2]string{"t", v.Format(time.RFC3339Nano)}
+	default:
+		return [2]string{"s", v.(string)}
+	}
+}
+
+// Compare orders two values of a sort key, read from rows or cursors.
+// Values of different kinds compare equal.
+func Compare(a, b interface{}) int {
+	switch a := normalize(a).(type) {
+	case int64:
+		b, _ := normalize(b).(int64)
+		return cmp.Compare(a, b)
+	case uint64:
+		b, _ := normalize(b).(uint64)
+		return cmp.Compare(a, b)
+	case float64:
+		b, _ := normalize(b).(float64)
+		return cmp.Compare(a, b)
+	case bool:
+		b, _ := normalize(b).(bool)
+		switch {
+		case a == b:
+			return 0
+		case b:
+			return -1
+		}
+		return 1
+	case time.Time:
+		b, _ := normalize(b).(time.Time)
+		return a.Compare(b)
+	default:
+		b, _ := normalize(b).(string)
+		return strings.Compare(a.(string), b)
+	}
+}
+
+// Order is the full sort order of the page: Sort followed by id, in the
+// direction of the last key so a descending list stays newest first.
+func (p Params) Order() []Key {
+	desc := len(p.Sort) > 0 && p.Sort[len(p.Sort)-1].Desc
+	return append(slices.Clone(p.Sort), Key{Column: "id", Desc: desc})
+}
+
+// Offset is the number of rows before the page when paging by number.
+func (p Params) Offset() int {
+	if p.After != nil || p.Page < 1 {
+		return 0
+	}
+	return (p.Page - 1) * p.Limit
+}
+
+// Anchor returns the values of the columns of Order of row, the After of
+// the page following a page ending with row.
+func Anchor[T any](p Params, row T, keys Keys[T]) []interface{} {
+	var values []interface{}
+	for _, key := range p.Order() {
+		values = append(values, keys[key.Column](row))
+	}
+	return values
+}
+
+// Next returns the cursor of the page after rows, or "" when the page was
+// the last.
+func Next[T any](p Params, rows []T, keys Keys[T]) string {
+	if n := len(rows); n == 0 || n < p.Limit {
+		return ""
+	}
+	c := cursor{Sort: p.sort}
+	for _, v := range Anchor(p, rows[len(rows)-1], keys) {
+		c.Values = append(c.Values, encodeValue(v))
+	}
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// TotalPages is the number of pages of limit rows total rows fill.
+func TotalPages(total int64, limit int) int {
+	if limit < 1 {
+		return 0
+	}
+	return int((total + int64(limit) - 1) / int64(limit))
+}
@@ -0,0 +1,190 @@
+package pagination_test
+
+import (
+	"ecommerce-backend/pkg/money"
+	"ecommerce-backend/pkg/pagination"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+type row struct {
+	ID        uint
+	Name      string
+	Price     money.Amount
+	Rating    float64
+	CreatedAt time.Time
+}
+
+var sortable = pagination.Sortable{
+	Fields: map[string]string{
+		"nama":       "name",
+		"harga":      "price",
+		"rating":     "rating",
+		"created_at": "created_at",
+	},
+	Default: "created_at:desc",
+}
+
+var keys = pagination.Keys[row]{
+	"id":         func(r row) interface{} { return r.ID },
+	"name":       func(r row) interface{} { return r.Name },
+	"price":      func(r row) interface{} { return r.Price },
+	"rating":     func(r row) interface{} { return r.Rating },
+	"created_at": func(r row) interface{} { return r.CreatedAt },
+}
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		page, limit         int
+		wantPage, wantLimit int
+	}{
+		{0, 0, 1, pagination.DefaultLimit},
+		{-3, -1, 1, pagination.DefaultLimit},
+		{2, 25, 2, 25},
+		{1, pagination.MaxLimit + 1, 1, pagination.MaxLimit},
+	}
+	for _, tt := range tests {
+		page, limit := pagination.Clamp(tt.page, tt.limit)
+		if page != tt.wantPage || limit != tt.wantLimit {
+			t.Errorf("Clamp(%d, %d) = %d, %d, want %d, %d", tt.page, tt.limit, page, limit, tt.wantPage, tt.wantLimit)
+		}
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		sort string
+		want []pagination.Key
+	}{
+		{"", []pagination.Key{{Column: "created_at", Desc: true}, {Column: "id", Desc: true}}},
+		{"harga", []pagination.Key{{Column: "price"}, {Column: "id"}}},
+		{"harga:DESC, nama", []pagination.Key{{Column: "price", Desc: true}, {Column: "name"}, {Column: "id"}}},
+		{"rating:desc", []pagination.Key{{Column: "rating", Desc: true}, {Column: "id", Desc: true}}},
+	}
+	for _, tt := range tests {
+		p, err := pagination.Parse(pagination.Query{Sort: tt.sort}, sortable)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.sort, err)
+		}
+		if got := p.Order(); !slices.Equal(got, tt.want) {
+			t.Errorf("Parse(%q).Order() = %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+
+	for _, sort := range []string{"id", "stok", "harga:up", "harga,harga:desc"} {
+		_, err := pagination.Parse(pagination.Query{Sort: sort}, sortable)
+		var paramErr *pagination.Error
+		if !errors.As(err, &paramErr) || paramErr.Param != "sort" {
+			t.Errorf("Parse(%q): got %v, want a sort error", sort, err)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	p, _ := pagination.Parse(pagination.Query{Page: 3, Limit: 20}, sortable)
+	if p.Offset() != 40 {
+		t.Fatalf("Offset() = %d, want 40", p.Offset())
+	}
+	if got := pagination.TotalPages(41, 20); got != 3 {
+		t.Fatalf("TotalPages(41, 20) = %d, want 3", got)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	created := time.Date(2026, 3, 1, 10, 30, 0, 123456789, time.FixedZone("WIB", 7*3600))
+	rows := []row{
+		{ID: 4, Name: "Kopi", Price: money.Rupiah(15000), Rating: 4.5, CreatedAt: created.Add(time.Hour)},
+		{ID: 9, Name: "Teh \"tubruk\"", Price: 2500050, Rating: 0.1, CreatedAt: created},
+	}
+	for _, sort := range []string{"", "harga:desc,nama", "rating", "created_at:asc"} {
+		p, err := pagination.Parse(pagination.Query{Limit: 2, Sort: sort}, sortable)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cursor := pagination.Next(p, rows, keys)
+		if cursor == "" {
+			t.Fatalf("%q: no cursor after a full page", sort)
+		}
+
+		next, err := pagination.Parse(pagination.Query{Limit: 2, Cursor: cursor}, sortable)
+		if err != nil {
+			t.Fatalf("%q: Parse(cursor): %v", sort, err)
+		}
+		if !slices.Equal(next.Order(), p.Order()) {
+			t.Fatalf("%q: cursor order %v, want %v", sort, next.Order(), p.Order())
+		}
+		want := pagination.Anchor(p, rows[1], keys)
+		if len(next.After) != len(want) {
+			t.Fatalf("%q: After = %v, want %v", sort, next.After, want)
+		}
+		for i := range want {
+			if pagination.Compare(next.After[i], want[i]) != 0 {
+				t.Fatalf("%q: After[%d] = %v, want %v", sort, i, next.After[i], want[i])
+			}
+		}
+		if next.Offset() != 0 {
+			t.Fatalf("%q: Offset() = %d with a cursor", sort, next.Offset())
+		}
+
+		// the sort order of the cursor may be repeated, but not changed
+		if _, err := pagination.Parse(pagination.Query{Sort: sort, Cursor: cursor}, sortable); sort != "" && err != nil {
+			t.Fatalf("%q: Parse(same sort, cursor): %v", sort, err)
+		}
+		if _, err := pagination.Parse(pagination.Query{Sort: "nama:desc", Cursor: cursor}, sortable); err == nil {
+			t.Fatalf("%q: cursor accepted for another sort order", sort)
+		}
+	}
+}
+
+func TestLastPage(t *testing.T) {
+	p, _ := pagination.Parse(pagination.Query{Limit: 3}, sortable)
+	if cursor := pagination.Next(p, []row{{ID: 1}, {ID: 2}}, keys); cursor != "" {
+		t.Fatalf("cursor %q after a short page", cursor)
+	}
+	if cursor := pagination.Next(p, nil, keys); cursor != "" {
+		t.Fatalf("cursor %q after an empty page", cursor)
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for name, cursor := range map[string]string{
+		"garbage":        "not a cursor",
+		"not json":       encode("{"),
+		"no values":      encode(`{"s":"created_at:desc","v":[]}`),
+		"unknown kind":   encode(`{"s":"created_at:desc","v":[["x","1"],["u","1"]]}`),
+		"bad number":     encode(`{"s":"created_at:desc","v":[["t","2026-03-01T10:30:00Z"],["u","-1"]]}`),
+		"missing values": encode(`{"s":"harga:asc,nama:asc","v":[["i","100"],["u","1"]]}`),
+		"unknown sort":   encode(`{"s":"stok:asc","v":[["i","1"],["u","1"]]}`),
+	} {
+		_, err := pagination.Parse(pagination.Query{Cursor: cursor}, sortable)
+		var paramErr *pagination.Error
+		if !errors.As(err, &paramErr) {
+			t.Errorf("%s: got %v, want a parameter error", name, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{uint(1), uint64(2), -1},
+		{money.Amount(250), int64(250), 0},
+		{3, int64(2), 1},
+		{4.5, 4.25, 1},
+		{"apel", "Apel", 1},
+		{now, now.Add(time.Nanosecond), -1},
+		{false, true, -1},
+	}
+	for _, tt := range tests {
+		if got := pagination.Compare(tt.a, tt.b); got != tt.want {
+			t.Errorf("Compare(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package slug turns product names into the slugs of storefront URLs:
+// lowercase ASCII letters and digits joined by single hyphens, so
+// "Kaos Polos Café & Topi (XL)" becomes "kaos-polos-cafe-dan-topi-xl".
+package slug
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxLength is the length of the longest slug, suffix included.
+const MaxLength = 80
+
+// transliterations spells out what does not decompose into ASCII letters
+// and accents. Apostrophes are dropped rather than split words.
+var transliterations = map[rune]string{
+	'ß':  "ss",
+	'æ':  "ae",
+	'œ':  "oe",
+	'ø':  "o",
+	'đ':  "d",
+	'ð':  "d",
+	'ł':  "l",
+	'þ':  "th",
+	'ı':  "i",
+	'&':  " dan ",
+	'\'': "",
+	'’':  "",
+}
+
+// Make returns the slug of s. Accents are stripped, other letters and
+// symbols become hyphens, and long slugs are cut at a word boundary. It
+// returns "" when s has no letter or digit it can spell in ASCII.
+func Make(s string) string {
+	var ascii strings.Builder
+	for _, r := range norm.NFKD.String(s) {
+		r = unicode.ToLower(r)
+		if t, ok := transliterations[r]; ok {
+			ascii.WriteString(t)
+		} else if !unicode.Is(unicode.Mn, r) {
+			ascii.WriteRune(r)
+		}
+	}
+
+	var b strings.Builder
+	hyphen := false
+	for _, r := range ascii.String() {
+		if 'a' <= r && r <= 'z' || '0' <= r && r <= '9' {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	return truncate(b.String(), MaxLength)
+}
+
+// WithSuffix returns the n-th candidate for a taken slug: slug itself for
+// n <= 1, then slug-2, slug-3 and so on, cut to fit MaxLength.
+func WithSuffix(slug string, n int) string {
+	if n <= 1 {
+		return slug
+	}
+	suffix := "-" + strconv.Itoa(n)
+	return truncate(slug, MaxLength-len(suffix)) + suffix
+}
+
+// truncate cuts slug to at most n bytes, at the last hyphen when it has one.
+func truncate(slug string, n int) string {
+	if len(slug) <= n {
+		return slug
+	}
+	if slug[n] == '-' {
+		return slug[:n]
+	}
+	cut := slug[:n]
+	if i := strings.LastIndexByte(cut, '-'); i > 0 {
+		cut = cut[:i]
+	}
+	return cut
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,7 +11,12 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-var SecretKey = []byte("supersecretkey") // Use ENV in production
+// Set from config at startup
+var (
+	SecretKey  = []byte("supersecretkey")
+	TokenTTL   = 24 * time.Hour
+	UploadPath = "public/uploads"
+)
 
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -24,11 +28,21 @@ func CheckPassword(hash, password string) bool {
 	return err == nil
 }
 
+// Claims is what an access token says about its user, as written by
+// GenerateToken.
+type Claims struct {
+	UserID  uint `json:"user_id"`
+	IsAdmin bool `json:"is_admin"`
+	jwt.RegisteredClaims
+}
+
 func GenerateToken(userID uint, isAdmin bool) (string, error) {
-	claims := jwt.MapClaims{
-		"user_id":  userID,
-		"is_admin": isAdmin,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
+	claims := Claims{
+		UserID:  userID,
+		IsAdmin: isAdmin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenTTL)),
+		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(SecretKey)
@@ -51,14 +65,13 @@ func SaveUploadedFile(ctx *gin.Context, paramName string) (string, error) {
 	}
 
 	// Create uploads directory if not exists
-	uploadPath := "public/uploads"
-	if _, err := os.Stat(uploadPath); os.IsNotExist(err) {
-		os.MkdirAll(uploadPath, 0755)
+	if _, err := os.Stat(UploadPath); os.IsNotExist(err) {
+		os.MkdirAll(UploadPath, 0755)
 	}
 
 	// Generate unique filename
 	filename := fmt.Sprintf("%d-%s", time.Now().Unix(), filepath.Base(file.Filename))
-	dst := filepath.Join(uploadPath, filename)
+	dst := filepath.Join(UploadPath, filename)
 
 	if err := ctx.SaveUploadedFile(file, dst); err != nil {
 		return "", err
@@ -66,8 +79,3 @@ func SaveUploadedFile(ctx *gin.Context, paramName string) (string, error) {
 	
 	return filename, nil
 }
-
-// Slugify Helper (Added this function)
-func Slugify(s string) string {
-	return strings.ToLower(strings.ReplaceAll(s, " ", "-"))
-}
\ No newline at end of file